@@ -4,6 +4,9 @@ $ENV:GOBIN="C:/Users/Dekr0/go/bin"
 $Env:GOOSE_DBSTRING="build_15016"
 $Env:GOOSE_MIGRATION_DIR="sql/schema"
 $Env:GOOSE_DRIVER="sqlite3"
+# Build of the previous game version (e.g. "build_15016") that asset hashes and
+# source IDs are compared with. If empty, `${GOOSE_DBSTRING}_backup` is used.
+$Env:PREV_GOOSE_DBSTRING=""
 
 function config {
     go install github.com/sqlc-dev/sqlc/cmd/sqlc@latest
@@ -53,3 +56,130 @@ function extract_soundbank_db {
     )
     go run . --extract_soundbank_db --dest $dest
 }
+
+function extract_asset {
+    param (
+        $aid,
+        $fid,
+        $tid,
+        $dest
+    )
+    go run . --extract_asset --aid $aid --fid $fid --tid $tid --dest $dest
+}
+
+function extract_source {
+    param (
+        $sid,
+        $dest
+    )
+    go run . --extract_source --sid $sid --dest $dest
+}
+
+function extract_source_ogg {
+    param (
+        $sid,
+        $dest,
+        $codebooks
+    )
+    go run . --extract_source --format ogg --codebooks=$codebooks --sid $sid --dest $dest
+}
+
+function extract_source_wav {
+    param (
+        $sid,
+        $dest
+    )
+    go run . --extract_source --format wav --sid $sid --dest $dest
+}
+
+function extract_source_opus {
+    param (
+        $sid,
+        $dest
+    )
+    go run . --extract_source --format opus --sid $sid --dest $dest
+}
+
+function trace_soundbank {
+    param (
+        $aid,
+        $fid,
+        $dest
+    )
+    go run . --trace_soundbank --aid $aid --fid $fid --dest $dest
+}
+
+function fsck_archive {
+    param (
+        $dest
+    )
+    go run . --fsck --dest $dest
+}
+
+function analyze_toc {
+    param (
+        $dest
+    )
+    go run . --analyze_toc --dest $dest
+}
+
+function localized {
+    param (
+        $aid,
+        $fid
+    )
+    go run . --localized --aid $aid --fid $fid
+}
+
+function match_sources {
+    param (
+        $dest
+    )
+    go run . --match_sources --dest $dest
+}
+
+function duplicates {
+    param (
+        $dest
+    )
+    go run . --duplicates --dest $dest
+}
+
+function compare_loudness {
+    param (
+        $sid,
+        $replacement
+    )
+    go run . --compare_loudness --sid $sid --replacement $replacement
+}
+
+function validate_replacement {
+    param (
+        $sid,
+        $replacement
+    )
+    go run . --validate_replacement --sid $sid --replacement $replacement
+}
+
+function import_label {
+    param (
+        $dest
+    )
+    go run . --import_label --dest $dest
+}
+
+function search {
+    param (
+        $tags = "",
+        $categories = ""
+    )
+    go run . --search --tags "$tags" --categories "$categories"
+}
+
+function export_waveform {
+    param (
+        $dest,
+        $sid = 0
+    )
+    go run . --export_waveform --dest $dest --sid $sid
+}
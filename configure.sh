@@ -3,6 +3,10 @@ export CGO_ENABLE=1
 export GOOSE_DRIVER="sqlite3"
 
 VERSION=15637
+# Build of the previous game version. Asset hashes and source IDs are compared
+# with `build_${PREV_VERSION}`. If empty, they are compared with
+# `build_${VERSION}_backup`, which is the last run of the same build.
+PREV_VERSION=""
 
 config() {
     go install github.com/sqlc-dev/sqlc/cmd/sqlc@latest
@@ -36,6 +40,12 @@ goose_up_id() {
     goose up
 }
 
+prev_build() {
+    if [ -n "$PREV_VERSION" ]; then
+        export PREV_GOOSE_DBSTRING="build_${PREV_VERSION}"
+    fi
+}
+
 reset_log() {
     if [ -e log.txt ]; then
         rm log.txt
@@ -46,6 +56,7 @@ generate() {
     goose_up_complete
 
     reset_log
+    prev_build
 
     go run . --insert_archive
     go run . --generate
@@ -71,3 +82,68 @@ extract_soundbank() {
 extract_soundbank_db() {
     go run . --extract_soundbank_db --dest $1
 }
+
+extract_asset() {
+    go run . --extract_asset --aid $1 --fid $2 --tid $3 --dest $4
+}
+
+extract_source() {
+    go run . --extract_source --sid $1 --dest $2
+}
+
+extract_source_ogg() {
+    go run . --extract_source --format ogg --codebooks=$3 --sid $1 --dest $2
+}
+
+extract_source_wav() {
+    go run . --extract_source --format wav --sid $1 --dest $2
+}
+
+extract_source_opus() {
+    go run . --extract_source --format opus --sid $1 --dest $2
+}
+
+trace_soundbank() {
+    go run . --trace_soundbank --aid $1 --fid $2 --dest $3
+}
+
+fsck_archive() {
+    go run . --fsck --dest $1
+}
+
+analyze_toc() {
+    go run . --analyze_toc --dest $1
+}
+
+localized() {
+    go run . --localized --aid $1 --fid $2
+}
+
+match_sources() {
+    prev_build
+    go run . --match_sources --dest $1
+}
+
+duplicates() {
+    go run . --duplicates --dest $1
+}
+
+compare_loudness() {
+    go run . --compare_loudness --sid $1 --replacement $2
+}
+
+validate_replacement() {
+    go run . --validate_replacement --sid $1 --replacement $2
+}
+
+import_label() {
+    go run . --import_label --dest $1
+}
+
+search() {
+    go run . --search --tags "$1" --categories "$2"
+}
+
+export_waveform() {
+    go run . --export_waveform --dest $1 --sid ${2:-0}
+}
@@ -0,0 +1,337 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	wio "dekr0/hd2_audio_db/io"
+	"dekr0/hd2_audio_db/parser"
+)
+
+// Maximum number of distinct values tracked per field. Values beyond that are
+// still counted in Samples and hypotheses but not in Top.
+const maxDistinct = 4096
+const maxTop = 16
+
+type ValueCount struct {
+	Value uint64 `json:"value"`
+	Count uint64 `json:"count"`
+}
+
+// Hypothesis is a relation between a field and a known property of the ToC.
+// Support is the number of samples where the relation holds.
+type Hypothesis struct {
+	Name    string `json:"name"`
+	Support uint64 `json:"support"`
+	Samples uint64 `json:"samples"`
+}
+
+type FieldStat struct {
+	Name           string       `json:"name"`
+	Samples        uint64       `json:"samples"`
+	Distinct       int          `json:"distinct"`
+	DistinctCapped bool         `json:"distinct_capped"`
+	Zero           uint64       `json:"zero"`
+	PowerOfTwo     uint64       `json:"power_of_two"`
+	Top            []ValueCount `json:"top"`
+	Hypotheses     []Hypothesis `json:"hypotheses"`
+}
+
+type TocAnalysis struct {
+	Archives int         `json:"archives"`
+	Failed   []string    `json:"failed"`
+	Fields   []FieldStat `json:"fields"`
+}
+
+type fieldAcc struct {
+	samples uint64
+	zero    uint64
+	pow2    uint64
+	capped  bool
+	counts  map[uint64]uint64
+	hyps    map[string]*[2]uint64
+}
+
+type tocAcc struct {
+	m      sync.Mutex
+	fields map[string]*fieldAcc
+}
+
+func (t *tocAcc) add(name string, v uint64, hyps map[string]bool) {
+	f, in := t.fields[name]
+	if !in {
+		f = &fieldAcc{
+			counts: make(map[uint64]uint64),
+			hyps: make(map[string]*[2]uint64),
+		}
+		t.fields[name] = f
+	}
+	f.samples += 1
+	if v == 0 {
+		f.zero += 1
+	} else if bits.OnesCount64(v) == 1 {
+		f.pow2 += 1
+	}
+	if _, in := f.counts[v]; in || len(f.counts) < maxDistinct {
+		f.counts[v] += 1
+	} else {
+		f.capped = true
+	}
+	for h, ok := range hyps {
+		c, in := f.hyps[h]
+		if !in {
+			c = &[2]uint64{}
+			f.hyps[h] = c
+		}
+		if ok {
+			c[0] += 1
+		}
+		c[1] += 1
+	}
+}
+
+// AnalyzeToc gathers the fields of the ToC whose meaning is unknown across all
+// archives in `data` and writes their value distributions into `w` as JSON.
+// Each field is tested against a few hypotheses (alignment of offsets, equal to
+// sizes, counts or indices) so that a field whose meaning is guessed right
+// shows full support. The report only provides evidence. It does not decide.
+func AnalyzeToc(ctx context.Context, data string, w io.Writer) (*TocAnalysis, error) {
+	f, err := os.Open(data)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	acc := tocAcc{fields: make(map[string]*fieldAcc)}
+	analysis := TocAnalysis{Failed: []string{}}
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, MaxArchiveReder)
+	for {
+		entries, err := f.ReadDir(1024)
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !isArchive(entry) { continue }
+
+			select {
+			case <- ctx.Done():
+				wg.Wait()
+				return nil, ctx.Err()
+			case sem <- struct{}{}:
+				wg.Add(1)
+				go func(aid string) {
+					defer wg.Done()
+					defer func() { <- sem }()
+					a, err := parseFullToc(filepath.Join(data, aid))
+					acc.m.Lock()
+					defer acc.m.Unlock()
+					if err != nil {
+						analysis.Failed = append(analysis.Failed, aid)
+						return
+					}
+					analysis.Archives += 1
+					acc.archive(a)
+				}(entry.Name())
+			}
+		}
+	}
+	wg.Wait()
+
+	analysis.Fields = acc.stats()
+	slices.Sort(analysis.Failed)
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(&analysis); err != nil {
+		return nil, err
+	}
+	return &analysis, nil
+}
+
+func parseFullToc(p string) (a *parser.Archive, err error) {
+	m, err := wio.OpenMmapReader(p)
+	if err != nil {
+		return nil, err
+	}
+	defer m.Close()
+
+	defer func() {
+		if r := recover(); r != nil {
+			a = nil
+			err = fmt.Errorf("Failed to parse ToC of %s: %v", p, r)
+		}
+	}()
+
+	a = &parser.Archive{}
+	parser.ParseArchiveMmap(a, m)
+	return a, nil
+}
+
+// archive accumulates all unknown fields of one archive. Caller must hold t.m.
+func (t *tocAcc) archive(a *parser.Archive) {
+	t.add("archive.unknown", uint64(a.Unknown), map[string]bool{
+		"equals_num_files": uint64(a.Unknown) == uint64(a.NumFiles),
+		"equals_num_types": uint64(a.Unknown) == uint64(a.NumTypes),
+	})
+	for i := 0; i < len(a.Unk4Data); i += 4 {
+		t.add(
+			fmt.Sprintf("archive.unk4_data[%d:%d]", i, i + 4),
+			uint64(wio.ByteOrder.Uint32(a.Unk4Data[i:i + 4])),
+			nil,
+		)
+	}
+
+	type typeProps struct {
+		dataAlign    uint64
+		streamAlign  uint64
+		gpuRsrcAlign uint64
+		totalData    uint64
+		maxData      uint64
+	}
+	props := make(map[uint64]*typeProps, a.NumTypes)
+	for i := range a.Headers {
+		h := &a.Headers[i]
+		p, in := props[h.TypeID]
+		if !in {
+			p = &typeProps{}
+			props[h.TypeID] = p
+		}
+		p.dataAlign = gcdAlign(p.dataAlign, h.DataOffset)
+		if h.StreamSize > 0 {
+			p.streamAlign = gcdAlign(p.streamAlign, h.StreamOffset)
+		}
+		if h.GPURsrcSize > 0 {
+			p.gpuRsrcAlign = gcdAlign(p.gpuRsrcAlign, h.GPURsrcOffset)
+		}
+		p.totalData += uint64(h.DataSize)
+		p.maxData = max(p.maxData, uint64(h.DataSize))
+	}
+
+	for _, cnt := range a.AssetTypeCnts {
+		p, in := props[cnt.Type]
+		if !in {
+			p = &typeProps{}
+		}
+		for _, field := range []struct {
+			name string
+			v    uint64
+		}{
+			{"unknown", cnt.Unknown},
+			{"unknown_u32a", uint64(cnt.UnknownU32A)},
+			{"unknown_u32b", uint64(cnt.UnknownU32B)},
+		} {
+			hyps := map[string]bool{
+				"equals_num": field.v == cnt.Num,
+				"equals_data_alignment": field.v == p.dataAlign,
+				"equals_stream_alignment": p.streamAlign != 0 && field.v == p.streamAlign,
+				"equals_gpu_rsrc_alignment": p.gpuRsrcAlign != 0 && field.v == p.gpuRsrcAlign,
+				"equals_total_data_size": field.v == p.totalData,
+				"equals_max_data_size": field.v == p.maxData,
+			}
+			t.add("type." + field.name, field.v, hyps)
+			t.add(fmt.Sprintf("type[%d].%s", cnt.Type, field.name), field.v, hyps)
+		}
+	}
+
+	for i := range a.Headers {
+		h := &a.Headers[i]
+		for _, field := range []struct {
+			name string
+			v    uint64
+		}{
+			{"unknown_u64a", h.UnknownU64A},
+			{"unknown_u64b", h.UnknownU64B},
+			{"unknown_u32a", uint64(h.UnknownU32A)},
+			{"unknown_u32b", uint64(h.UnknownU32B)},
+		} {
+			v := field.v
+			pow2 := v != 0 && bits.OnesCount64(v) == 1
+			hyps := map[string]bool{
+				"equals_data_size": v == uint64(h.DataSize),
+				"equals_stream_size": v == uint64(h.StreamSize),
+				"equals_gpu_rsrc_size": v == uint64(h.GPURsrcSize),
+				"ge_data_size": v >= uint64(h.DataSize),
+				"equals_index": v == uint64(h.Idx),
+				"divides_data_offset": pow2 && h.DataOffset % v == 0,
+			}
+			if h.StreamSize > 0 {
+				hyps["divides_stream_offset"] = pow2 && h.StreamOffset % v == 0
+			}
+			if h.GPURsrcSize > 0 {
+				hyps["divides_gpu_rsrc_offset"] = pow2 && h.GPURsrcOffset % v == 0
+			}
+			t.add("asset." + field.name, v, hyps)
+			t.add(fmt.Sprintf("asset[%d].%s", h.TypeID, field.name), v, hyps)
+		}
+	}
+}
+
+// gcdAlign returns the largest power of two that divides both `align` and
+// `offset`. An `align` of 0 means no offset has been seen yet.
+func gcdAlign(align uint64, offset uint64) uint64 {
+	if offset == 0 {
+		return align
+	}
+	a := offset & -offset
+	if align == 0 {
+		return a
+	}
+	return min(align, a)
+}
+
+func (t *tocAcc) stats() []FieldStat {
+	names := make([]string, 0, len(t.fields))
+	for name := range t.fields {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	stats := make([]FieldStat, 0, len(names))
+	for _, name := range names {
+		f := t.fields[name]
+		s := FieldStat{
+			Name: name,
+			Samples: f.samples,
+			Distinct: len(f.counts),
+			DistinctCapped: f.capped,
+			Zero: f.zero,
+			PowerOfTwo: f.pow2,
+			Top: make([]ValueCount, 0, len(f.counts)),
+			Hypotheses: make([]Hypothesis, 0, len(f.hyps)),
+		}
+		for v, c := range f.counts {
+			s.Top = append(s.Top, ValueCount{v, c})
+		}
+		slices.SortFunc(s.Top, func(a, b ValueCount) int {
+			if a.Count != b.Count {
+				if a.Count > b.Count { return -1 }
+				return 1
+			}
+			if a.Value < b.Value { return -1 }
+			if a.Value > b.Value { return 1 }
+			return 0
+		})
+		s.Top = s.Top[:min(len(s.Top), maxTop)]
+		for h, c := range f.hyps {
+			s.Hypotheses = append(s.Hypotheses, Hypothesis{h, c[0], c[1]})
+		}
+		slices.SortFunc(s.Hypotheses, func(a, b Hypothesis) int {
+			if a.Name < b.Name { return -1 }
+			if a.Name > b.Name { return 1 }
+			return 0
+		})
+		stats = append(stats, s)
+	}
+	return stats
+}
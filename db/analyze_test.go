@@ -0,0 +1,59 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"dekr0/hd2_audio_db/parser"
+)
+
+func TestAnalyzeToc(t *testing.T) {
+	data := t.TempDir()
+	// Data offsets start at 264 (72 + 32 + 2 * 80) so they are 8 bytes aligned
+	headers := []parser.AssetHeader{
+		{FileID: 1, TypeID: 42, DataSize: 16, UnknownU32A: 16, UnknownU64A: 8},
+		{FileID: 2, TypeID: 42, DataOffset: 16, DataSize: 8, UnknownU32A: 8, UnknownU64A: 8},
+	}
+	writeTestArchive(t, filepath.Join(data, "0000000000000001"), headers, make([]byte, 24))
+
+	b := bytes.Buffer{}
+	analysis, err := AnalyzeToc(context.Background(), data, &b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if analysis.Archives != 1 || len(analysis.Failed) != 0 {
+		t.Fatalf("unexpected analysis %s", b.String())
+	}
+
+	support := func(field string, hyp string) (uint64, uint64) {
+		for _, f := range analysis.Fields {
+			if f.Name != field {
+				continue
+			}
+			for _, h := range f.Hypotheses {
+				if h.Name == hyp {
+					return h.Support, h.Samples
+				}
+			}
+		}
+		t.Fatalf("missing hypothesis %s of %s", hyp, field)
+		return 0, 0
+	}
+	if s, n := support("asset.unknown_u32a", "equals_data_size"); s != 2 || n != 2 {
+		t.Fatalf("expect full support, got %d / %d", s, n)
+	}
+	if s, n := support("asset[42].unknown_u64a", "divides_data_offset"); s != 2 || n != 2 {
+		t.Fatalf("expect full support, got %d / %d", s, n)
+	}
+	if s, _ := support("asset.unknown_u64b", "divides_data_offset"); s != 0 {
+		t.Fatalf("expect no support for zero, got %d", s)
+	}
+}
+
+func TestGcdAlign(t *testing.T) {
+	if a := gcdAlign(gcdAlign(gcdAlign(0, 0), 0x300), 0x40); a != 0x40 {
+		t.Fatalf("expect 0x40, got %#x", a)
+	}
+}
@@ -0,0 +1,272 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+
+	database "dekr0/hd2_audio_db/internal/complete"
+	wio "dekr0/hd2_audio_db/io"
+	"dekr0/hd2_audio_db/wem"
+)
+
+// sourceAudioParams parses the WEM header and computes the fingerprint of 
+// every source in `locationInsert`. Sources that can be decoded are measured 
+// for loudness and get a waveform envelope as well. A stream location is 
+// preferred since the main archive only holds the prefetched head of a 
+// prefetch + stream source. Sources whose header cannot be parsed are skipped 
+// with a warning. Sources in an unchanged asset reuse the rows of the previous 
+// build in `prev` (if not nil) instead of being decoded again.
+func sourceAudioParams(
+	ctx context.Context,
+	data string,
+	locationInsert []database.InsertSourceLocationParams,
+	prev *prevSources,
+) (
+	[]database.InsertSourceAudioParams,
+	[]database.InsertSourceFingerprintParams,
+	[]database.InsertSourceLoudnessParams,
+	[]database.InsertSourceWaveformParams,
+	error,
+) {
+	sources := make(map[int64]*database.InsertSourceLocationParams)
+	order := []int64{}
+	for i := range locationInsert {
+		l := &locationInsert[i]
+		seen, in := sources[l.Sid]
+		if !in {
+			order = append(order, l.Sid)
+		}
+		if !in || (seen.Part == PartMain && l.Part == PartStream) {
+			sources[l.Sid] = l
+		}
+	}
+
+	parts := make(map[string]*wio.MmapReader)
+	defer func() {
+		for _, m := range parts {
+			if m != nil {
+				m.Close()
+			}
+		}
+	}()
+
+	audioInsert := make([]database.InsertSourceAudioParams, 0, len(order))
+	fingerprintInsert := make([]database.InsertSourceFingerprintParams, 0, len(order))
+	loudnessInsert := []database.InsertSourceLoudnessParams{}
+	waveformInsert := []database.InsertSourceWaveformParams{}
+	reused := 0
+	for _, sid := range order {
+		select {
+		case <- ctx.Done():
+			return nil, nil, nil, nil, ctx.Err()
+		default:
+		}
+
+		l := sources[sid]
+		p := sourceFile(data, l.Aid, l.Part)
+		m, in := parts[p]
+		if !in {
+			m = openPart(p)
+			parts[p] = m
+		}
+		if m == nil {
+			slog.Warn("Missing archive of source", "path", p, "sid", sid)
+			continue
+		}
+		b, err := m.Slice(uint(l.FileOffset), uint(l.Size))
+		if err != nil {
+			slog.Warn("Source is out of range", "path", p, "sid", sid, "error", err)
+			continue
+		}
+		w, err := wem.Parse(b)
+		if err != nil {
+			slog.Warn("Failed to parse WEM header", "path", p, "sid", sid, "error", err)
+			continue
+		}
+
+		a := database.InsertSourceAudioParams{
+			Sid: sid,
+			Codec: string(w.Codec),
+			FormatTag: int64(w.FormatTag),
+			Channels: int64(w.Channels),
+			SampleRate: int64(w.SampleRate),
+			SampleCount: int64(w.SampleCount),
+			Duration: w.Duration(),
+			LoopStart: int64(w.LoopStart),
+			LoopEnd: int64(w.LoopEnd),
+			CueCount: int64(len(w.Cues)),
+		}
+		if w.HasLoop {
+			a.HasLoop = 1
+		}
+		audioInsert = append(audioInsert, a)
+
+		if f, in := prev.fingerprint(l); in {
+			fingerprintInsert = append(fingerprintInsert, f)
+			if l, in := prev.loudness[sid]; in {
+				loudnessInsert = append(loudnessInsert, database.InsertSourceLoudnessParams{
+					Sid: l.Sid,
+					Integrated: l.Integrated,
+					TruePeak: l.TruePeak,
+					SamplePeak: l.SamplePeak,
+					Rms: l.Rms,
+					Duration: l.Duration,
+					Version: l.Version,
+				})
+			}
+			if w, in := prev.waveforms[sid]; in {
+				waveformInsert = append(waveformInsert, database.InsertSourceWaveformParams{
+					Sid: w.Sid,
+					Channels: w.Channels,
+					SampleRate: w.SampleRate,
+					SamplesPerBucket: w.SamplesPerBucket,
+					Peaks: w.Peaks,
+					Version: w.Version,
+				})
+			}
+			reused += 1
+			continue
+		}
+
+		// nil if the codec cannot be decoded
+		samples, _ := wem.Decode(w, b)
+		fingerprintInsert = append(fingerprintInsert, fingerprintParams(sid, w, b, samples))
+		if samples != nil {
+			loudnessInsert = append(loudnessInsert, loudnessParams(sid, w, samples))
+			waveformInsert = append(waveformInsert, waveformParams(sid, w, samples))
+		}
+	}
+	if prev != nil {
+		slog.Info(
+			"Reused sources of unchanged assets",
+			"sources", len(audioInsert),
+			"reused", reused,
+		)
+	}
+	return audioInsert, fingerprintInsert, loudnessInsert, waveformInsert, nil
+}
+
+// Version of wem.Decode, fingerprint.FromPCM, loudness.Measure and 
+// waveform.FromPCM stored with the fingerprint, loudness and waveform rows. 
+// Bump it whenever one of them changes its output so that rows of the previous 
+// build are computed again instead of reused.
+const sourceAnalysisVersion = 1
+
+// prevSources holds fingerprint, loudness and waveform rows of the previous 
+// build with the current sourceAnalysisVersion, and the assets that are 
+// unchanged since then.
+type prevSources struct {
+	unchanged    map[assetKey]struct{}
+	fingerprints map[int64]database.SourceFingerprint
+	loudness     map[int64]database.SourceLoudness
+	waveforms    map[int64]database.SourceWaveform
+}
+
+// fingerprint returns the fingerprint of the previous build for the source at 
+// `l` if its asset is unchanged. A nil `p` has nothing to reuse.
+func (p *prevSources) fingerprint(
+	l *database.InsertSourceLocationParams,
+) (database.InsertSourceFingerprintParams, bool) {
+	if p == nil {
+		return database.InsertSourceFingerprintParams{}, false
+	}
+	if _, in := p.unchanged[assetKey{l.Aid, l.Fid, l.Tid}]; !in {
+		return database.InsertSourceFingerprintParams{}, false
+	}
+	f, in := p.fingerprints[l.Sid]
+	if !in {
+		return database.InsertSourceFingerprintParams{}, false
+	}
+	return database.InsertSourceFingerprintParams{
+		Sid: f.Sid,
+		Kind: f.Kind,
+		ContentHash: f.ContentHash,
+		Fingerprint: f.Fingerprint,
+		Version: f.Version,
+	}, true
+}
+
+// loadPrevSources loads the rows of the previous build that sources of the 
+// unchanged assets in `assetInsert` (see markUnchanged) can reuse. It returns 
+// nil if nothing can be reused.
+func loadPrevSources(
+	ctx context.Context, assetInsert []database.InsertAssetParams,
+) (*prevSources, error) {
+	prev := prevSources{
+		unchanged: make(map[assetKey]struct{}),
+		fingerprints: make(map[int64]database.SourceFingerprint),
+		loudness: make(map[int64]database.SourceLoudness),
+		waveforms: make(map[int64]database.SourceWaveform),
+	}
+	for _, a := range assetInsert {
+		if a.Unchanged == 1 {
+			prev.unchanged[assetKey{a.Aid, a.Fid, a.Tid}] = struct{}{}
+		}
+	}
+	if len(prev.unchanged) == 0 {
+		return nil, nil
+	}
+
+	p := prevDBString()
+	c, err := sql.Open("sqlite3", p)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	q := database.New(c)
+	fingerprints, err := q.GetAllSourceFingerprint(ctx)
+	if err != nil {
+		slog.Warn("Previous build has no source fingerprints", "path", p, "error", err)
+		return nil, nil
+	}
+	loudness, err := q.GetAllSourceLoudness(ctx)
+	if err != nil {
+		slog.Warn("Previous build has no source loudness", "path", p, "error", err)
+		return nil, nil
+	}
+	waveforms, err := q.GetAllSourceWaveform(ctx)
+	if err != nil {
+		slog.Warn("Previous build has no source waveforms", "path", p, "error", err)
+		return nil, nil
+	}
+	stale := prev.add(fingerprints, loudness, waveforms)
+	if stale > 0 {
+		slog.Info(
+			"Previous build has sources of another analysis version",
+			"path", p,
+			"stale", stale,
+			"version", sourceAnalysisVersion,
+		)
+	}
+	return &prev, c.Close()
+}
+
+// add keeps the rows of the current sourceAnalysisVersion and returns the 
+// number of fingerprints of another version.
+func (p *prevSources) add(
+	fingerprints []database.SourceFingerprint,
+	loudness []database.SourceLoudness,
+	waveforms []database.SourceWaveform,
+) int {
+	stale := 0
+	for _, f := range fingerprints {
+		if f.Version != sourceAnalysisVersion {
+			stale += 1
+			continue
+		}
+		p.fingerprints[f.Sid] = f
+	}
+	for _, l := range loudness {
+		if l.Version == sourceAnalysisVersion {
+			p.loudness[l.Sid] = l
+		}
+	}
+	for _, w := range waveforms {
+		if w.Version == sourceAnalysisVersion {
+			p.waveforms[w.Sid] = w
+		}
+	}
+	return stale
+}
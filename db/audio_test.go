@@ -0,0 +1,115 @@
+package db
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"dekr0/hd2_audio_db/fingerprint"
+	database "dekr0/hd2_audio_db/internal/complete"
+)
+
+func TestSourceAudio(t *testing.T) {
+	le := binary.LittleEndian
+	// Mono 48k 16 bits PCM with 9600 samples (200 ms)
+	w := []byte("RIFF\x00\x00\x00\x00WAVEfmt ")
+	w = le.AppendUint32(w, 16)
+	w = le.AppendUint16(w, 1)
+	w = le.AppendUint16(w, 1)
+	w = le.AppendUint32(w, 48000)
+	w = le.AppendUint32(w, 96000)
+	w = le.AppendUint16(w, 2)
+	w = le.AppendUint16(w, 16)
+	w = append(w, "data"...)
+	w = le.AppendUint32(w, 19200)
+
+	data := t.TempDir()
+	stream := append(make([]byte, 64), w...)
+	if err := os.WriteFile(filepath.Join(data, "a.stream"), stream, 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(data, "a"), make([]byte, 16), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	locationInsert := []database.InsertSourceLocationParams{
+		// Prefetched head in the main archive is out of range. It must not be
+		// picked over the stream.
+		{Sid: 1, Aid: "a", Part: PartMain, FileOffset: 0, Size: 1024, Prefetch: 1},
+		{Sid: 1, Aid: "a", Fid: 7, Tid: 9, Part: PartStream, FileOffset: 64, Size: int64(len(w)), Prefetch: 1},
+		{Sid: 2, Aid: "b", Part: PartStream, FileOffset: 0, Size: 16},
+	}
+	audioInsert, fingerprintInsert, loudnessInsert, waveformInsert, err := sourceAudioParams(
+		context.Background(), data, locationInsert, nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(audioInsert) != 1 {
+		t.Fatalf("expect 1 source, got %v", audioInsert)
+	}
+	a := audioInsert[0]
+	if a.Sid != 1 || a.Codec != "PCM" || a.Channels != 1 || a.SampleRate != 48000 ||
+	   a.SampleCount != 9600 || a.Duration != 0.2 {
+		t.Fatalf("unexpected source audio %v", a)
+	}
+	// Samples are missing so the fingerprint falls back to the payload and 
+	// loudness is not measured.
+	if len(fingerprintInsert) != 1 || fingerprintInsert[0].Kind != fingerprint.KindPayload {
+		t.Fatalf("unexpected source fingerprint %v", fingerprintInsert)
+	}
+	if len(loudnessInsert) != 0 || len(waveformInsert) != 0 {
+		t.Fatalf("unexpected source loudness %v or waveform %v", loudnessInsert, waveformInsert)
+	}
+
+	// The stream asset is unchanged since the previous build, so its rows are
+	// reused instead of computed.
+	// Rows of another analysis version are dropped.
+	prev := &prevSources{
+		unchanged: map[assetKey]struct{}{{"a", 7, 9}: {}},
+		fingerprints: make(map[int64]database.SourceFingerprint),
+		loudness: make(map[int64]database.SourceLoudness),
+		waveforms: make(map[int64]database.SourceWaveform),
+	}
+	stale := prev.add(
+		[]database.SourceFingerprint{
+			{Sid: 1, Kind: fingerprint.KindPCM, ContentHash: 42, Version: sourceAnalysisVersion},
+			{Sid: 2, Kind: fingerprint.KindPCM, ContentHash: 43},
+		},
+		[]database.SourceLoudness{
+			{Sid: 1, Integrated: -23, Version: sourceAnalysisVersion},
+			{Sid: 2, Integrated: -20},
+		},
+		[]database.SourceWaveform{
+			{Sid: 1, Channels: 1, Version: sourceAnalysisVersion},
+			{Sid: 2, Channels: 1},
+		},
+	)
+	if stale != 1 || len(prev.fingerprints) != 1 || len(prev.loudness) != 1 || len(prev.waveforms) != 1 {
+		t.Fatalf("expect stale rows to be dropped, got %d stale %v", stale, prev)
+	}
+	_, fingerprintInsert, loudnessInsert, waveformInsert, err = sourceAudioParams(
+		context.Background(), data, locationInsert, prev,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fingerprintInsert) != 1 || fingerprintInsert[0].ContentHash != 42 ||
+	   len(loudnessInsert) != 1 || loudnessInsert[0].Integrated != -23 ||
+	   len(waveformInsert) != 1 || waveformInsert[0].Version != sourceAnalysisVersion {
+		t.Fatalf("expect rows of previous build, got %v %v %v", fingerprintInsert, loudnessInsert, waveformInsert)
+	}
+
+	delete(prev.unchanged, assetKey{"a", 7, 9})
+	_, fingerprintInsert, _, _, err = sourceAudioParams(
+		context.Background(), data, locationInsert, prev,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fingerprintInsert) != 1 || fingerprintInsert[0].Kind != fingerprint.KindPayload {
+		t.Fatalf("expect changed source to be computed, got %v", fingerprintInsert)
+	}
+}
@@ -0,0 +1,149 @@
+package db
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	wio "dekr0/hd2_audio_db/io"
+	"dekr0/hd2_audio_db/parser"
+	"dekr0/hd2_audio_db/wem"
+)
+
+// Formats of extracted sources
+const (
+	SourceFormatWem  = "wem"
+	SourceFormatOgg  = "ogg"
+	SourceFormatWav  = "wav"
+	SourceFormatOpus = "opus"
+)
+
+// Format of sources written by ExtractSource.
+var SourceFormat = SourceFormatWem
+
+// Path of the packed codebook library (e.g. `packed_codebooks_aoTuV_603.bin`) 
+// for Wwise Vorbis with external codebooks. Empty means the library embedded 
+// in `wem` (see wem.DefaultCodebookLibrary).
+var CodebookPath = ""
+
+// Whether Wwise Vorbis sources store codebooks inline instead of referencing 
+// a codebook library. CodebookPath is ignored if set.
+var InlineCodebooks = false
+
+// readRange reads `size` bytes starting at `offset` of file `src`.
+func readRange(src string, offset int64, size int64) ([]byte, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	b := make([]byte, size)
+	if _, err := io.ReadFull(io.NewSectionReader(f, offset, size), b); err != nil {
+		return nil, fmt.Errorf(
+			"%s: failed to read %d bytes at offset %d: %w", src, size, offset, err,
+		)
+	}
+	return b, nil
+}
+
+// convertSource converts WEM `b` into `format` and writes it into a newly 
+// created file `p`.
+func convertSource(b []byte, format string, p string) error {
+	w, err := wem.Parse(b)
+	if err != nil {
+		return err
+	}
+
+	var opt wem.OggOptions
+	switch format {
+	case SourceFormatOgg:
+		if w.Codec != wem.CodecVorbis {
+			return fmt.Errorf("Cannot convert %s into ogg", w.Codec)
+		}
+		switch {
+		case InlineCodebooks:
+			opt.InlineCodebooks = true
+		case CodebookPath == "":
+			if opt.Codebooks, err = wem.DefaultCodebookLibrary(); err != nil {
+				return err
+			}
+		default:
+			if opt.Codebooks, err = wem.LoadCodebookLibrary(CodebookPath); err != nil {
+				return err
+			}
+		}
+	case SourceFormatWav:
+		if w.Codec != wem.CodecPCM && w.Codec != wem.CodecIMA {
+			return fmt.Errorf("Cannot convert %s into wav", w.Codec)
+		}
+	case SourceFormatOpus:
+		if w.Codec != wem.CodecOpus {
+			return fmt.Errorf("Cannot convert %s into opus", w.Codec)
+		}
+	default:
+		return fmt.Errorf("Unknown source format %s", format)
+	}
+
+	o, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	defer o.Close()
+	switch format {
+	case SourceFormatWav:
+		err = wem.ToWAV(w, b, o)
+	case SourceFormatOpus:
+		err = wem.ToOggOpus(w, b, o)
+	default:
+		err = wem.ToOgg(w, b, o, opt)
+	}
+	if err != nil {
+		o.Close()
+		os.Remove(p)
+		return err
+	}
+	return o.Close()
+}
+
+// convertMedia converts every source embedded in sound bank `bank` (without 
+// the 16 bytes header of the game) into SourceFormat and writes them into 
+// folder `dest` as `<sid>.<SourceFormat>`. Nothing is written if SourceFormat 
+// is wem since embedded sources are already in the sound bank. A source that 
+// cannot be converted, e.g., the prefetched head of a streamed source, is 
+// reported and skipped. It returns the number of converted sources.
+func convertMedia(bank []byte, dest string) (int, error) {
+	if SourceFormat == SourceFormatWem {
+		return 0, nil
+	}
+	r := wio.NewInPlaceReader(bank, wio.ByteOrder)
+	idx, err := parser.ParseMediaIndex(r, uint(len(bank)))
+	if err != nil {
+		return 0, err
+	}
+	if len(idx.Media) == 0 {
+		return 0, nil
+	}
+	if err := os.MkdirAll(dest, 0777); err != nil {
+		return 0, err
+	}
+
+	converted := 0
+	for _, m := range idx.Media {
+		begin := idx.DataOffset + uint(m.Offset)
+		p := filepath.Join(dest, fmt.Sprintf("%d.%s", m.SourceID, SourceFormat))
+		if err := convertSource(bank[begin:begin + uint(m.Size)], SourceFormat, p); err != nil {
+			slog.Warn(
+				"Failed to convert embedded source",
+				"sid", m.SourceID,
+				"format", SourceFormat,
+				"error", err,
+			)
+			continue
+		}
+		converted += 1
+	}
+	return converted, nil
+}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConvertMedia(t *testing.T) {
+	le := binary.LittleEndian
+	wav := testPCMWav([]int16{0, 100, -100, 0})
+	broken := []byte("RIFF")
+
+	bank := []byte("BKHD")
+	bank = le.AppendUint32(bank, 12)
+	bank = append(bank, make([]byte, 12)...)
+	bank = append(bank, "DIDX"...)
+	bank = le.AppendUint32(bank, 24)
+	for _, m := range [][3]uint32{{7, 0, uint32(len(wav))}, {8, uint32(len(wav)), 4}} {
+		for _, v := range m {
+			bank = le.AppendUint32(bank, v)
+		}
+	}
+	bank = append(bank, "DATA"...)
+	bank = le.AppendUint32(bank, uint32(len(wav) + len(broken)))
+	bank = append(bank, wav...)
+	bank = append(bank, broken...)
+
+	defer func(format string) { SourceFormat = format }(SourceFormat)
+	dest := filepath.Join(t.TempDir(), "bank")
+
+	SourceFormat = SourceFormatWem
+	if n, err := convertMedia(bank, dest); err != nil || n != 0 {
+		t.Fatalf("expect nothing converted into wem, got %d (%v)", n, err)
+	}
+
+	SourceFormat = SourceFormatWav
+	n, err := convertMedia(bank, dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("expect 1 converted source, got %d", n)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "7.wav")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "8.wav")); !os.IsNotExist(err) {
+		t.Fatalf("expect broken source to be skipped, got %v", err)
+	}
+}
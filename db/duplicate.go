@@ -0,0 +1,182 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+
+	"dekr0/hd2_audio_db/fingerprint"
+	database "dekr0/hd2_audio_db/internal/complete"
+)
+
+// Minimum similarity for two fingerprints to be near-identical duplicates.
+var DuplicateThreshold = 0.9
+
+// duplicateParams groups sources in `fingerprintInsert` that have the same
+// content hash or near-identical fingerprints of the same kind. Only groups of
+// two or more sources are returned.
+func duplicateParams(
+	fingerprintInsert []database.InsertSourceFingerprintParams,
+) ([]database.InsertSourceDuplicateParams, error) {
+	parent := make([]int, len(fingerprintInsert))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	union := func(i int, j int) {
+		ri, rj := find(i), find(j)
+		if ri != rj {
+			parent[max(ri, rj)] = min(ri, rj)
+		}
+	}
+
+	fps := make([]fingerprint.Fingerprint, len(fingerprintInsert))
+	byHash := make(map[int64]int)
+	indices := map[string]*fingerprint.Index{
+		fingerprint.KindPCM: fingerprint.NewIndex(),
+		fingerprint.KindPayload: fingerprint.NewIndex(),
+	}
+	for i, f := range fingerprintInsert {
+		fp, err := fingerprint.Parse(f.Fingerprint)
+		if err != nil {
+			return nil, fmt.Errorf("Source %d: %w", f.Sid, err)
+		}
+		fps[i] = fp
+		if len(fp) == 0 {
+			continue
+		}
+		if j, in := byHash[f.ContentHash]; in {
+			union(i, j)
+		} else {
+			byHash[f.ContentHash] = i
+		}
+		if x, in := indices[f.Kind]; in {
+			x.Add(i, fp)
+		}
+	}
+	for i, f := range fingerprintInsert {
+		x, in := indices[f.Kind]
+		if !in || len(fps[i]) == 0 {
+			continue
+		}
+		for _, j := range x.Candidates(fps[i], matchCandidates) {
+			if j == i || find(i) == find(j) || fingerprintInsert[j].Kind != f.Kind {
+				continue
+			}
+			if fingerprint.Similarity(fps[i], fps[j]) >= DuplicateThreshold {
+				union(i, j)
+			}
+		}
+	}
+
+	groups := make(map[int][]int)
+	for i := range fingerprintInsert {
+		r := find(i)
+		groups[r] = append(groups[r], i)
+	}
+	duplicateInsert := []database.InsertSourceDuplicateParams{}
+	for _, members := range groups {
+		if len(members) < 2 {
+			continue
+		}
+		rep := slices.MinFunc(members, func(a int, b int) int {
+			return cmp.Compare(fingerprintInsert[a].Sid, fingerprintInsert[b].Sid)
+		})
+		method := MatchHash
+		for _, m := range members {
+			if fingerprintInsert[m].ContentHash != fingerprintInsert[rep].ContentHash {
+				method = MatchFingerprint
+				break
+			}
+		}
+		for _, m := range members {
+			confidence := 1.0
+			if fingerprintInsert[m].ContentHash != fingerprintInsert[rep].ContentHash {
+				confidence = fingerprint.Similarity(fps[m], fps[rep])
+			}
+			duplicateInsert = append(duplicateInsert, database.InsertSourceDuplicateParams{
+				Gid: fingerprintInsert[rep].Sid,
+				Sid: fingerprintInsert[m].Sid,
+				Method: method,
+				Confidence: confidence,
+			})
+		}
+	}
+	slices.SortFunc(duplicateInsert, func(a, b database.InsertSourceDuplicateParams) int {
+		if a.Gid != b.Gid {
+			return cmp.Compare(a.Gid, b.Gid)
+		}
+		return cmp.Compare(a.Sid, b.Sid)
+	})
+	return duplicateInsert, nil
+}
+
+type DuplicateSource struct {
+	Sid        int64    `json:"sid"`
+	Confidence float64  `json:"confidence"`
+	Soundbanks []string `json:"soundbanks"`
+}
+
+type DuplicateGroup struct {
+	Gid     int64             `json:"gid"`
+	Method  string            `json:"method"`
+	Sources []DuplicateSource `json:"sources"`
+}
+
+type DuplicateReport struct {
+	Groups     int              `json:"groups"`
+	Sources    int              `json:"sources"`
+	Duplicates []DuplicateGroup `json:"duplicates"`
+}
+
+// WriteDuplicates writes every group in `source_duplicate` table, along with
+// the sound banks that use each source, into `w` as JSON.
+func WriteDuplicates(ctx context.Context, w io.Writer) error {
+	c, err := conn()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	q := database.New(c)
+	rows, err := q.GetAllSourceDuplicate(ctx)
+	if err != nil {
+		return err
+	}
+
+	report := DuplicateReport{Duplicates: []DuplicateGroup{}}
+	for _, row := range rows {
+		if len(report.Duplicates) == 0 || report.Duplicates[len(report.Duplicates) - 1].Gid != row.Gid {
+			report.Duplicates = append(report.Duplicates, DuplicateGroup{
+				Gid: row.Gid, Method: row.Method, Sources: []DuplicateSource{},
+			})
+		}
+		banks, err := q.GetSourceSoundbankPath(ctx, row.Sid)
+		if err != nil {
+			return err
+		}
+		if banks == nil {
+			banks = []string{}
+		}
+		g := &report.Duplicates[len(report.Duplicates) - 1]
+		g.Sources = append(g.Sources, DuplicateSource{row.Sid, row.Confidence, banks})
+	}
+	report.Groups = len(report.Duplicates)
+	report.Sources = len(rows)
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(&report); err != nil {
+		return err
+	}
+	return c.Close()
+}
@@ -0,0 +1,61 @@
+package db
+
+import (
+	"testing"
+
+	"dekr0/hd2_audio_db/fingerprint"
+	database "dekr0/hd2_audio_db/internal/complete"
+)
+
+func TestDuplicateParams(t *testing.T) {
+	tail := testTone(600, 1)
+	louder := make([]int16, len(tail))
+	for i := range tail {
+		louder[i] = tail[i] / 2 * 3
+	}
+	fp := func(sid int64, hash int64, samples []int16) database.InsertSourceFingerprintParams {
+		f := testFingerprint(sid, hash, samples)
+		return database.InsertSourceFingerprintParams{
+			Sid: f.Sid, Kind: f.Kind, ContentHash: f.ContentHash, Fingerprint: f.Fingerprint,
+		}
+	}
+	payload := fingerprint.FromPayload([]byte("identical payload")).Bytes()
+	fingerprintInsert := []database.InsertSourceFingerprintParams{
+		fp(9, 1, tail),
+		fp(3, 2, louder),
+		fp(5, 1, tail),
+		fp(4, 4, testTone(300, 2)),
+		{Sid: 8, Kind: fingerprint.KindPayload, ContentHash: 8, Fingerprint: payload},
+		{Sid: 7, Kind: fingerprint.KindPayload, ContentHash: 8, Fingerprint: payload},
+		// Empty sources share the hash of nothing but are not duplicates.
+		{Sid: 10, Kind: fingerprint.KindPayload, ContentHash: 0, Fingerprint: []byte{}},
+		{Sid: 11, Kind: fingerprint.KindPayload, ContentHash: 0, Fingerprint: []byte{}},
+	}
+	duplicateInsert, err := duplicateParams(fingerprintInsert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []struct {
+		gid    int64
+		sid    int64
+		method string
+	}{
+		{3, 3, MatchFingerprint},
+		{3, 5, MatchFingerprint},
+		{3, 9, MatchFingerprint},
+		{7, 7, MatchHash},
+		{7, 8, MatchHash},
+	}
+	if len(duplicateInsert) != len(expect) {
+		t.Fatalf("expect %d duplicates, got %v", len(expect), duplicateInsert)
+	}
+	for i, d := range duplicateInsert {
+		e := expect[i]
+		if d.Gid != e.gid || d.Sid != e.sid || d.Method != e.method {
+			t.Fatalf("expect %v, got %v", e, d)
+		}
+		if d.Confidence < DuplicateThreshold || d.Confidence > 1 {
+			t.Fatalf("unexpected confidence %v", d)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	database "dekr0/hd2_audio_db/internal/complete"
+	"dekr0/hd2_audio_db/parser"
+)
+
+// ExtractAsset extracts the main data, stream part and GPU resource part of
+// an asset specified by (aid, fid, tid) into `dest`. Offsets and sizes are
+// taken from the `asset` table so the ToC of the archive is not parsed again.
+// Parts with zero size are skipped. Main data is written as is, i.e.,
+// including whatever header the game put in front of it. If SourceFormat is 
+// not wem, the stream part of a WwiseStream asset is converted into 
+// `<name>.<SourceFormat>`, and sources embedded in a sound bank are converted 
+// into folder `<name>` (see convertMedia).
+func ExtractAsset(
+	ctx context.Context,
+	data string,
+	dest string,
+	aid string,
+	fid uint64,
+	tid uint64,
+) error {
+	stat, err := os.Lstat(dest)
+	if err != nil {
+		if os.IsNotExist(err) {
+			if err := os.Mkdir(dest, 0777); err != nil {
+				return err
+			}
+		}
+	} else if !stat.IsDir() {
+		return fmt.Errorf("%s is a file", dest)
+	}
+
+	c, err := conn()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	q := database.New(c)
+	asset, err := q.GetAsset(ctx, database.GetAssetParams{
+		Aid: aid,
+		Fid: int64(fid),
+		Tid: int64(tid),
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf(
+				"Asset (aid %s, fid %d, tid %d) is not in the database",
+				aid, fid, tid,
+			)
+		}
+		return err
+	}
+	c.Close()
+
+	return extractAsset(ctx, data, dest, &asset)
+}
+
+func extractAsset(
+	ctx context.Context,
+	data string,
+	dest string,
+	a *database.Asset,
+) error {
+	name := fmt.Sprintf("%s_%d_%d", a.Aid, uint64(a.Fid), uint64(a.Tid))
+	parts := []struct {
+		src    string
+		ext    string
+		offset int64
+		size   int64
+	}{
+		{a.Aid, ".main", a.DataOffset, a.DataSize},
+		{a.Aid + ".stream", ".stream", a.StreamFileOffset, a.StreamSize},
+		{a.Aid + ".gpu_resources", ".gpu_resources", a.GpuRsrcOffset, a.GpuRsrcSize},
+	}
+	for _, part := range parts {
+		select {
+		case <- ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		if part.size <= 0 {
+			continue
+		}
+		var err error
+		if part.ext == ".stream" && 
+		   a.Tid == int64(parser.AssetTypeWwiseStream) &&
+		   SourceFormat != SourceFormatWem {
+			// The stream part of a WwiseStream asset is a whole WEM
+			var b []byte
+			b, err = readRange(filepath.Join(data, part.src), part.offset, part.size)
+			if err == nil {
+				err = convertSource(
+					b, SourceFormat, filepath.Join(dest, name + "." + SourceFormat),
+				)
+			}
+		} else {
+			err = extractRange(
+				filepath.Join(data, part.src),
+				part.offset,
+				part.size,
+				filepath.Join(dest, name + part.ext),
+			)
+		}
+		if err != nil {
+			slog.Error(
+				"Failed to extract asset",
+				"aid", a.Aid,
+				"fid", uint64(a.Fid),
+				"tid", uint64(a.Tid),
+				"part", part.ext,
+				"error", err,
+			)
+			return err
+		}
+	}
+
+	if a.Tid != int64(parser.AssetTypeSoundBank) || 
+	   SourceFormat == SourceFormatWem || 
+	   a.DataSize < 16 {
+		return nil
+	}
+	b, err := readRange(filepath.Join(data, a.Aid), a.DataOffset, a.DataSize)
+	if err != nil {
+		return err
+	}
+	_, err = convertMedia(b[16:], filepath.Join(dest, name))
+	return err
+}
+
+// extractRange copies `size` bytes starting at `offset` of file `src` into a
+// newly created file `p`. It fails if `src` does not contain the full range
+// instead of producing a truncated output.
+func extractRange(src string, offset int64, size int64, p string) error {
+	i, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer i.Close()
+
+	o, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	defer o.Close()
+
+	n, err := io.Copy(o, io.NewSectionReader(i, offset, size))
+	if err != nil {
+		return err
+	}
+	if n != size {
+		return fmt.Errorf(
+			"%s: expect %d bytes at offset %d, only %d bytes available",
+			src, size, offset, n,
+		)
+	}
+	return o.Close()
+}
@@ -0,0 +1,32 @@
+package db
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExtractRange(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "archive")
+	if err := os.WriteFile(src, []byte("0123456789"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	p := filepath.Join(dir, "out")
+	if err := extractRange(src, 2, 5, p); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, []byte("23456")) {
+		t.Fatalf("expect 23456, got %s", b)
+	}
+
+	if err := extractRange(src, 8, 5, p); err == nil {
+		t.Fatal("expect error when range exceeds the end of file")
+	}
+}
@@ -0,0 +1,189 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"dekr0/hd2_audio_db/fingerprint"
+	database "dekr0/hd2_audio_db/internal/complete"
+	"dekr0/hd2_audio_db/wem"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// Minimum confidence for a fingerprint match between two builds.
+var MatchThreshold = 0.6
+
+// Number of candidates compared per source when matching fingerprints.
+const matchCandidates = 16
+
+// How an old source ID is mapped to a new source ID
+const (
+	MatchHash        = "hash"
+	MatchFingerprint = "fingerprint"
+)
+
+// fingerprintParams computes the fingerprint of WEM `b` with header `w` from 
+// its decoded `samples`. Sources that cannot be decoded (nil `samples`) use 
+// the raw `data` chunk instead.
+func fingerprintParams(
+	sid int64, w *wem.Wem, b []byte, samples []int16,
+) database.InsertSourceFingerprintParams {
+	payload := b[min(uint64(w.DataOffset), uint64(len(b))):
+	             min(uint64(w.DataOffset) + uint64(w.DataSize), uint64(len(b)))]
+	f := database.InsertSourceFingerprintParams{
+		Sid: sid,
+		Kind: fingerprint.KindPayload,
+		ContentHash: int64(xxhash.Sum64(payload)),
+		Version: sourceAnalysisVersion,
+	}
+	if samples != nil {
+		f.Kind = fingerprint.KindPCM
+		f.Fingerprint = fingerprint.FromPCM(
+			samples, int(w.Channels), int(w.SampleRate),
+		).Bytes()
+	} else {
+		f.Fingerprint = fingerprint.FromPayload(payload).Bytes()
+	}
+	return f
+}
+
+type sourceMatch struct {
+	old        int64
+	new        int64 // 0 if there is no match
+	confidence float64
+	method     string
+}
+
+// matchSources maps every source in `prev` to a source in `curr`. A source
+// with the same content hash is a match with confidence 1, the same source ID
+// first. Otherwise, the most similar fingerprint of the same kind above
+// MatchThreshold is the match.
+func matchSources(
+	prev []database.SourceFingerprint, curr []database.SourceFingerprint,
+) ([]sourceMatch, error) {
+	byHash := make(map[int64][]int64)
+	bySid := make(map[int64]*database.SourceFingerprint, len(curr))
+	fps := make([]fingerprint.Fingerprint, len(curr))
+	indices := map[string]*fingerprint.Index{
+		fingerprint.KindPCM: fingerprint.NewIndex(),
+		fingerprint.KindPayload: fingerprint.NewIndex(),
+	}
+	for i := range curr {
+		c := &curr[i]
+		f, err := fingerprint.Parse(c.Fingerprint)
+		if err != nil {
+			return nil, fmt.Errorf("Source %d: %w", c.Sid, err)
+		}
+		fps[i] = f
+		byHash[c.ContentHash] = append(byHash[c.ContentHash], c.Sid)
+		bySid[c.Sid] = c
+		if x, in := indices[c.Kind]; in {
+			x.Add(i, f)
+		}
+	}
+
+	matches := make([]sourceMatch, 0, len(prev))
+	for _, p := range prev {
+		m := sourceMatch{old: p.Sid}
+		if c, in := bySid[p.Sid]; in && c.ContentHash == p.ContentHash {
+			m.new, m.confidence, m.method = p.Sid, 1, MatchHash
+			matches = append(matches, m)
+			continue
+		}
+		if sids, in := byHash[p.ContentHash]; in {
+			m.new, m.confidence, m.method = sids[0], 1, MatchHash
+			matches = append(matches, m)
+			continue
+		}
+
+		f, err := fingerprint.Parse(p.Fingerprint)
+		if err != nil {
+			return nil, fmt.Errorf("Source %d of previous build: %w", p.Sid, err)
+		}
+		x, in := indices[p.Kind]
+		if !in {
+			matches = append(matches, m)
+			continue
+		}
+		for _, i := range x.Candidates(f, matchCandidates) {
+			if curr[i].Kind != p.Kind {
+				continue
+			}
+			s := fingerprint.Similarity(f, fps[i])
+			if s >= MatchThreshold && s > m.confidence {
+				m.new, m.confidence, m.method = curr[i].Sid, s, MatchFingerprint
+			}
+		}
+		matches = append(matches, m)
+	}
+	return matches, nil
+}
+
+// MatchSources maps source IDs of the previous build (see prevDBString) to
+// source IDs of the current build and writes `old_sid,new_sid,confidence,
+// method` as CSV into `out`. `new_sid` and `method` are empty for sources
+// without a match.
+func MatchSources(ctx context.Context, out io.Writer) error {
+	p := prevDBString()
+	if _, err := os.Stat(p); err != nil {
+		return fmt.Errorf("No previous build at %s: %w", p, err)
+	}
+	c, err := sql.Open("sqlite3", p)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	prev, err := database.New(c).GetAllSourceFingerprint(ctx)
+	if err != nil {
+		return fmt.Errorf("Previous build has no source fingerprints: %w", err)
+	}
+	c.Close()
+
+	c, err = conn()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	curr, err := database.New(c).GetAllSourceFingerprint(ctx)
+	if err != nil {
+		return err
+	}
+	c.Close()
+
+	matches, err := matchSources(prev, curr)
+	if err != nil {
+		return err
+	}
+
+	w := csv.NewWriter(out)
+	w.Write([]string{"old_sid", "new_sid", "confidence", "method"})
+	matched := 0
+	for _, m := range matches {
+		newSid := ""
+		if m.method != "" {
+			newSid = strconv.FormatInt(m.new, 10)
+			matched += 1
+		}
+		w.Write([]string{
+			strconv.FormatInt(m.old, 10),
+			newSid,
+			strconv.FormatFloat(m.confidence, 'f', 3, 64),
+			m.method,
+		})
+	}
+	w.Flush()
+	slog.Info(
+		"Matched sources with previous build",
+		"path", p,
+		"sources", len(matches),
+		"matched", matched,
+	)
+	return w.Error()
+}
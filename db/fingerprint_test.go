@@ -0,0 +1,72 @@
+package db
+
+import (
+	"math"
+	"testing"
+
+	"dekr0/hd2_audio_db/fingerprint"
+	database "dekr0/hd2_audio_db/internal/complete"
+)
+
+func testTone(freq float64, seconds float64) []int16 {
+	samples := make([]int16, int(seconds * 24000))
+	for i := range samples {
+		// Pitch glides so that consecutive frames differ.
+		f := freq * (1 + float64(i) / float64(len(samples)))
+		samples[i] = int16(16000 * math.Sin(2 * math.Pi * f * float64(i) / 24000))
+	}
+	return samples
+}
+
+func testFingerprint(sid int64, hash int64, samples []int16) database.SourceFingerprint {
+	return database.SourceFingerprint{
+		Sid: sid,
+		Kind: fingerprint.KindPCM,
+		ContentHash: hash,
+		Fingerprint: fingerprint.FromPCM(samples, 1, 24000).Bytes(),
+	}
+}
+
+func TestMatchSources(t *testing.T) {
+	tone := testTone(400, 1)
+	quiet := make([]int16, len(tone))
+	for i := range tone {
+		quiet[i] = tone[i] / 2
+	}
+	prev := []database.SourceFingerprint{
+		testFingerprint(1, 10, testTone(300, 1)),
+		testFingerprint(2, 20, testTone(500, 1)),
+		testFingerprint(3, 30, tone),
+		testFingerprint(4, 40, testTone(700, 2)),
+	}
+	curr := []database.SourceFingerprint{
+		testFingerprint(1, 10, testTone(300, 1)), // unchanged
+		testFingerprint(5, 20, testTone(500, 1)), // new ID
+		testFingerprint(6, 31, quiet), // re-imported at a lower level
+		testFingerprint(7, 70, testTone(1500, 3)),
+	}
+	matches, err := matchSources(prev, curr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []struct {
+		new    int64
+		method string
+	}{
+		{1, MatchHash},
+		{5, MatchHash},
+		{6, MatchFingerprint},
+		{0, ""},
+	}
+	if len(matches) != len(expect) {
+		t.Fatalf("expect %d matches, got %v", len(expect), matches)
+	}
+	for i, m := range matches {
+		if m.old != prev[i].Sid || m.new != expect[i].new || m.method != expect[i].method {
+			t.Fatalf("source %d: unexpected match %v", prev[i].Sid, m)
+		}
+	}
+	if matches[2].confidence < MatchThreshold || matches[2].confidence > 1 {
+		t.Fatalf("unexpected confidence %f", matches[2].confidence)
+	}
+}
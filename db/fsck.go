@@ -0,0 +1,251 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	wio "dekr0/hd2_audio_db/io"
+	"dekr0/hd2_audio_db/parser"
+)
+
+const (
+	FsckToc           = "toc"
+	FsckMissingFile   = "missing_file"
+	FsckDataRange     = "data_range"
+	FsckStreamRange   = "stream_range"
+	FsckGPURsrcRange  = "gpu_resources_range"
+	FsckTypeCount     = "type_count"
+	FsckBankMagic     = "bank_magic"
+	FsckDependency    = "wwise_dependency"
+)
+
+type FsckProblem struct {
+	Aid    string `json:"aid"`
+	Fid    uint64 `json:"fid,omitempty"`
+	Tid    uint64 `json:"tid,omitempty"`
+	Kind   string `json:"kind"`
+	Detail string `json:"detail"`
+}
+
+type FsckReport struct {
+	Archives int           `json:"archives"`
+	Assets   int           `json:"assets"`
+	Problems []FsckProblem `json:"problems"`
+}
+
+// Fsck verifies every archive in `data`:
+// - the ToC can be parsed;
+// - data, stream and GPU resource ranges of every asset lie within the main
+// archive, `.stream` and `.gpu_resources` file respectively;
+// - counts in AssetTypeCnts match the asset headers;
+// - every sound bank starts with BKHD after the 16 bytes header of the game;
+// - every wwise dependency holds a valid path string.
+// The report is written into `w` as JSON. Problems are not errors. The caller
+// decides what to do with a report that has problems.
+func Fsck(ctx context.Context, data string, w io.Writer) (*FsckReport, error) {
+	f, err := os.Open(data)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	report := FsckReport{Problems: []FsckProblem{}}
+	var m sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, MaxArchiveReder)
+	for {
+		entries, err := f.ReadDir(1024)
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !isArchive(entry) { continue }
+
+			select {
+			case <- ctx.Done():
+				wg.Wait()
+				return nil, ctx.Err()
+			case sem <- struct{}{}:
+				wg.Add(1)
+				go func(aid string) {
+					defer wg.Done()
+					defer func() { <- sem }()
+					assets, problems := fsckArchive(data, aid)
+					m.Lock()
+					report.Archives += 1
+					report.Assets += assets
+					report.Problems = append(report.Problems, problems...)
+					m.Unlock()
+				}(entry.Name())
+			}
+		}
+	}
+	wg.Wait()
+
+	slices.SortStableFunc(report.Problems, func(a, b FsckProblem) int {
+		return strings.Compare(a.Aid, b.Aid)
+	})
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(&report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// fsckArchive checks archive `aid` and returns the number of assets and the
+// problems found. It never panics. A ToC that cannot be parsed is reported
+// as a problem.
+func fsckArchive(data string, aid string) (assets int, problems []FsckProblem) {
+	problems = []FsckProblem{}
+	report := func(h *parser.AssetHeader, kind string, format string, args ...any) {
+		p := FsckProblem{Aid: aid, Kind: kind, Detail: fmt.Sprintf(format, args...)}
+		if h != nil {
+			p.Fid = h.FileID
+			p.Tid = h.TypeID
+		}
+		problems = append(problems, p)
+	}
+
+	p := filepath.Join(data, aid)
+	m, err := wio.OpenMmapReader(p)
+	if err != nil {
+		report(nil, FsckToc, "%v", err)
+		return 0, problems
+	}
+	defer m.Close()
+
+	a := parser.Archive{}
+	if err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("%v", r)
+			}
+		}()
+		parser.ParseArchiveMmap(&a, m)
+		return nil
+	}(); err != nil {
+		report(nil, FsckToc, "Failed to parse ToC: %v", err)
+		return 0, problems
+	}
+
+	mainSize := uint64(m.Size())
+	streamSize, streamErr := fileSize(p + ".stream")
+	gpuRsrcSize, gpuRsrcErr := fileSize(p + ".gpu_resources")
+
+	cnts := make(map[uint64]uint64, a.NumTypes)
+	for i := range a.Headers {
+		h := &a.Headers[i]
+		cnts[h.TypeID] += 1
+
+		if !inRange(h.DataOffset, uint64(h.DataSize), mainSize) {
+			report(h, FsckDataRange,
+				"[%d, +%d) exceeds archive size %d",
+				h.DataOffset, h.DataSize, mainSize)
+		}
+		if h.StreamSize > 0 {
+			if streamErr != nil {
+				report(h, FsckMissingFile, "%v", streamErr)
+			} else if !inRange(h.StreamOffset, uint64(h.StreamSize), streamSize) {
+				report(h, FsckStreamRange,
+					"[%d, +%d) exceeds stream file size %d",
+					h.StreamOffset, h.StreamSize, streamSize)
+			}
+		}
+		if h.GPURsrcSize > 0 {
+			if gpuRsrcErr != nil {
+				report(h, FsckMissingFile, "%v", gpuRsrcErr)
+			} else if !inRange(h.GPURsrcOffset, uint64(h.GPURsrcSize), gpuRsrcSize) {
+				report(h, FsckGPURsrcRange,
+					"[%d, +%d) exceeds GPU resource file size %d",
+					h.GPURsrcOffset, h.GPURsrcSize, gpuRsrcSize)
+			}
+		}
+	}
+
+	var total uint64 = 0
+	for _, cnt := range a.AssetTypeCnts {
+		total += cnt.Num
+		if cnts[cnt.Type] != cnt.Num {
+			report(nil, FsckTypeCount,
+				"type %d: expect %d assets, found %d",
+				cnt.Type, cnt.Num, cnts[cnt.Type])
+		}
+	}
+	if total != uint64(a.NumFiles) {
+		report(nil, FsckTypeCount,
+			"type counts sum up to %d, archive has %d assets", total, a.NumFiles)
+	}
+
+	for _, i := range a.SoundBnks {
+		h := &a.Headers[i]
+		if !inRange(h.DataOffset, uint64(h.DataSize), mainSize) {
+			continue
+		}
+		if h.DataSize < 16 + 4 {
+			report(h, FsckBankMagic, "sound bank is too small (%d bytes)", h.DataSize)
+			continue
+		}
+		magic := m.SliceUnsafe(uint(h.DataOffset + 16), 4)
+		if !bytes.Equal(magic, []byte{'B', 'K', 'H', 'D'}) {
+			report(h, FsckBankMagic, "expect BKHD, found %q", magic)
+		}
+	}
+
+	for _, i := range a.Deps {
+		h := &a.Headers[i]
+		if !inRange(h.DataOffset, uint64(h.DataSize), mainSize) {
+			continue
+		}
+		if err := checkDependency(
+			m.SliceUnsafe(uint(h.DataOffset), uint(h.DataSize)),
+		); err != nil {
+			report(h, FsckDependency, "%v", err)
+		}
+	}
+
+	return len(a.Headers), problems
+}
+
+// checkDependency checks that the payload of a wwise dependency can be parsed 
+// and holds a non-empty printable path.
+func checkDependency(data []byte) error {
+	d, err := parser.ParseWwiseDependency(data)
+	if err != nil {
+		return err
+	}
+	if len(d.Path) == 0 {
+		return fmt.Errorf("path is empty")
+	}
+	if i := strings.IndexFunc(d.Path, func(r rune) bool {
+		return !unicode.IsPrint(r)
+	}); i >= 0 {
+		return fmt.Errorf("path has non printable character at %d", i)
+	}
+	return nil
+}
+
+func inRange(offset uint64, size uint64, total uint64) bool {
+	return offset <= total && size <= total - offset
+}
+
+func fileSize(p string) (uint64, error) {
+	stat, err := os.Stat(p)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(stat.Size()), nil
+}
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"dekr0/hd2_audio_db/parser"
+)
+
+func TestFsck(t *testing.T) {
+	data := t.TempDir()
+
+	dep := []byte{0, 0, 0, 0, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint32(dep[4:], 12)
+	dep = append(dep, []byte("wwise/a.bnk\x00")...)
+	payload := append(make([]byte, 16), []byte("BKHD")...)
+	payload = append(payload, dep...)
+
+	bank := parser.AssetHeader{
+		FileID: 1, TypeID: uint64(parser.AssetTypeSoundBank), DataSize: 20,
+	}
+	wdep := parser.AssetHeader{
+		FileID: 1, TypeID: uint64(parser.AssetTypeWwiseDependency),
+		DataOffset: 20, DataSize: uint32(len(dep)),
+	}
+	writeTestArchive(t, filepath.Join(data, "0000000000000001"),
+		[]parser.AssetHeader{bank, wdep}, payload)
+
+	b := bytes.Buffer{}
+	report, err := Fsck(context.Background(), data, &b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Archives != 1 || report.Assets != 2 || len(report.Problems) != 0 {
+		t.Fatalf("expect clean report, got %s", b.String())
+	}
+
+	// Corrupt: bad magic, bad dependency, range beyond the end, missing stream
+	bad := bytes.Clone(payload)
+	copy(bad[16:], "XXXX")
+	bad[20 + 8] = 0x01
+	stream := parser.AssetHeader{FileID: 2, TypeID: 42, StreamSize: 4}
+	tail := parser.AssetHeader{FileID: 3, TypeID: 42, DataOffset: 100, DataSize: 1}
+	writeTestArchive(t, filepath.Join(data, "0000000000000002"),
+		[]parser.AssetHeader{bank, wdep, stream, tail}, bad)
+	os.WriteFile(filepath.Join(data, "0000000000000003"), []byte("garbage"), 0666)
+
+	b.Reset()
+	report, err = Fsck(context.Background(), data, &b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := map[string]int{}
+	for _, p := range report.Problems {
+		kinds[p.Kind] += 1
+	}
+	for _, kind := range []string{
+		FsckToc, FsckBankMagic, FsckDependency, FsckMissingFile, FsckDataRange,
+	} {
+		if kinds[kind] != 1 {
+			t.Fatalf("expect one %s problem, got report %s", kind, b.String())
+		}
+	}
+}
+
+func TestCheckDependency(t *testing.T) {
+	dep := []byte{0, 0, 0, 0, 4, 0, 0, 0, 'a', '/', 'b', 0}
+	if err := checkDependency(dep); err != nil {
+		t.Fatal(err)
+	}
+	dep[4] = 5
+	if err := checkDependency(dep); err == nil {
+		t.Fatal("expect error on path length beyond payload")
+	}
+	dep[4] = 4
+	dep[9] = 0xff
+	if err := checkDependency(dep); err == nil {
+		t.Fatal("expect error on invalid UTF-8")
+	}
+}
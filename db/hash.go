@@ -0,0 +1,176 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"os"
+
+	database "dekr0/hd2_audio_db/internal/complete"
+	wio "dekr0/hd2_audio_db/io"
+	"dekr0/hd2_audio_db/parser"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// Whether Generate hashes the content of every asset. Hashing reads every 
+// byte of the data folder, including `.stream` and `.gpu_resources` files.
+var HashAssets = true
+
+type assetKey struct {
+	aid string
+	fid int64
+	tid int64
+}
+
+// hashAssets fills the data, stream and GPU resource hashes of `assetInsert`, 
+// which must be produced by assetParams from the same archive `a`. A part 
+// that is empty or cannot be read is left as 0. An asset with a part that is 
+// not empty but cannot be read is flagged with HashFailed. `ctx` is checked
+// before every asset. The remaining assets are left unhashed if it is done.
+func hashAssets(
+	ctx context.Context,
+	assetInsert []database.InsertAssetParams,
+	a *parser.Archive,
+	m *wio.MmapReader,
+	p string,
+) error {
+	stream := openPart(p + ".stream")
+	if stream != nil {
+		defer stream.Close()
+	}
+	gpuRsrc := openPart(p + ".gpu_resources")
+	if gpuRsrc != nil {
+		defer gpuRsrc.Close()
+	}
+
+	for i := range a.Headers {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		h := &a.Headers[i]
+		var ok, streamOk, gpuRsrcOk bool
+		assetInsert[i].DataHash, ok = hashPart(m, h.DataOffset, h.DataSize, p, h)
+		assetInsert[i].StreamHash, streamOk = hashPart(
+			stream, h.StreamOffset, h.StreamSize, p + ".stream", h,
+		)
+		assetInsert[i].GpuRsrcHash, gpuRsrcOk = hashPart(
+			gpuRsrc, h.GPURsrcOffset, h.GPURsrcSize, p + ".gpu_resources", h,
+		)
+		if !ok || !streamOk || !gpuRsrcOk {
+			assetInsert[i].HashFailed = 1
+		}
+	}
+	return nil
+}
+
+func openPart(p string) *wio.MmapReader {
+	m, err := wio.OpenMmapReader(p)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn("Failed to map archive", "path", p, "error", err)
+		}
+		return nil
+	}
+	return m
+}
+
+// hashPart returns the hash of a part and false if the part is not empty but 
+// cannot be read.
+func hashPart(
+	m *wio.MmapReader,
+	offset uint64,
+	size uint32,
+	p string,
+	h *parser.AssetHeader,
+) (int64, bool) {
+	if size == 0 {
+		return 0, true
+	}
+	if m == nil {
+		slog.Warn("Missing archive to hash", "path", p, "fid", h.FileID, "tid", h.TypeID)
+		return 0, false
+	}
+	data, err := m.Slice(uint(offset), uint(size))
+	if err != nil {
+		slog.Warn(
+			"Failed to read asset to hash",
+			"path", p,
+			"fid", h.FileID,
+			"tid", h.TypeID,
+			"error", err,
+		)
+		return 0, false
+	}
+	return int64(xxhash.Sum64(data)), true
+}
+
+// prevDBString returns the database of the previous build: 
+// `PREV_GOOSE_DBSTRING` (`PREV_VERSION` in configure.sh), or else 
+// `${GOOSE_DBSTRING}_backup`, which configure.sh moves the last run of the same 
+// build to before migration. See "Asset Hashes and Previous Build" in 
+// documentation.md.
+func prevDBString() string {
+	if p := os.Getenv("PREV_GOOSE_DBSTRING"); p != "" {
+		return p
+	}
+	return os.Getenv("GOOSE_DBSTRING") + "_backup"
+}
+
+// markUnchanged marks assets whose data, stream and GPU resource hashes all 
+// match the same asset (aid, fid, tid) in the database of the previous build. 
+// Nothing is marked if there is no previous build or it has no hashes. An 
+// asset that failed to hash in either build is never marked.
+func markUnchanged(ctx context.Context, assetInsert []database.InsertAssetParams) error {
+	if !HashAssets {
+		return nil
+	}
+	p := prevDBString()
+	if _, err := os.Stat(p); err != nil {
+		slog.Info("No previous build to compare asset hashes with", "path", p)
+		return nil
+	}
+	c, err := sql.Open("sqlite3", p)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	rows, err := database.New(c).GetAllAssetHash(ctx)
+	if err != nil {
+		slog.Warn("Previous build has no asset hashes", "path", p, "error", err)
+		return nil
+	}
+	prev := make(map[assetKey]database.GetAllAssetHashRow, len(rows))
+	for _, row := range rows {
+		prev[assetKey{row.Aid, row.Fid, row.Tid}] = row
+	}
+
+	unchanged := 0
+	for i := range assetInsert {
+		a := &assetInsert[i]
+		row, in := prev[assetKey{a.Aid, a.Fid, a.Tid}]
+		if in && sameHash(a, &row) {
+			a.Unchanged = 1
+			unchanged += 1
+		}
+	}
+	slog.Info(
+		"Compared asset hashes with previous build",
+		"path", p,
+		"assets", len(assetInsert),
+		"unchanged", unchanged,
+	)
+	return c.Close()
+}
+
+// sameHash reports whether asset `a` has the same hashes as `row` of the 
+// previous build. Hashes that failed in either build are never the same.
+func sameHash(a *database.InsertAssetParams, row *database.GetAllAssetHashRow) bool {
+	if a.HashFailed == 1 || row.HashFailed == 1 {
+		return false
+	}
+	return row.DataHash == a.DataHash &&
+	       row.StreamHash == a.StreamHash &&
+	       row.GpuRsrcHash == a.GpuRsrcHash
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	database "dekr0/hd2_audio_db/internal/complete"
+	wio "dekr0/hd2_audio_db/io"
+	"dekr0/hd2_audio_db/parser"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+func TestHashAssets(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "0000000000000001")
+	headers := []parser.AssetHeader{
+		{FileID: 1, TypeID: 42, DataSize: 4, StreamOffset: 2, StreamSize: 3},
+		{FileID: 2, TypeID: 42, DataOffset: 4, DataSize: 4, GPURsrcSize: 1},
+		{FileID: 3, TypeID: 42, DataOffset: 8, DataSize: 0},
+	}
+	writeTestArchive(t, p, headers, []byte("abcdefgh"))
+	if err := os.WriteFile(p + ".stream", []byte("0123456"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := wio.OpenMmapReader(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	a := parser.Archive{}
+	parser.ParseArchiveMmap(&a, m)
+	assetInsert := assetParams(&a, "0000000000000001")
+	if err := hashAssets(context.Background(), assetInsert, &a, m, p); err != nil {
+		t.Fatal(err)
+	}
+
+	sum := func(s string) int64 { return int64(xxhash.Sum64String(s)) }
+	expects := []database.InsertAssetParams{
+		{DataHash: sum("abcd"), StreamHash: sum("234")},
+		{DataHash: sum("efgh"), HashFailed: 1}, // GPU resource file is missing
+		{},
+	}
+	for i, e := range expects {
+		got := assetInsert[i]
+		if got.DataHash != e.DataHash || 
+		   got.StreamHash != e.StreamHash ||
+		   got.GpuRsrcHash != e.GpuRsrcHash ||
+		   got.HashFailed != e.HashFailed {
+			t.Fatalf(
+				"asset %d: expect hashes (%d, %d, %d, failed %d), got (%d, %d, %d, failed %d)", i,
+				e.DataHash, e.StreamHash, e.GpuRsrcHash, e.HashFailed,
+				got.DataHash, got.StreamHash, got.GpuRsrcHash, got.HashFailed,
+			)
+		}
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	skipped := assetParams(&a, "0000000000000001")
+	if err := hashAssets(cancelled, skipped, &a, m, p); err == nil || skipped[0].DataHash != 0 {
+		t.Fatal("expect no hash after cancellation")
+	}
+}
+
+func TestSameHash(t *testing.T) {
+	a := database.InsertAssetParams{DataHash: 1, StreamHash: 2}
+	row := database.GetAllAssetHashRow{DataHash: 1, StreamHash: 2}
+	if !sameHash(&a, &row) {
+		t.Fatal("expect same hashes")
+	}
+	row.GpuRsrcHash = 3
+	if sameHash(&a, &row) {
+		t.Fatal("expect different GPU resource hashes")
+	}
+
+	// A part that failed to hash is 0 in both builds but never the same
+	a = database.InsertAssetParams{DataHash: 1, HashFailed: 1}
+	row = database.GetAllAssetHashRow{DataHash: 1}
+	if sameHash(&a, &row) {
+		t.Fatal("expect failed hash in current build to be different")
+	}
+	a.HashFailed = 0
+	row.HashFailed = 1
+	if sameHash(&a, &row) {
+		t.Fatal("expect failed hash in previous build to be different")
+	}
+}
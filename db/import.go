@@ -0,0 +1,561 @@
+package db
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	database "dekr0/hd2_audio_db/internal/complete"
+)
+
+// Folder of the label JSON files.
+var LabelDir = "label"
+
+// Layouts of a label JSON file
+const (
+	// {"<name>": "<id>"} or {"mapping": {"<name>": "<id>"}}
+	LabelLayoutMapping = "mapping"
+	// {"version": 1, "sources": {"<name>": "<id>"}} or
+	// {"version": 2, "sources": [{"name", "id"}], "containers": [{"name", "sources", "desc"}]}
+	LabelLayoutSources = "sources"
+	// {"<name>": {"id": "<id>", "tags": ["<tag>"]}}, <id> is the 64 bits file
+	// ID of a WwiseStream asset
+	LabelLayoutMusic   = "music"
+	// shared.json, {"<id>": ["<series>"]}
+	LabelLayoutSeries  = "series"
+	// *_shared_detail_.json, {"detail": [{"audio_source_id", "linked_audio_archive_names"}]}
+	LabelLayoutDetail  = "detail"
+)
+
+// What the ID of a label entry refers to
+const (
+	LabelTargetSource    = "source"
+	LabelTargetHierarchy = "hierarchy"
+)
+
+// labelID is an ID written as a JSON string or number.
+type labelID string
+
+func (i *labelID) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*i = labelID(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(b, &n); err != nil {
+		return err
+	}
+	*i = labelID(n)
+	return nil
+}
+
+type labelEntry struct {
+	id          labelID
+	name        string
+	description string
+	tags        []string
+}
+
+// isComment reports whether key `k` of a label file is a comment (e.g.,
+// `__comment__`) instead of an entry.
+func isComment(k string) bool {
+	return strings.HasPrefix(k, "__")
+}
+
+// parseMapping parses {"<name>": "<id>"}.
+func parseMapping(b []byte) ([]labelEntry, error) {
+	m := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	entries := []labelEntry{}
+	for name, raw := range m {
+		if isComment(name) { continue }
+		var id labelID
+		if err := json.Unmarshal(raw, &id); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		entries = append(entries, labelEntry{id: id, name: name})
+	}
+	return entries, nil
+}
+
+func parseSources(m map[string]json.RawMessage) ([]labelEntry, error) {
+	sources := bytes.TrimSpace(m["sources"])
+	if len(sources) > 0 && sources[0] == '{' {
+		return parseMapping(sources)
+	}
+
+	var v2Sources []struct {
+		Name string  `json:"name"`
+		ID   labelID `json:"id"`
+		Desc string  `json:"desc"`
+	}
+	var v2Containers []struct {
+		Name    string    `json:"name"`
+		Sources []labelID `json:"sources"`
+		Desc    string    `json:"desc"`
+	}
+	if err := json.Unmarshal(sources, &v2Sources); err != nil {
+		return nil, err
+	}
+	if raw, in := m["containers"]; in {
+		if err := json.Unmarshal(raw, &v2Containers); err != nil {
+			return nil, err
+		}
+	}
+	entries := []labelEntry{}
+	for _, s := range v2Sources {
+		entries = append(entries, labelEntry{id: s.ID, name: s.Name, description: s.Desc})
+	}
+	for _, c := range v2Containers {
+		for _, id := range c.Sources {
+			entries = append(entries, labelEntry{id: id, name: c.Name, description: c.Desc})
+		}
+	}
+	return entries, nil
+}
+
+func parseMusic(b []byte) ([]labelEntry, error) {
+	m := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	entries := []labelEntry{}
+	for name, raw := range m {
+		if isComment(name) { continue }
+		var e struct {
+			ID   labelID  `json:"id"`
+			Tags []string `json:"tags"`
+		}
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		entries = append(entries, labelEntry{id: e.ID, name: name, tags: e.Tags})
+	}
+	return entries, nil
+}
+
+func parseSeries(b []byte) ([]labelEntry, error) {
+	m := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	entries := []labelEntry{}
+	for id, raw := range m {
+		if isComment(id) { continue }
+		var series []string
+		if err := json.Unmarshal(raw, &series); err != nil {
+			return nil, fmt.Errorf("%s: %w", id, err)
+		}
+		entries = append(entries, labelEntry{id: labelID(id), tags: series})
+	}
+	return entries, nil
+}
+
+func parseDetail(raw json.RawMessage) ([]labelEntry, error) {
+	var detail []struct {
+		ID           labelID  `json:"audio_source_id"`
+		ArchiveNames []string `json:"linked_audio_archive_names"`
+	}
+	if err := json.Unmarshal(raw, &detail); err != nil {
+		return nil, err
+	}
+	entries := []labelEntry{}
+	for _, d := range detail {
+		entries = append(entries, labelEntry{id: d.ID, tags: d.ArchiveNames})
+	}
+	return entries, nil
+}
+
+// parseLabelFile detects the layout of label file `b` and returns its
+// entries sorted by ID and name.
+func parseLabelFile(b []byte) (string, []labelEntry, error) {
+	layout, entries, err := parseLabelLayout(b)
+	if err != nil {
+		return "", nil, err
+	}
+	slices.SortFunc(entries, func(a labelEntry, b labelEntry) int {
+		if c := cmp.Compare(a.id, b.id); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.name, b.name)
+	})
+	return layout, entries, nil
+}
+
+func parseLabelLayout(b []byte) (string, []labelEntry, error) {
+	m := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(b, &m); err != nil {
+		return "", nil, err
+	}
+	if raw, in := m["detail"]; in {
+		entries, err := parseDetail(raw)
+		return LabelLayoutDetail, entries, err
+	}
+	if raw, in := m["mapping"]; in {
+		entries, err := parseMapping(raw)
+		return LabelLayoutMapping, entries, err
+	}
+	if _, in := m["sources"]; in {
+		entries, err := parseSources(m)
+		return LabelLayoutSources, entries, err
+	}
+
+	// Layout without a top level key is told by its entries, which must all
+	// agree.
+	keys := slices.Sorted(maps.Keys(m))
+	layout, first := "", ""
+	for _, k := range keys {
+		if isComment(k) { continue }
+		raw := bytes.TrimSpace(m[k])
+		if len(raw) == 0 { continue }
+		l := LabelLayoutMapping
+		switch raw[0] {
+		case '[':
+			l = LabelLayoutSeries
+		case '{':
+			l = LabelLayoutMusic
+		}
+		if layout == "" {
+			layout, first = l, k
+		} else if l != layout {
+			return "", nil, fmt.Errorf(
+				"Entries mix layouts: %s is %s but %s is %s", first, layout, k, l,
+			)
+		}
+	}
+	switch layout {
+	case LabelLayoutSeries:
+		entries, err := parseSeries(b)
+		return layout, entries, err
+	case LabelLayoutMusic:
+		entries, err := parseMusic(b)
+		return layout, entries, err
+	case LabelLayoutMapping:
+		entries, err := parseMapping(b)
+		return layout, entries, err
+	}
+	return LabelLayoutMapping, []labelEntry{}, nil
+}
+
+// fileTags returns the tags that every entry of label file `rel` (relative to
+// the label folder) gets: the name of its folder and its own name. Files that
+// list tags per entry (series and detail) get none.
+func fileTags(rel string, layout string) []string {
+	if layout == LabelLayoutSeries || layout == LabelLayoutDetail {
+		return []string{}
+	}
+	tags := []string{}
+	if dir := path.Base(path.Dir(rel)); dir != "." {
+		tags = append(tags, dir)
+	}
+	return append(tags, strings.TrimSuffix(path.Base(rel), path.Ext(rel)))
+}
+
+type LabelFileReport struct {
+	File       string `json:"file"`
+	Layout     string `json:"layout,omitempty"`
+	Entries    int    `json:"entries"`
+	// Sources labeled. An entry with a stream file ID labels every source in
+	// that stream.
+	Sources    int    `json:"sources"`
+	Hierarchy  int    `json:"hierarchy"`
+	// Entries resolved by the file ID of a WwiseStream asset
+	Streams    int    `json:"streams"`
+	// Entries whose ID is neither a source, a hierarchy object nor a stream
+	// file ID
+	Unresolved int    `json:"unresolved"`
+	// Set if the file is skipped
+	Error      string `json:"error,omitempty"`
+}
+
+type LabelReport struct {
+	Files   []LabelFileReport `json:"files"`
+	Entries int               `json:"entries"`
+	Invalid int               `json:"invalid"`
+}
+
+type hierarchyLabel struct {
+	name        string
+	description string
+	tags        []string
+}
+
+// labelWriter resolves IDs of label entries and writes them into the
+// database.
+type labelWriter struct {
+	q         *database.Queries
+	ids       *labelIDs
+	sources   map[int64]struct{}
+	hierarchy map[int64]struct{}
+	// Sources of each WwiseStream asset by file ID
+	streams   map[int64][]int64
+	// Labels of a hierarchy object merged across files
+	labels    map[int64]*hierarchyLabel
+}
+
+// resolve returns the target of `id` and the IDs it maps to, or "" if it is
+// neither a source, a hierarchy object nor the file ID of a WwiseStream asset.
+// A source is preferred. A 64 bits file ID (e.g., music labels) maps to the
+// sources streamed from that asset, and `stream` is set.
+func (w *labelWriter) resolve(id labelID) (target string, ids []int64, stream bool) {
+	v, err := strconv.ParseUint(strings.TrimSpace(string(id)), 10, 64)
+	if err != nil {
+		return "", nil, false
+	}
+	if v <= math.MaxUint32 {
+		if _, in := w.sources[int64(v)]; in {
+			return LabelTargetSource, []int64{int64(v)}, false
+		}
+		if _, in := w.hierarchy[int64(v)]; in {
+			return LabelTargetHierarchy, []int64{int64(v)}, false
+		}
+	}
+	if sids, in := w.streams[int64(v)]; in {
+		return LabelTargetSource, sids, true
+	}
+	return "", nil, false
+}
+
+func (w *labelWriter) write(
+	ctx context.Context, rel string, layout string, entries []labelEntry,
+) (LabelFileReport, error) {
+	r := LabelFileReport{File: rel, Layout: layout, Entries: len(entries)}
+	common := fileTags(rel, layout)
+	for _, e := range entries {
+		target, ids, stream := w.resolve(e.id)
+		if target == "" {
+			r.Unresolved += 1
+			continue
+		}
+		if stream {
+			r.Streams += 1
+		}
+		tags := slices.Clone(common)
+		for _, t := range e.tags {
+			t = strings.TrimSpace(t)
+			if t != "" && !slices.Contains(tags, t) {
+				tags = append(tags, t)
+			}
+		}
+		for _, id := range ids {
+			if err := w.q.InsertLabelEntry(ctx, database.InsertLabelEntryParams{
+				File: rel,
+				Target: target,
+				ID: id,
+				Name: e.name,
+				Description: e.description,
+				Tags: strings.Join(tags, LabelSeparator),
+			}); err != nil {
+				return r, err
+			}
+
+			if target == LabelTargetHierarchy {
+				r.Hierarchy += 1
+				l, in := w.labels[id]
+				if !in {
+					l = &hierarchyLabel{}
+					w.labels[id] = l
+				}
+				if l.name == "" {
+					l.name = e.name
+				}
+				if l.description == "" {
+					l.description = e.description
+				}
+				for _, t := range tags {
+					if !slices.Contains(l.tags, t) {
+						l.tags = append(l.tags, t)
+					}
+				}
+				continue
+			}
+
+			r.Sources += 1
+			for _, t := range tags {
+				tid, err := w.ids.tag(ctx, t)
+				if err != nil {
+					return r, err
+				}
+				if err := w.q.InsertSourceTag(ctx, database.InsertSourceTagParams{
+					Sid: id, TagID: tid,
+				}); err != nil {
+					return r, err
+				}
+			}
+		}
+	}
+	return r, nil
+}
+
+// flush writes the merged labels into `hierarchy` and `hierarchy_tag` table.
+func (w *labelWriter) flush(ctx context.Context) error {
+	for hid, l := range w.labels {
+		if err := w.q.UpdateHierarchyLabel(ctx, database.UpdateHierarchyLabelParams{
+			Label: l.name,
+			Tags: strings.Join(l.tags, LabelSeparator),
+			Description: l.description,
+			Hid: hid,
+		}); err != nil {
+			return err
+		}
+		for _, t := range l.tags {
+			tid, err := w.ids.tag(ctx, t)
+			if err != nil {
+				return err
+			}
+			if err := w.q.InsertHierarchyTagByHid(ctx, database.InsertHierarchyTagByHidParams{
+				TagID: tid, Hid: hid,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ImportLabels imports every JSON file in `dir` (see LabelLayout* for the
+// layouts understood) into `label_entry` table. Each entry is mapped to a
+// source or a hierarchy object by ID, or to the sources of a WwiseStream asset
+// by its 64 bits file ID (`source_location.fid`). Names, descriptions and tags of
+// hierarchy objects are written into `hierarchy` and `hierarchy_tag` table,
+// and tags of sources into `source_tag` table. Labels of a previous import
+// are replaced. Invalid files are reported and skipped. The report is
+// written into `out` as JSON.
+func ImportLabels(ctx context.Context, dir string, out io.Writer) (*LabelReport, error) {
+	paths := []string{}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && filepath.Ext(p) == ".json" {
+			paths = append(paths, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(paths)
+
+	c, err := conn()
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	q := database.New(c)
+	sids, err := q.GetAllSoundSid(ctx)
+	if err != nil {
+		return nil, err
+	}
+	hids, err := q.GetAllHierarchyHid(ctx)
+	if err != nil {
+		return nil, err
+	}
+	streams, err := q.GetAllStreamSource(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := c.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	qTx := q.WithTx(tx)
+	if err := qTx.DeleteAllLabelEntry(ctx); err != nil {
+		return nil, err
+	}
+	if err := qTx.DeleteAllSourceTag(ctx); err != nil {
+		return nil, err
+	}
+	if err := qTx.DeleteAllHierarchyTag(ctx); err != nil {
+		return nil, err
+	}
+	if err := qTx.ResetHierarchyLabel(ctx); err != nil {
+		return nil, err
+	}
+
+	w := labelWriter{
+		q: qTx,
+		ids: newLabelIDs(qTx),
+		sources: make(map[int64]struct{}, len(sids)),
+		hierarchy: make(map[int64]struct{}, len(hids)),
+		streams: make(map[int64][]int64),
+		labels: make(map[int64]*hierarchyLabel),
+	}
+	for _, sid := range sids {
+		w.sources[sid] = struct{}{}
+	}
+	for _, hid := range hids {
+		w.hierarchy[hid] = struct{}{}
+	}
+	for _, s := range streams {
+		w.streams[s.Fid] = append(w.streams[s.Fid], s.Sid)
+	}
+
+	report := LabelReport{Files: []LabelFileReport{}}
+	for _, p := range paths {
+		select {
+		case <- ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return nil, err
+		}
+		rel = filepath.ToSlash(rel)
+
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		layout, entries, err := parseLabelFile(b)
+		if err != nil {
+			slog.Warn("Skipped invalid label file", "file", rel, "error", err)
+			report.Files = append(report.Files, LabelFileReport{File: rel, Error: err.Error()})
+			report.Invalid += 1
+			continue
+		}
+		r, err := w.write(ctx, rel, layout, entries)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", rel, err)
+		}
+		report.Files = append(report.Files, r)
+		report.Entries += r.Entries
+	}
+	if err := w.flush(ctx); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	slog.Info(
+		"Imported labels",
+		"path", dir,
+		"files", len(paths),
+		"entries", report.Entries,
+		"invalid", report.Invalid,
+	)
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return &report, enc.Encode(&report)
+}
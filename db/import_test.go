@@ -0,0 +1,203 @@
+package db
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestParseLabelFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		layout  string
+		ids     []labelID
+	}{
+		{
+			"flat",
+			`{"kick_01": "634033290", "__comment__": "not an entry", "kick_02": 890702132}`,
+			LabelLayoutMapping,
+			[]labelID{"634033290", "890702132"},
+		},
+		{
+			"mapping",
+			`{"mapping": {"tail_01": "291193203", "__comment__": "not an entry"}}`,
+			LabelLayoutMapping,
+			[]labelID{"291193203"},
+		},
+		{
+			"sources v1",
+			`{"version": 1, "sources": {"hmg_01": "515083593"}}`,
+			LabelLayoutSources,
+			[]labelID{"515083593"},
+		},
+		{
+			"sources v2",
+			`{"version": 2, "sources": [{"name": "tail", "id": "18767884"}],
+			  "containers": [{"name": "foley", "sources": ["1", "2"], "desc": "d"}]}`,
+			LabelLayoutSources,
+			[]labelID{"1", "18767884", "2"},
+		},
+		{
+			"music",
+			`{"engage_01": {"id": "9619311087507923469", "tags": ["instrumental"]}}`,
+			LabelLayoutMusic,
+			[]labelID{"9619311087507923469"},
+		},
+		{
+			"series",
+			`{"1019838111": ["HMG EM", "Liberator Series"]}`,
+			LabelLayoutSeries,
+			[]labelID{"1019838111"},
+		},
+		{
+			"detail",
+			`{"detail": [{"audio_source_id": "291193203",
+			  "linked_audio_archive_ids": ["3b2c86dd5b316eb2"],
+			  "linked_audio_archive_names": ["Dropship"]}]}`,
+			LabelLayoutDetail,
+			[]labelID{"291193203"},
+		},
+	}
+	for _, test := range tests {
+		layout, entries, err := parseLabelFile([]byte(test.content))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if layout != test.layout {
+			t.Fatalf("%s: expect layout %s, got %s", test.name, test.layout, layout)
+		}
+		ids := []labelID{}
+		for _, e := range entries {
+			ids = append(ids, e.id)
+		}
+		if !slices.Equal(ids, test.ids) {
+			t.Fatalf("%s: expect %v, got %v", test.name, test.ids, ids)
+		}
+	}
+
+	_, entries, _ := parseLabelFile([]byte(`{"version": 2, "sources": [],
+		"containers": [{"name": "foley", "sources": ["1"], "desc": "d"}]}`))
+	if entries[0].name != "foley" || entries[0].description != "d" {
+		t.Fatalf("expect container label, got %v", entries[0])
+	}
+
+	if _, _, err := parseLabelFile([]byte(`{"a": "1",}`)); err == nil {
+		t.Fatal("expect error on trailing comma")
+	}
+
+	// Decided by every entry instead of whichever comes first in a map
+	mixed := []byte(`{"a": "1", "b": {"id": "2", "tags": []}, "c": ["x"]}`)
+	for range 16 {
+		if _, _, err := parseLabelFile(mixed); err == nil {
+			t.Fatal("expect error on entries of different layouts")
+		}
+	}
+}
+
+func TestParseLabelDir(t *testing.T) {
+	dir := filepath.Join("..", "label")
+	if _, err := os.Stat(dir); err != nil {
+		t.Skip("No label folder")
+	}
+	err := filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(p) != ".json" {
+			return err
+		}
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		// Files that are not valid JSON are reported and skipped by
+		// ImportLabels. Every valid file must have a known layout.
+		if _, entries, err := parseLabelFile(b); err == nil && len(entries) == 0 {
+			t.Errorf("%s: no entry", p)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileTags(t *testing.T) {
+	tags := fileTags("weapons_stratagems/safe/hmg.json", LabelLayoutSources)
+	if !slices.Equal(tags, []string{"safe", "hmg"}) {
+		t.Fatalf("unexpected tags %v", tags)
+	}
+	if tags := fileTags("shared.json", LabelLayoutSeries); len(tags) != 0 {
+		t.Fatalf("expect no tag, got %v", tags)
+	}
+	if tags := fileTags("liberator.json", LabelLayoutMapping); !slices.Equal(tags, []string{"liberator"}) {
+		t.Fatalf("unexpected tags %v", tags)
+	}
+}
+
+func TestLabelResolve(t *testing.T) {
+	_, entries, err := parseLabelFile([]byte(`{
+		"high_diff_bot_not_engage_variant_01_01": { "id": "2710884087396091108", "tags": ["buildup", "ambient"] },
+		"high_diff_bot_not_engage_variant_01_02": { "id": "17943417857097256822", "tags": ["buildup", "ambient"] },
+		"high_diff_bot_engage_01": { "id": "2448990006426812253", "tags": ["instructmental"] }
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := labelWriter{
+		sources: map[int64]struct{}{291193203: {}},
+		hierarchy: map[int64]struct{}{18767884: {}},
+		streams: map[int64][]int64{},
+	}
+	// File IDs above math.MaxInt64 are stored as negative INTEGER.
+	for fid, sids := range map[uint64][]int64{
+		2710884087396091108: {1001, 1002},
+		17943417857097256822: {1003},
+	} {
+		w.streams[int64(fid)] = sids
+	}
+
+	expect := map[labelID][]int64{
+		"2448990006426812253": nil,
+		"2710884087396091108": {1001, 1002},
+		"17943417857097256822": {1003},
+	}
+	for _, e := range entries {
+		target, ids, stream := w.resolve(e.id)
+		if expect[e.id] == nil {
+			if target != "" {
+				t.Fatalf("%s: expect unresolved, got %s %v", e.name, target, ids)
+			}
+			continue
+		}
+		if target != LabelTargetSource || !stream || !slices.Equal(ids, expect[e.id]) {
+			t.Fatalf("%s: expect sources %v, got %s %v", e.name, expect[e.id], target, ids)
+		}
+	}
+
+	if target, ids, stream := w.resolve("291193203"); target != LabelTargetSource || ids[0] != 291193203 || stream {
+		t.Fatalf("expect source, got %s %v", target, ids)
+	}
+	if target, ids, _ := w.resolve("18767884"); target != LabelTargetHierarchy || ids[0] != 18767884 {
+		t.Fatalf("expect hierarchy object, got %s %v", target, ids)
+	}
+	if target, _, _ := w.resolve("-1"); target != "" {
+		t.Fatalf("expect invalid ID to be unresolved, got %s", target)
+	}
+
+	b, err := os.ReadFile(filepath.Join("..", "label", "music", "bot_high_diff_combat.json"))
+	if err != nil {
+		t.Skip("No music label")
+	}
+	layout, entries, err := parseLabelFile(b)
+	if err != nil || layout != LabelLayoutMusic {
+		t.Fatalf("expect music layout, got %s: %v", layout, err)
+	}
+	for _, e := range entries {
+		v, err := strconv.ParseUint(string(e.id), 10, 64)
+		if err != nil || v <= math.MaxUint32 {
+			t.Fatalf("%s: expect 64 bits file ID, got %s", e.name, e.id)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package db
+
+import (
+	"context"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	wio "dekr0/hd2_audio_db/io"
+	"dekr0/hd2_audio_db/parser"
+)
+
+// Bump whenever the layout of TocIndex or parser.Archive changes so that an
+// index written by an older build is discarded instead of being misread.
+const tocIndexVersion = 2
+
+// Path of the persistent ToC index. Empty means the default location in the
+// user cache directory.
+var TocIndexPath = ""
+
+// TocIndex is a persistent index of parsed archive headers keyed by archive
+// ID. An entry is valid as long as the size and the modification time of the
+// archive match. Only headers of sound banks and wwise dependencies are kept
+// to keep the index small.
+type TocIndex struct {
+	Version int
+	Entries map[string]*TocEntry
+	m       sync.Mutex
+	dirty   bool
+}
+
+type TocEntry struct {
+	Size    int64
+	ModTime int64
+	Archive *parser.Archive
+}
+
+func tocIndexPath() (string, error) {
+	if TocIndexPath != "" {
+		return TocIndexPath, nil
+	}
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cache, "hd2_audio_db", "toc_index.gob"), nil
+}
+
+// LoadTocIndex loads the persistent ToC index. A missing, corrupted or
+// outdated index yields an empty index instead of an error. It will be
+// rebuilt by Refresh and Lookup.
+func LoadTocIndex() *TocIndex {
+	idx := &TocIndex{
+		Version: tocIndexVersion,
+		Entries: make(map[string]*TocEntry),
+	}
+
+	p, err := tocIndexPath()
+	if err != nil {
+		slog.Warn("Failed to locate ToC index", "error", err)
+		return idx
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn("Failed to open ToC index", "path", p, "error", err)
+		}
+		return idx
+	}
+	defer f.Close()
+
+	loaded := TocIndex{}
+	if err := gob.NewDecoder(f).Decode(&loaded); err != nil {
+		slog.Warn("Discard corrupted ToC index", "path", p, "error", err)
+		return idx
+	}
+	if loaded.Version != tocIndexVersion || loaded.Entries == nil {
+		slog.Info("Discard outdated ToC index", "path", p)
+		return idx
+	}
+	idx.Entries = loaded.Entries
+	return idx
+}
+
+// Save writes the index if it has been changed since it is loaded. The index
+// is written into a temporary file first and then renamed so that an
+// interrupted run never leaves a truncated index behind.
+func (idx *TocIndex) Save() error {
+	idx.m.Lock()
+	defer idx.m.Unlock()
+	if !idx.dirty {
+		return nil
+	}
+
+	p, err := tocIndexPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0777); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(p), filepath.Base(p) + ".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if err := gob.NewEncoder(f).Encode(idx); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), p); err != nil {
+		return err
+	}
+	idx.dirty = false
+	return nil
+}
+
+// Lookup returns the headers of archive `aid`. The archive is parsed and the
+// entry is replaced if the archive changed since it is indexed.
+func (idx *TocIndex) Lookup(data string, aid string) (*parser.Archive, error) {
+	p := filepath.Join(data, aid)
+	stat, err := os.Stat(p)
+	if err != nil {
+		idx.m.Lock()
+		if _, in := idx.Entries[aid]; in {
+			delete(idx.Entries, aid)
+			idx.dirty = true
+		}
+		idx.m.Unlock()
+		return nil, err
+	}
+	return idx.lookup(p, aid, stat)
+}
+
+func (idx *TocIndex) lookup(p string, aid string, stat os.FileInfo) (
+	*parser.Archive, error,
+) {
+	idx.m.Lock()
+	e, in := idx.Entries[aid]
+	idx.m.Unlock()
+	if in && e.Size == stat.Size() && e.ModTime == stat.ModTime().UnixNano() {
+		return e.Archive, nil
+	}
+
+	a, err := parseToc(p)
+	if err != nil {
+		return nil, err
+	}
+	idx.m.Lock()
+	idx.Entries[aid] = &TocEntry{
+		Size: stat.Size(),
+		ModTime: stat.ModTime().UnixNano(),
+		Archive: a,
+	}
+	idx.dirty = true
+	idx.m.Unlock()
+	return a, nil
+}
+
+// Refresh validates every archive in `data` against the index. New or changed
+// archives are parsed, and entries of removed archives are dropped. Only
+// stat is needed for archives that are unchanged.
+func (idx *TocIndex) Refresh(ctx context.Context, data string) error {
+	f, err := os.Open(data)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	seen := make(map[string]struct{}, len(idx.Entries))
+	sem := make(chan struct{}, MaxArchiveReder)
+	var w sync.WaitGroup
+	for {
+		entries, err := f.ReadDir(1024)
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		for _, entry := range entries {
+			if !isArchive(entry) { continue }
+
+			stat, err := entry.Info()
+			if err != nil {
+				slog.Error("Failed to stat archive", "archive", entry.Name(), "error", err)
+				continue
+			}
+			seen[entry.Name()] = struct{}{}
+
+			select {
+			case <- ctx.Done():
+				w.Wait()
+				return ctx.Err()
+			case sem <- struct{}{}:
+				w.Add(1)
+				go func(aid string, stat os.FileInfo) {
+					defer w.Done()
+					defer func() { <- sem }()
+					if _, err := idx.lookup(filepath.Join(data, aid), aid, stat); err != nil {
+						slog.Warn("Failed to index archive", "archive", aid, "error", err)
+					}
+				}(entry.Name(), stat)
+			}
+		}
+	}
+	w.Wait()
+
+	idx.m.Lock()
+	for aid := range idx.Entries {
+		if _, in := seen[aid]; !in {
+			delete(idx.Entries, aid)
+			idx.dirty = true
+		}
+	}
+	idx.m.Unlock()
+	return nil
+}
+
+// Soundbanks returns the archive IDs of all indexed archives that contain at
+// least one sound bank in lexical order.
+func (idx *TocIndex) Soundbanks() []string {
+	idx.m.Lock()
+	defer idx.m.Unlock()
+	aids := make([]string, 0, len(idx.Entries))
+	for aid, e := range idx.Entries {
+		if len(e.Archive.SoundBnks) > 0 {
+			aids = append(aids, aid)
+		}
+	}
+	slices.Sort(aids)
+	return aids
+}
+
+// parseToc parses the ToC of archive `p` and keeps only headers of sound
+// banks and wwise dependencies. Indices in SoundBnks and Deps are remapped to
+// the trimmed Headers.
+func parseToc(p string) (a *parser.Archive, err error) {
+	m, err := wio.OpenMmapReader(p)
+	if err != nil {
+		return nil, err
+	}
+	defer m.Close()
+
+	defer func() {
+		if r := recover(); r != nil {
+			a = nil
+			err = fmt.Errorf("Failed to parse ToC of %s: %v", p, r)
+		}
+	}()
+
+	full := parser.Archive{}
+	parser.ParseArchiveMmap(&full, m)
+
+	a = &parser.Archive{
+		NumTypes: full.NumTypes,
+		NumFiles: full.NumFiles,
+		Unknown: full.Unknown,
+		Unk4Data: full.Unk4Data,
+		AssetTypeCnts: full.AssetTypeCnts,
+		Headers: make([]parser.AssetHeader, 0, len(full.SoundBnks) + len(full.Deps)),
+		SoundBnks: make([]uint32, 0, len(full.SoundBnks)),
+		Deps: make([]uint32, 0, len(full.Deps)),
+	}
+	for _, i := range full.SoundBnks {
+		a.SoundBnks = append(a.SoundBnks, uint32(len(a.Headers)))
+		a.Headers = append(a.Headers, full.Headers[i])
+	}
+	for _, i := range full.Deps {
+		a.Deps = append(a.Deps, uint32(len(a.Headers)))
+		a.Headers = append(a.Headers, full.Headers[i])
+	}
+	return a, nil
+}
+
+// listArchives returns the IDs of all archives in `data` in lexical order 
+// without parsing them.
+func listArchives(data string) ([]string, error) {
+	entries, err := os.ReadDir(data)
+	if err != nil {
+		return nil, err
+	}
+	aids := []string{}
+	for _, entry := range entries {
+		if isArchive(entry) {
+			aids = append(aids, entry.Name())
+		}
+	}
+	return aids, nil
+}
+
+func isArchive(entry os.DirEntry) bool {
+	if entry.IsDir() { return false }
+
+	ext := filepath.Ext(entry.Name())
+	if strings.Compare(ext, ".stream") == 0 { return false }
+	if strings.Compare(ext, ".gpu_resources") == 0 { return false }
+	if strings.Compare(ext, ".ini") == 0 { return false }
+	if strings.Compare(ext, ".data") == 0 { return false }
+	if strings.Contains(ext, "patch") { return false }
+	return true
+}
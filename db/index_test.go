@@ -0,0 +1,147 @@
+package db
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	wio "dekr0/hd2_audio_db/io"
+	"dekr0/hd2_audio_db/parser"
+)
+
+// writeTestArchive writes a minimal archive with the given asset headers and 
+// `data` appended right after the ToC. Data offsets of headers are relative 
+// to the end of the ToC.
+func writeTestArchive(t *testing.T, p string, headers []parser.AssetHeader, data []byte) {
+	types := []uint64{}
+	cnts := map[uint64]uint64{}
+	for _, h := range headers {
+		if _, in := cnts[h.TypeID]; !in {
+			types = append(types, h.TypeID)
+		}
+		cnts[h.TypeID] += 1
+	}
+
+	w := wio.NewBufferWriter(0, wio.ByteOrder)
+	w.U32Unsafe(parser.MagicValue)
+	w.U32Unsafe(uint32(len(types)))
+	w.U32Unsafe(uint32(len(headers)))
+	w.U32Unsafe(0)
+	w.PadUnsafe(56)
+	for _, t := range types {
+		w.PadUnsafe(8)
+		w.U64Unsafe(t)
+		w.U64Unsafe(cnts[t])
+		w.PadUnsafe(8)
+	}
+	base := uint64(w.Tell()) + uint64(len(headers)) * 80
+	for i, h := range headers {
+		w.U64Unsafe(h.FileID)
+		w.U64Unsafe(h.TypeID)
+		w.U64Unsafe(base + h.DataOffset)
+		w.U64Unsafe(h.StreamOffset)
+		w.U64Unsafe(h.GPURsrcOffset)
+		w.U64Unsafe(h.UnknownU64A)
+		w.U64Unsafe(h.UnknownU64B)
+		w.U32Unsafe(h.DataSize)
+		w.U32Unsafe(h.StreamSize)
+		w.U32Unsafe(h.GPURsrcSize)
+		w.U32Unsafe(h.UnknownU32A)
+		w.U32Unsafe(h.UnknownU32B)
+		w.U32Unsafe(uint32(i))
+	}
+	w.WriteUnsafe(data)
+	if err := os.WriteFile(p, w.Bytes(), 0666); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTocIndex(t *testing.T) {
+	data := t.TempDir()
+	TocIndexPath = filepath.Join(t.TempDir(), "toc_index.gob")
+	defer func() { TocIndexPath = "" }()
+
+	bank := parser.AssetHeader{
+		FileID: 1, TypeID: uint64(parser.AssetTypeSoundBank), DataSize: 4,
+	}
+	dep := parser.AssetHeader{
+		FileID: 1, TypeID: uint64(parser.AssetTypeWwiseDependency), 
+		DataOffset: 4, DataSize: 4,
+	}
+	other := parser.AssetHeader{FileID: 2, TypeID: 42}
+	writeTestArchive(t, filepath.Join(data, "0000000000000001"), 
+		[]parser.AssetHeader{other, bank, dep}, make([]byte, 8))
+	writeTestArchive(t, filepath.Join(data, "0000000000000002"), 
+		[]parser.AssetHeader{other}, nil)
+	os.WriteFile(filepath.Join(data, "settings.ini"), []byte("ini"), 0666)
+	os.WriteFile(filepath.Join(data, "0000000000000003"), []byte("garbage"), 0666)
+
+	idx := LoadTocIndex()
+	if err := idx.Refresh(context.Background(), data); err != nil {
+		t.Fatal(err)
+	}
+	if len(idx.Entries) != 2 {
+		t.Fatalf("expect 2 entries, got %d", len(idx.Entries))
+	}
+	aids := idx.Soundbanks()
+	if len(aids) != 1 || aids[0] != "0000000000000001" {
+		t.Fatalf("unexpected archives with sound banks %v", aids)
+	}
+	a := idx.Entries[aids[0]].Archive
+	if len(a.Headers) != 2 || a.Headers[a.SoundBnks[0]].FileID != 1 ||
+	   a.Headers[a.Deps[0]].TypeID != uint64(parser.AssetTypeWwiseDependency) {
+		t.Fatalf("unexpected trimmed archive %v", a.Headers)
+	}
+	if err := idx.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	idx = LoadTocIndex()
+	if len(idx.Entries) != 2 || idx.dirty {
+		t.Fatalf("expect 2 clean entries after reload, got %d", len(idx.Entries))
+	}
+
+	// Changed archive is parsed again
+	writeTestArchive(t, filepath.Join(data, "0000000000000001"), 
+		[]parser.AssetHeader{bank, dep}, make([]byte, 8))
+	a, err := idx.Lookup(data, "0000000000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.NumFiles != 2 || !idx.dirty {
+		t.Fatalf("expect stale entry to be replaced, got %d files", a.NumFiles)
+	}
+
+	// Removed archive is dropped
+	os.Remove(filepath.Join(data, "0000000000000002"))
+	if err := idx.Refresh(context.Background(), data); err != nil {
+		t.Fatal(err)
+	}
+	if _, in := idx.Entries["0000000000000002"]; in || len(idx.Entries) != 1 {
+		t.Fatalf("expect removed archive to be dropped, got %d entries", len(idx.Entries))
+	}
+}
+
+func TestListArchives(t *testing.T) {
+	data := t.TempDir()
+	for _, name := range []string{
+		"9ba626afa44a3aa3", "2e24ba9dd702da5c", "2e24ba9dd702da5c.stream",
+		"2e24ba9dd702da5c.gpu_resources", "settings.ini", "9ba626afa44a3aa3.patch_0",
+	} {
+		if err := os.WriteFile(filepath.Join(data, name), nil, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(data, "dir"), 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	aids, err := listArchives(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(aids) != 2 || aids[0] != "2e24ba9dd702da5c" || aids[1] != "9ba626afa44a3aa3" {
+		t.Fatalf("unexpected archives %v", aids)
+	}
+}
@@ -0,0 +1,210 @@
+package db
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	database "dekr0/hd2_audio_db/internal/complete"
+)
+
+// Folder of the CSV files that assign tags and categories to archives.
+var ArchiveCSV = "csv/archives"
+
+// Separator of multiple values in `tags` and `categories` columns
+const LabelSeparator = ";"
+
+// splitLabels splits `s` joined with LabelSeparator. Empty and repeated
+// labels are dropped.
+func splitLabels(s string) []string {
+	labels := []string{}
+	for _, l := range strings.Split(s, LabelSeparator) {
+		l = strings.TrimSpace(l)
+		if l == "" || slices.Contains(labels, l) { continue }
+		labels = append(labels, l)
+	}
+	return labels
+}
+
+// labelIDs inserts tags and categories into `tag` and `category` table on
+// demand and caches their IDs.
+type labelIDs struct {
+	q          *database.Queries
+	tags       map[string]int64
+	categories map[string]int64
+}
+
+func newLabelIDs(q *database.Queries) *labelIDs {
+	return &labelIDs{q, make(map[string]int64), make(map[string]int64)}
+}
+
+func (l *labelIDs) tag(ctx context.Context, name string) (int64, error) {
+	if id, in := l.tags[name]; in {
+		return id, nil
+	}
+	id, err := l.q.InsertTag(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+	l.tags[name] = id
+	return id, nil
+}
+
+func (l *labelIDs) category(ctx context.Context, name string) (int64, error) {
+	if id, in := l.categories[name]; in {
+		return id, nil
+	}
+	id, err := l.q.InsertCategory(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+	l.categories[name] = id
+	return id, nil
+}
+
+// writeArchiveLabels replaces the rows of archive `aid` in `archive_tag` and
+// `archive_category` table.
+func writeArchiveLabels(
+	ctx context.Context, ids *labelIDs, aid string, tags string, categories string,
+) error {
+	if err := ids.q.DeleteArchiveTag(ctx, aid); err != nil {
+		return err
+	}
+	if err := ids.q.DeleteArchiveCategory(ctx, aid); err != nil {
+		return err
+	}
+	for _, t := range splitLabels(tags) {
+		id, err := ids.tag(ctx, t)
+		if err != nil {
+			return err
+		}
+		if err := ids.q.InsertArchiveTag(ctx, database.InsertArchiveTagParams{
+			Aid: aid, TagID: id,
+		}); err != nil {
+			return err
+		}
+	}
+	for _, c := range splitLabels(categories) {
+		id, err := ids.category(ctx, c)
+		if err != nil {
+			return err
+		}
+		if err := ids.q.InsertArchiveCategory(ctx, database.InsertArchiveCategoryParams{
+			Aid: aid, CategoryID: id,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type archiveLabel struct {
+	tags       []string
+	categories []string
+}
+
+func (l *archiveLabel) add(tag string, category string) {
+	if tag != "" && !slices.Contains(l.tags, tag) {
+		l.tags = append(l.tags, tag)
+	}
+	if !slices.Contains(l.categories, category) {
+		l.categories = append(l.categories, category)
+	}
+}
+
+// readArchiveLabels reads every `<category>.csv` in `dir`. The first column of
+// a row is a tag. The remaining columns are the IDs of archives with that tag
+// and category. An archive can have multiple tags and categories.
+func readArchiveLabels(dir string) (map[string]*archiveLabel, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.csv"))
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(paths)
+
+	labels := make(map[string]*archiveLabel)
+	for _, p := range paths {
+		category := strings.TrimSuffix(filepath.Base(p), ".csv")
+		if err := readArchiveCSV(p, category, labels); err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+	}
+	return labels, nil
+}
+
+func readArchiveCSV(p string, category string, labels map[string]*archiveLabel) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	for {
+		record, err := r.Read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		if len(record) == 0 { continue }
+		tag := strings.TrimSpace(record[0])
+		for _, aid := range record[1:] {
+			aid = strings.TrimSpace(aid)
+			if aid == "" { continue }
+			l, in := labels[aid]
+			if !in {
+				l = &archiveLabel{}
+				labels[aid] = l
+			}
+			l.add(tag, category)
+		}
+	}
+	return nil
+}
+
+// loadArchiveLabels reads the labels in ArchiveCSV and reports archive IDs
+// that are not in `data`. Archives are left without labels if ArchiveCSV does
+// not exist.
+func loadArchiveLabels(data string) (map[string]*archiveLabel, error) {
+	if _, err := os.Stat(ArchiveCSV); err != nil {
+		slog.Warn("No archive CSV. Archives have no tag and category.", "path", ArchiveCSV)
+		return map[string]*archiveLabel{}, nil
+	}
+	labels, err := readArchiveLabels(ArchiveCSV)
+	if err != nil {
+		return nil, err
+	}
+
+	missing := []string{}
+	for aid := range labels {
+		if _, err := os.Stat(filepath.Join(data, aid)); err != nil {
+			missing = append(missing, aid)
+		}
+	}
+	slices.Sort(missing)
+	for _, aid := range missing {
+		l := labels[aid]
+		slog.Warn(
+			"Archive in CSV is not in data folder",
+			"aid", aid,
+			"tags", strings.Join(l.tags, LabelSeparator),
+			"categories", strings.Join(l.categories, LabelSeparator),
+		)
+	}
+	slog.Info(
+		"Loaded archive labels",
+		"path", ArchiveCSV,
+		"archives", len(labels),
+		"missing", len(missing),
+	)
+	return labels, nil
+}
@@ -0,0 +1,51 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestReadArchiveLabels(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"weapons.csv": "AR-23 Liberator,a14f1a98c80575af,dbd9381f9ea9788f,,\n" +
+			"AC-8 Autocannon,6ad7cc21015a5f85,,,\n",
+		"stratagems.csv": "Autocannon Sentry,6ad7cc21015a5f85\n" +
+			"AC-8 Autocannon, 6ad7cc21015a5f85 \n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	labels, err := readArchiveLabels(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(labels) != 3 {
+		t.Fatalf("expect 3 archives, got %d", len(labels))
+	}
+	l := labels["6ad7cc21015a5f85"]
+	if !slices.Equal(l.tags, []string{"Autocannon Sentry", "AC-8 Autocannon"}) ||
+	   !slices.Equal(l.categories, []string{"stratagems", "weapons"}) {
+		t.Fatalf("unexpected labels %v", *l)
+	}
+	l = labels["dbd9381f9ea9788f"]
+	if !slices.Equal(l.tags, []string{"AR-23 Liberator"}) ||
+	   !slices.Equal(l.categories, []string{"weapons"}) {
+		t.Fatalf("unexpected labels %v", *l)
+	}
+}
+
+func TestSplitLabels(t *testing.T) {
+	labels := splitLabels(" weapons;; stratagems ;weapons;")
+	if !slices.Equal(labels, []string{"weapons", "stratagems"}) {
+		t.Fatalf("unexpected labels %v", labels)
+	}
+	if labels := splitLabels(""); len(labels) != 0 {
+		t.Fatalf("expect no label, got %v", labels)
+	}
+}
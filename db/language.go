@@ -0,0 +1,51 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+
+	database "dekr0/hd2_audio_db/internal/complete"
+)
+
+// WriteLocalizedSoundbanks writes every sound bank that is the same bank as 
+// sound bank (aid, fid) in another language, including itself, one per line 
+// as `language | aid | fid | path`.
+func WriteLocalizedSoundbanks(
+	ctx context.Context,
+	aid string,
+	fid uint64,
+	w io.Writer,
+) error {
+	c, err := conn()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	q := database.New(c)
+	bank, err := q.GetSoundbank(ctx, database.GetSoundbankParams{
+		Aid: aid, Fid: int64(fid),
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf(
+				"Sound bank (aid %s, fid %d) is not in the database", aid, fid,
+			)
+		}
+		return err
+	}
+	banks, err := q.GetSoundbankGroup(ctx, bank.GroupPath)
+	if err != nil {
+		return err
+	}
+	for _, b := range banks {
+		if _, err := fmt.Fprintf(
+			w, "%s | %s | %d | %s\n", b.Language, b.Aid, uint64(b.Fid), b.Path,
+		); err != nil {
+			return err
+		}
+	}
+	return c.Close()
+}
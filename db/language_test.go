@@ -0,0 +1,14 @@
+package db
+
+import "testing"
+
+func TestGroupPath(t *testing.T) {
+	en := groupPath("content/audio/vo/english(us)/stratagem")
+	de := groupPath("content/audio/vo/german/stratagem")
+	if en != de || en != "content_audio_vo_stratagem" {
+		t.Fatalf("expect the same group path, got %s and %s", en, de)
+	}
+	if p := groupPath("content/audio/weapons"); p != "content_audio_weapons" {
+		t.Fatalf("unexpected group path %s", p)
+	}
+}
@@ -51,6 +51,11 @@ func WriteArchives(ctx context.Context, data string) error {
 	timeout, cancel := context.WithTimeout(ctx, time.Second * 8)
 	defer cancel()
 
+	labels, err := loadArchiveLabels(data)
+	if err != nil {
+		return err
+	}
+
 	sem := make(chan struct{}, MaxDirReader)
 
 	var w sync.WaitGroup
@@ -68,9 +73,9 @@ func WriteArchives(ctx context.Context, data string) error {
 			return timeout.Err()
 		case sem <- struct{}{}:
 			w.Add(1)
-			go writeArchives(&w, ctx, data, entries)
+			go writeArchives(&w, ctx, data, entries, labels)
 		default:
-			writeArchives(nil, ctx, data, entries)
+			writeArchives(nil, ctx, data, entries, labels)
 		}
 	}
 
@@ -84,6 +89,7 @@ func writeArchives(
 	ctx context.Context,
 	data string,
 	entries []os.DirEntry,
+	labels map[string]*archiveLabel,
 ) {
 	if w != nil {
 		defer w.Done()
@@ -104,6 +110,7 @@ func writeArchives(
 	}
 
 	withTx := q.WithTx(tx)
+	ids := newLabelIDs(withTx)
 	for _, entry := range entries {
 		if entry.IsDir() { continue }
 
@@ -126,10 +133,18 @@ func writeArchives(
 			Categories: "",
 			DateModified: stat.ModTime().Format(time.UnixDate),
 		}
+		if l, in := labels[entry.Name()]; in {
+			p.Tags = strings.Join(l.tags, LabelSeparator)
+			p.Categories = strings.Join(l.categories, LabelSeparator)
+		}
 		if err := withTx.InsertArchive(ctx, p); err != nil {
 			slog.Error("Failed to insert archive", "archive", entry.Name())
 			panic(err)
 		}
+		if err := writeArchiveLabels(ctx, ids, p.Aid, p.Tags, p.Categories); err != nil {
+			slog.Error("Failed to insert archive labels", "archive", entry.Name())
+			panic(err)
+		}
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -157,26 +172,60 @@ func Generate(ctx context.Context, data string) error {
 	}
 	c.Close()
 
+	tocInsert := []database.InsertArchiveTocParams{}
+	typeInsert := []database.InsertAssetTypeParams{}
 	assetInsert := []database.InsertAssetParams{}
 	bankInsert := []database.InsertSoundbankParams{}
 	hircInsert := []database.InsertHierarchyParams{}
 	soundInsert := []database.InsertSoundParams{}
+	stringInsert := []database.InsertStringParams{}
+	locationInsert := []database.InsertSourceLocationParams{}
 	for _, archive := range archives {
 		select {
 		case <- ctx.Done():
 			return ctx.Err()
 		default:
 			slog.Info(fmt.Sprintf("Extracting information from archive %s", archive.Aid))
-			localAssetInsert, localBankInsert, localHircInsert, localSoundInsert := gather(
+			localToc, localTypeInsert, localAssetInsert, localBankInsert, localHircInsert, localSoundInsert, localStringInsert, localLocationInsert := gatherMmap(
+				ctx,
 				filepath.Join(data, archive.Aid),
 				archive.Aid,
 			)
+			for i := range localBankInsert {
+				localBankInsert[i].Categories = archive.Categories
+			}
+			tocInsert = append(tocInsert, localToc)
+			typeInsert = append(typeInsert, localTypeInsert...)
 			assetInsert = append(assetInsert, localAssetInsert...)
 			bankInsert = append(bankInsert, localBankInsert...)
 			hircInsert = append(hircInsert, localHircInsert...)
 			soundInsert = append(soundInsert, localSoundInsert...)
+			stringInsert = append(stringInsert, localStringInsert...)
+			locationInsert = append(locationInsert, localLocationInsert...)
 		}
 	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := markUnchanged(ctx, assetInsert); err != nil {
+		return err
+	}
+	locationInsert = resolveStreams(locationInsert, assetInsert)
+	prev, err := loadPrevSources(ctx, assetInsert)
+	if err != nil {
+		return err
+	}
+	audioInsert, fingerprintInsert, loudnessInsert, waveformInsert, err := sourceAudioParams(
+		ctx, data, locationInsert, prev,
+	)
+	if err != nil {
+		return err
+	}
+	duplicateInsert, err := duplicateParams(fingerprintInsert)
+	if err != nil {
+		return err
+	}
 
 	c, err = conn()
 	if err != nil {
@@ -189,6 +238,16 @@ func Generate(ctx context.Context, data string) error {
 		return err
 	}
 	qTx := database.New(c).WithTx(tx)
+	for _, t := range tocInsert {
+		if err := qTx.InsertArchiveToc(ctx, t); err != nil {
+			panic(err)
+		}
+	}
+	for _, t := range typeInsert {
+		if err := qTx.InsertAssetType(ctx, t); err != nil {
+			panic(err)
+		}
+	}
 	for _, a := range assetInsert {
 		if err := qTx.InsertAsset(ctx, a); err != nil {
 			panic(err)
@@ -204,11 +263,73 @@ func Generate(ctx context.Context, data string) error {
 			panic(err)
 		}
 	}
+	ids := newLabelIDs(qTx)
+	for _, b := range bankInsert {
+		for _, category := range splitLabels(b.Categories) {
+			id, err := ids.category(ctx, category)
+			if err != nil {
+				panic(err)
+			}
+			if err := qTx.InsertSoundbankCategory(ctx, database.InsertSoundbankCategoryParams{
+				Aid: b.Aid, Fid: b.Fid, CategoryID: id,
+			}); err != nil {
+				panic(err)
+			}
+		}
+	}
+	for _, h := range hircInsert {
+		for _, tag := range splitLabels(h.Tags) {
+			id, err := ids.tag(ctx, tag)
+			if err != nil {
+				panic(err)
+			}
+			if err := qTx.InsertHierarchyTag(ctx, database.InsertHierarchyTagParams{
+				Aid: h.Aid, Fid: h.Fid, Hid: h.Hid, TagID: id,
+			}); err != nil {
+				panic(err)
+			}
+		}
+	}
 	for _, s := range soundInsert {
 		if err := qTx.InsertSound(ctx, s); err != nil {
 			panic(err)
 		}
 	}
+	for _, s := range stringInsert {
+		if err := qTx.InsertString(ctx, s); err != nil {
+			panic(err)
+		}
+	}
+	for _, l := range locationInsert {
+		if err := qTx.InsertSourceLocation(ctx, l); err != nil {
+			panic(err)
+		}
+	}
+	for _, a := range audioInsert {
+		if err := qTx.InsertSourceAudio(ctx, a); err != nil {
+			panic(err)
+		}
+	}
+	for _, f := range fingerprintInsert {
+		if err := qTx.InsertSourceFingerprint(ctx, f); err != nil {
+			panic(err)
+		}
+	}
+	for _, d := range duplicateInsert {
+		if err := qTx.InsertSourceDuplicate(ctx, d); err != nil {
+			panic(err)
+		}
+	}
+	for _, l := range loudnessInsert {
+		if err := qTx.InsertSourceLoudness(ctx, l); err != nil {
+			panic(err)
+		}
+	}
+	for _, w := range waveformInsert {
+		if err := qTx.InsertSourceWaveform(ctx, w); err != nil {
+			panic(err)
+		}
+	}
 	if err := tx.Commit(); err != nil {
 		panic(err)
 	}
@@ -234,8 +355,77 @@ func gather(p string, aid string) (
 	r := wio.NewReader(f, wio.ByteOrder)
 
 	parseHeader(&a, r)
-	assetInsert = make([]database.InsertAssetParams, len(a.Headers))
+	assetInsert = assetParams(&a, aid)
 	bankInsert = make([]database.InsertSoundbankParams, len(a.SoundBnks))
+
+	bankInsert, hircInsert, soundInsert = parseBanks(&a, bankInsert, r, aid, p)
+
+	return assetInsert, bankInsert, hircInsert, soundInsert
+}
+
+// gatherMmap is the same as gather except the archive is memory mapped. ToC 
+// and sound banks are decoded directly from the mapping, and all bank parsers 
+// share the same mapping instead of re-opening the archive. Hashing stops 
+// early if `ctx` is done.
+func gatherMmap(ctx context.Context, p string, aid string) (
+	tocInsert database.InsertArchiveTocParams,
+	typeInsert []database.InsertAssetTypeParams,
+	assetInsert []database.InsertAssetParams,
+	bankInsert []database.InsertSoundbankParams,
+	hircInsert []database.InsertHierarchyParams,
+	soundInsert []database.InsertSoundParams,
+	stringInsert []database.InsertStringParams,
+	locationInsert []database.InsertSourceLocationParams,
+) {
+	a := parser.Archive{}
+
+	m, err := wio.OpenMmapReader(p)
+	if err != nil {
+		slog.Error("Failed to map archive", "path", p)
+		panic(err)
+	}
+	defer m.Close()
+
+	parser.ParseArchiveMmap(&a, m)
+	tocInsert, typeInsert = tocParams(&a, aid)
+	assetInsert = assetParams(&a, aid)
+	if HashAssets {
+		if err := hashAssets(ctx, assetInsert, &a, m, p); err != nil {
+			slog.Warn("Stopped hashing assets", "path", p, "error", err)
+		}
+	}
+	bankInsert = make([]database.InsertSoundbankParams, len(a.SoundBnks))
+
+	bankInsert, hircInsert, soundInsert, locationInsert = parseBanksMmap(&a, bankInsert, m, aid, p)
+	stringInsert = stringParams(&a, m, aid, p)
+
+	return tocInsert, typeInsert, assetInsert, bankInsert, hircInsert, soundInsert, stringInsert, locationInsert
+}
+
+func tocParams(a *parser.Archive, aid string) (
+	database.InsertArchiveTocParams, []database.InsertAssetTypeParams,
+) {
+	tocInsert := database.InsertArchiveTocParams{
+		Aid: aid,
+		NumTypes: int64(a.NumTypes),
+		NumFiles: int64(a.NumFiles),
+		Unknown: int64(a.Unknown),
+		Unk4Data: slices.Clone(a.Unk4Data[:]),
+	}
+	typeInsert := make([]database.InsertAssetTypeParams, len(a.AssetTypeCnts))
+	for i, t := range a.AssetTypeCnts {
+		typeInsert[i].Aid = aid
+		typeInsert[i].Tid = int64(t.Type)
+		typeInsert[i].Num = int64(t.Num)
+		typeInsert[i].Unknown01 = int64(t.Unknown)
+		typeInsert[i].Unknown02 = int64(t.UnknownU32A)
+		typeInsert[i].Unknown03 = int64(t.UnknownU32B)
+	}
+	return tocInsert, typeInsert
+}
+
+func assetParams(a *parser.Archive, aid string) []database.InsertAssetParams {
+	assetInsert := make([]database.InsertAssetParams, len(a.Headers))
 	for i, a := range a.Headers {
 		assetInsert[i].Aid = aid
 		assetInsert[i].Fid = int64(a.FileID)
@@ -251,14 +441,11 @@ func gather(p string, aid string) (
 		assetInsert[i].Unknown02 = int64(a.UnknownU64B)
 		assetInsert[i].DataSize = int64(a.DataSize)
 		assetInsert[i].StreamSize = int64(a.StreamSize)
-		assetInsert[i].GpuRsrcSize = int64(a.StreamSize)
+		assetInsert[i].GpuRsrcSize = int64(a.GPURsrcSize)
 		assetInsert[i].Unknown03 = int64(a.UnknownU32A)
 		assetInsert[i].Unknown04 = int64(a.UnknownU32B)
 	}
-
-	bankInsert, hircInsert, soundInsert = parseBanks(&a, bankInsert, r, aid, p)
-
-	return assetInsert, bankInsert, hircInsert, soundInsert
+	return assetInsert
 }
 
 func gatherInPlace(p string) () {
@@ -286,6 +473,7 @@ type ShareRsrc struct {
 	m           sync.Mutex
 	hircInsert  []database.InsertHierarchyParams
 	soundInsert []database.InsertSoundParams
+	locationInsert []database.InsertSourceLocationParams
 }
 
 func parseBanks(
@@ -322,13 +510,7 @@ func parseBanks(
 					)
 					panic(err)
 				}
-				path = string(
-					bytes.ReplaceAll(
-						bytes.ReplaceAll(data[5:], []byte{'\u0000'}, []byte{}),
-						[]byte{'/'},
-						[]byte{'_'},
-					),
-				)
+				path = dependencyPath(data)
 				break
 			}
 		}
@@ -357,29 +539,7 @@ func parseBanks(
 			}
 			HircMetric += int(hirc.Header)
 
-			Fid := int64(h.FileID)
-			hircInsert := make([]database.InsertHierarchyParams, len(hirc.Hierarchy))
-			for i, h := range hirc.Hierarchy {
-				hircInsert[i] = database.InsertHierarchyParams{
-					Aid: aid,
-					Fid: Fid,
-					Hid: int64(h.ID),
-					Type: parser.HircTypeName[h.Type],
-					Parent: int64(h.Parent),
-					Label: "",
-					Tags: "",
-					Description: "",
-				}
-			}
-			soundInsert := make([]database.InsertSoundParams, len(hirc.Sound))
-			for i, s := range hirc.Sound {
-				soundInsert[i] = database.InsertSoundParams{
-					Aid: aid,
-					Fid: Fid,
-					Hid: int64(hirc.Hierarchy[i].ID),
-					Sid: int64(s.SourceID),
-				}
-			}
+			hircInsert, soundInsert := hircParams(aid, h.FileID, hirc)
 			shareRsrc.m.Lock()
 			shareRsrc.hircInsert = append(shareRsrc.hircInsert, hircInsert...)
 			shareRsrc.soundInsert = append(shareRsrc.soundInsert, soundInsert...)
@@ -409,6 +569,19 @@ func parseBank(
 		slog.Warn("Missing hierarchy", "path", p, "aid", aid, "fid", fid)
 		return
 	}
+	hircInsert, soundInsert := hircParams(aid, fid, hirc)
+	s.m.Lock()
+	s.hircInsert = append(s.hircInsert, hircInsert...)
+	s.soundInsert = append(s.soundInsert, soundInsert...)
+	s.m.Unlock()
+}
+
+// hircParams converts a parsed hierarchy of the sound bank (aid, fid) into 
+// records of `hierarchy` and `sound` table.
+func hircParams(aid string, fid uint64, hirc *parser.HIRC) (
+	[]database.InsertHierarchyParams,
+	[]database.InsertSoundParams,
+) {
 	Fid := int64(fid)
 	hircInsert := make([]database.InsertHierarchyParams, len(hirc.Hierarchy))
 	for i, h := range hirc.Hierarchy {
@@ -428,13 +601,225 @@ func parseBank(
 		soundInsert[i] = database.InsertSoundParams{
 			Aid: aid,
 			Fid: Fid,
-			Hid: int64(hirc.Hierarchy[i].ID),
+			Hid: int64(hirc.Hierarchy[s.Idx].ID),
 			Sid: int64(s.SourceID),
 		}
 	}
+	return hircInsert, soundInsert
+}
+
+// wwiseDependency parses the data of a wwise dependency. A malformed payload 
+// falls back to the old heuristic (skip 5 bytes and strip NULs) so that one 
+// bad asset does not stop generation.
+func wwiseDependency(data []byte) *parser.WwiseDependency {
+	d, err := parser.ParseWwiseDependency(data)
+	if err == nil {
+		return d
+	}
+	slog.Warn("Malformed wwise dependency", "error", err)
+	d = &parser.WwiseDependency{}
+	if len(data) > 5 {
+		d.Path = string(bytes.ReplaceAll(data[5:], []byte{'\u0000'}, []byte{}))
+	}
+	return d
+}
+
+// dependencyPath returns the path stored in a wwise dependency as a slug 
+// that is safe to use as a file name.
+func dependencyPath(data []byte) string {
+	return wwiseDependency(data).Slug()
+}
+
+// groupPath removes language segments from a raw wwise dependency path so 
+// that the same bank in different languages maps to the same path.
+func groupPath(raw string) string {
+	segs := slices.DeleteFunc(strings.Split(raw, "/"), parser.IsLanguageSegment)
+	return strings.Join(segs, "_")
+}
+
+// bankLanguage reads the sound bank ID and the language from BKHD. Unknown 
+// language IDs are kept as hex so they can still be grouped.
+func bankLanguage(m *wio.MmapReader, h *parser.AssetHeader) (int64, string, error) {
+	if h.DataSize < 16 {
+		return 0, "", fmt.Errorf("Sound bank is too small")
+	}
+	r, err := m.NewInPlaceReader(uint(h.DataOffset + 16), uint(h.DataSize - 16))
+	if err != nil {
+		return 0, "", err
+	}
+	bkhd, err := parser.ParseBKHD(r)
+	if err != nil {
+		return 0, "", err
+	}
+	language, ok := parser.LanguageName(bkhd.LanguageID)
+	if !ok {
+		language = fmt.Sprintf("%#08x", bkhd.LanguageID)
+	}
+	return int64(bkhd.BankID), language, nil
+}
+
+// stringParams parses every string table asset of the archive. A malformed 
+// string table is skipped with a warning.
+func stringParams(
+	a *parser.Archive, m *wio.MmapReader, aid string, p string,
+) []database.InsertStringParams {
+	stringInsert := []database.InsertStringParams{}
+	for i := range a.Headers {
+		h := &a.Headers[i]
+		if h.TypeID != uint64(parser.AssetTypeStrings) {
+			continue
+		}
+		data, err := m.Slice(uint(h.DataOffset), uint(h.DataSize))
+		if err != nil {
+			slog.Warn("Failed to read string table", "path", p, "fid", h.FileID, "error", err)
+			continue
+		}
+		s, err := parser.ParseStrings(data)
+		if err != nil {
+			slog.Warn("Failed to parse string table", "path", p, "fid", h.FileID, "error", err)
+			continue
+		}
+		language, ok := parser.StringLanguage(s.Language)
+		if !ok {
+			language = fmt.Sprintf("%#08x", s.Language)
+		}
+		for _, e := range s.Entries {
+			stringInsert = append(stringInsert, database.InsertStringParams{
+				Aid: aid,
+				Fid: int64(h.FileID),
+				Language: language,
+				Sid: int64(e.ID),
+				Text: e.Text,
+			})
+		}
+	}
+	return stringInsert
+}
+
+func parseBanksMmap(
+	a *parser.Archive,
+	bankInsert []database.InsertSoundbankParams,
+	m *wio.MmapReader,
+	aid string,
+	p string,
+) (
+	[]database.InsertSoundbankParams,
+	[]database.InsertHierarchyParams,
+	[]database.InsertSoundParams,
+	[]database.InsertSourceLocationParams,
+) {
+	sem := make(chan struct{}, MaxBankParser)
+
+	shareRsrc := ShareRsrc{
+		hircInsert: []database.InsertHierarchyParams{}, 
+		soundInsert: []database.InsertSoundParams{},
+		locationInsert: []database.InsertSourceLocationParams{},
+	}
+
+	var w sync.WaitGroup
+
+	for i, b := range a.SoundBnks {
+		h := &a.Headers[b]
+
+		var path string = ""
+		var rawPath string = ""
+		var group string = ""
+		for _, d := range a.Deps {
+			dh := &a.Headers[d]
+			if dh.FileID == h.FileID {
+				data, err := m.Slice(uint(dh.DataOffset), uint(dh.DataSize))
+				if err != nil {
+					slog.Error(
+						"Failed to read data of wwise dependency",
+						"path", p,
+						"fid", dh.FileID,
+					)
+					panic(err)
+				}
+				dep := wwiseDependency(data)
+				path = dep.Slug()
+				rawPath = dep.Path
+				group = groupPath(dep.Path)
+				break
+			}
+		}
+		if path == "" {
+			path = fmt.Sprintf("bank_%d_%s", h.FileID, aid)
+			group = path
+		}
+
+		bankID, language, err := bankLanguage(m, h)
+		if err != nil {
+			slog.Warn(
+				"Failed to read BKHD",
+				"path", p,
+				"fid", h.FileID,
+				"error", err,
+			)
+		}
+
+		bankInsert[i].Aid = aid
+		bankInsert[i].Fid = int64(h.FileID)
+		bankInsert[i].Path = path
+		bankInsert[i].Name = ""
+		bankInsert[i].Categories = ""
+		bankInsert[i].BankID = bankID
+		bankInsert[i].Language = language
+		bankInsert[i].GroupPath = group
+		bankInsert[i].RawPath = rawPath
+
+		slog.Info(fmt.Sprintf("Parsing sound bank %s (file id %d)", path, h.FileID))
+		select {
+		case sem <- struct{}{}:
+			w.Add(1)
+			go func() {
+				defer w.Done()
+				parseBankMmap(&shareRsrc, aid, p, rawPath, h, m)
+				<- sem
+			}()
+		default:
+			parseBankMmap(&shareRsrc, aid, p, rawPath, h, m)
+		}
+	}
+	w.Wait()
+
+	return bankInsert, shareRsrc.hircInsert, shareRsrc.soundInsert, shareRsrc.locationInsert
+}
+
+func parseBankMmap(
+	s *ShareRsrc,
+	aid string, p string,
+	rawPath string,
+	h *parser.AssetHeader,
+	m *wio.MmapReader,
+) {
+	if h.DataSize < 16 {
+		slog.Warn("Sound bank is too small", "path", p, "aid", aid, "fid", h.FileID)
+		return
+	}
+	r, err := m.NewInPlaceReader(uint(h.DataOffset + 16), uint(h.DataSize - 16))
+	if err != nil {
+		slog.Error("Sound bank is out of range", "path", p, "aid", aid, "fid", h.FileID)
+		panic(err)
+	}
+	hirc := parser.ParseBankInPlace(r, h.DataOffset + uint64(h.DataSize))
+	if hirc == nil {
+		slog.Warn("Missing hierarchy", "path", p, "aid", aid, "fid", h.FileID)
+		return
+	}
+	hircInsert, soundInsert := hircParams(aid, h.FileID, hirc)
+
+	r.AbsSeekUnsafe(0)
+	idx, err := parser.ParseMediaIndex(r, r.Cap())
+	if err != nil {
+		slog.Warn("Failed to parse media index", "path", p, "aid", aid, "fid", h.FileID, "error", err)
+	}
+	locationInsert := locationParams(aid, h, rawPath, hirc, idx)
+
 	s.m.Lock()
 	s.hircInsert = append(s.hircInsert, hircInsert...)
 	s.soundInsert = append(s.soundInsert, soundInsert...)
+	s.locationInsert = append(s.locationInsert, locationInsert...)
 	s.m.Unlock()
 }
 
@@ -456,13 +841,7 @@ func parseBanksInPlace(a *parser.Archive, r *wio.Reader, p string) {
 					)
 					panic(err)
 				}
-				path = string(
-					bytes.ReplaceAll(
-						bytes.ReplaceAll(data[5:], []byte{'\u0000'}, []byte{}),
-						[]byte{'/'},
-						[]byte{'_'},
-					),
-				)
+				path = dependencyPath(data)
 				break
 			}
 		}
@@ -519,72 +898,58 @@ func ExportAllSoundbank(ctx context.Context, data string, dest string) error {
 		return fmt.Errorf("%s is a file", dest)
 	}
 
-	f, err := os.Open(data)
-	if err != nil {
+	timeout, cancel := context.WithTimeout(ctx, time.Second * 120)
+	defer cancel()
+
+	idx := LoadTocIndex()
+	if err := idx.Refresh(timeout, data); err != nil {
 		return err
 	}
+	if err := idx.Save(); err != nil {
+		slog.Warn("Failed to save ToC index", "error", err)
+	}
 
-	timeout, cancel := context.WithTimeout(ctx, time.Second * 120)
-	defer cancel()
 	var w sync.WaitGroup
-
 	sem := make(chan struct{}, MaxArchiveReder)
-	for {
-		entries, err := f.ReadDir(1024)
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return err
-		}
-		for _, entry := range entries {
-			if entry.IsDir() { continue }
-
-			ext := filepath.Ext(entry.Name())
-			if strings.Compare(ext, ".stream") == 0 { continue }
-			if strings.Compare(ext, ".gpu_resources") == 0 { continue }
-			if strings.Compare(ext, ".ini") == 0 { continue }
-			if strings.Compare(ext, ".data") == 0 { continue }
-			if strings.Contains(ext, "patch") { continue }
-
-			select {
-			case <- timeout.Done():
-				return timeout.Err()
-			case sem <- struct{}{}:
-				w.Add(1)
-				go exportSoundbanks(&w, ctx, filepath.Join(data, entry.Name()), dest)
-			default:
-				exportSoundbanks(nil, ctx, filepath.Join(data, entry.Name()), dest)
-			}
+	for _, aid := range idx.Soundbanks() {
+		a := idx.Entries[aid].Archive
+		select {
+		case <- timeout.Done():
+			return timeout.Err()
+		case sem <- struct{}{}:
+			w.Add(1)
+			go exportSoundbanks(&w, ctx, filepath.Join(data, aid), a, dest)
+		default:
+			exportSoundbanks(nil, ctx, filepath.Join(data, aid), a, dest)
 		}
 	}
 	w.Wait()
 	return nil
 }
 
+// exportSoundbanks exports all sound banks of archive `p`. `a` is the parsed 
+// ToC of the archive, usually from TocIndex, so the ToC is not read again.
 func exportSoundbanks(
 	w *sync.WaitGroup,
 	ctx context.Context,
 	p string,
+	a *parser.Archive,
 	dest string,
 ) {
 	if w != nil {
 		defer w.Done()
 	}
 
-	f, err := os.Open(p)
+	m, err := wio.OpenMmapReader(p)
 	if err != nil {
 		slog.Error("Failed to open archive", "path", p)
 		panic(err)
 	}
-	defer f.Close()
-
-	a := parser.Archive{}
-	r := wio.NewReader(f, wio.ByteOrder)
-
-	parseHeader(&a, r)
+	defer m.Close()
 
 	var ww sync.WaitGroup
+	// All writers must finish before the mapping is released
+	defer ww.Wait()
 	sem := make(chan struct{}, MaxBankWriter)
 
 	var bh *parser.AssetHeader
@@ -598,10 +963,8 @@ func exportSoundbanks(
 			wh = &a.Headers[w]
 
 			if wh.FileID == bh.FileID {
-				r.AbsSeekUnsafe(uint(wh.DataOffset))
-
-				data := make([]byte, wh.DataSize, wh.DataSize)
-				if err := r.ReadFull(data); err != nil {
+				data, err := m.Slice(uint(wh.DataOffset), uint(wh.DataSize))
+				if err != nil {
 					slog.Error(
 						"Failed to read data of wwise dependency",
 						"path", p,
@@ -610,13 +973,7 @@ func exportSoundbanks(
 					panic(err)
 				}
 
-				path = string(
-					bytes.ReplaceAll(
-						bytes.ReplaceAll(data[5:], []byte{'\u0000'}, []byte{}),
-						[]byte{'/'},
-						[]byte{'_'},
-					),
-				)
+				path = dependencyPath(data)
 				path = strings.ReplaceAll(path, "content_audio_", "")
 				path += ".bnk"
 
@@ -624,74 +981,75 @@ func exportSoundbanks(
 			}
 		}
 
-		sf, err := os.Open(p)
+		if bh.DataSize < 16 {
+			slog.Error("Sound bank is too small", "path", p, "fid", bh.FileID)
+			continue
+		}
+		data, err := m.Slice(uint(bh.DataOffset + 16), uint(bh.DataSize - 16))
 		if err != nil {
-			slog.Error("Failed to open archive", "path", p)
-			panic(err)
+			slog.Error(
+				"Failed to read data of sound bank",
+				"path", p,
+				"fid", bh.FileID,
+				"error", err,
+			)
+			continue
 		}
 
-		sr := wio.NewReader(
-			io.NewSectionReader(
-				sf,
-				int64(bh.DataOffset + 16),
-				int64(bh.DataSize - 16),
-			),
-			wio.ByteOrder,
-		)
-
 		select {
 		case <- ctx.Done():
 			return
 		case sem <- struct{}{}:
 			ww.Add(1)
 			go exportSoundbank(
-				&ww, ctx, 
-				sf, sr, 
+				&ww, ctx,
+				data,
 				filepath.Base(p), bh.FileID, filepath.Join(dest, path),
 			)
 		default:
 			exportSoundbank(
 				nil, ctx,
-				sf, sr,
+				data,
 				filepath.Base(p), bh.FileID, filepath.Join(dest, path),
 			)
 		}
 	}
-	ww.Wait()
 }
 
+// exportSoundbank writes the data of a sound bank (without the 16 bytes header 
+// from the game) into `p`. Bank generator version in BKHD is patched so that 
+// the output can be opened by wwiser. If SourceFormat is not wem, embedded 
+// sources are converted into a folder named after `p` without `.bnk`.
 func exportSoundbank(
 	w *sync.WaitGroup,
 	ctx context.Context,
-	i *os.File,
-	r *wio.Reader,
+	data []byte,
 	aid string,
 	fid uint64,
 	p string,
 ) {
 	if w != nil { defer w.Done() }
-	if i != nil { defer i.Close() }
 
-	f, err := os.Create(p)
-	if err != nil {
+	if len(data) < 12 {
 		slog.Error(
-			"Failed to create file",
+			"Failed to read BKHD",
 			"path", p,
 			"aid", aid,
 			"fid", fid,
-			"error", err,
 		)
 		return
 	}
-	writer := bufio.NewWriterSize(f, 4096)
-	defer f.Close()
 
-	// BKHD
-	data := make([]byte, 12, 12)
-	_, err = r.Read(data)
+	select {
+	case <- ctx.Done():
+		return
+	default:
+	}
+
+	f, err := os.Create(p)
 	if err != nil {
 		slog.Error(
-			"Failed to read BKHD",
+			"Failed to create file",
 			"path", p,
 			"aid", aid,
 			"fid", fid,
@@ -699,16 +1057,24 @@ func exportSoundbank(
 		)
 		return
 	}
-	data[0x08] = 0x9A
-	data[0x09] = 0x00
-	data[0x0A] = 0x00
-	data[0x0B] = 0x00
-	_, err = writer.Write(data)
+	defer f.Close()
+
+	// BKHD. data could be a slice of read only mapping so patch a copy
+	bkhd := slices.Clone(data[:12])
+	bkhd[0x08] = 0x9A
+	bkhd[0x09] = 0x00
+	bkhd[0x0A] = 0x00
+	bkhd[0x0B] = 0x00
+
+	writer := bufio.NewWriterSize(f, 4096)
+	if _, err = writer.Write(bkhd); err == nil {
+		_, err = writer.Write(data[12:])
+	}
 	if err != nil {
 		slog.Error(
 			"Failed to write all bytes",
 			"path", p,
-			"aid", aid, 
+			"aid", aid,
 			"fid", fid,
 			"error", err,
 		)
@@ -725,57 +1091,14 @@ func exportSoundbank(
 		return
 	}
 
-	data = slices.Grow(data, 4096 - 12)
-	for i := range cap(data) {
-		if i < len(data) {
-			data[i] = 0
-		} else {
-			data = append(data, 0)
-		}
-	}
-	for {
-		select {
-		case <- ctx.Done():
-			return
-		default:
-		}
-		nread, err := r.Read(data)
-		if nread < len(data) {
-			fmt.Print()
-		}
-		if err != nil {
-			if err == io.EOF {
-				if nread > 0 {
-					_, err = writer.Write(data[:nread:nread])
-					slog.Error(
-						"Failed to write all bytes",
-						"path", p,
-						"aid", aid,
-						"fid", fid,
-						"error", err,
-					)
-				}
-				return
-			}
-			slog.Error(
-				"Failed to write all bytes",
-				"path", p,
-				"aid", aid,
-				"fid", fid,
-				"error", err,
-			)
-			return
-		}
-		_, err = f.Write(data[:nread:nread])
-		if err != nil {
-			slog.Error(
-				"Failed to write all bytes",
-				"path", p,
-				"aid", aid,
-				"fid", fid,
-				"error", err,
-			)
-		}
+	if _, err := convertMedia(data, strings.TrimSuffix(p, ".bnk")); err != nil {
+		slog.Error(
+			"Failed to convert embedded sources",
+			"path", p,
+			"aid", aid,
+			"fid", fid,
+			"error", err,
+		)
 	}
 }
 
@@ -815,27 +1138,42 @@ func ExportSoundbanksTUI(ctx context.Context, data string, dest string) error {
 		c <- &Result{result, err}
 	}()
 
-	f, err := os.Open(data)
-	if err != nil {
-		return err
-	}
-
-	var r *Result
-	for r == nil {
-		r = poll(ctx, c)
-		if r != nil {
-			break
-		}
+	// Archives known by the index are listed right away. Refreshing the index 
+	// runs in the background and archives that are new or changed since last 
+	// run are appended to the picker once it is done. On first run the index 
+	// is empty so every archive in the plain directory listing is listed 
+	// instead, including those without sound bank.
+	idx := LoadTocIndex()
+	refreshCtx, cancelRefresh := context.WithCancel(ctx)
+	defer cancelRefresh()
+	done := make(chan error, 1)
+	go func() {
+		done <- idx.Refresh(refreshCtx, data)
+	}()
 
-		entries, err := f.ReadDir(1024)
+	aids := idx.Soundbanks()
+	if len(aids) == 0 {
+		slog.Info("ToC index is empty. List every archive while it is being built.")
+		aids, err = listArchives(data)
 		if err != nil {
-			if err == io.EOF {
-				break
-			}
 			return err
 		}
-
-		r = writeEntryToFzF(ctx, c, cmd, entries, p)
+	}
+	listed := make(map[string]struct{})
+	r := writeEntryToFzF(ctx, c, cmd, aids, listed, p)
+	if r == nil {
+		select {
+		case <- ctx.Done():
+			return ctx.Err()
+		case r = <- c:
+		case err := <- done:
+			done <- err
+			if err != nil {
+				slog.Warn("Failed to refresh ToC index", "error", err)
+			}
+			r = writeEntryToFzF(ctx, c, cmd, idx.Soundbanks(), listed, p)
+			p.Close()
+		}
 	}
 
 	if r == nil {
@@ -857,16 +1195,27 @@ func ExportSoundbanksTUI(ctx context.Context, data string, dest string) error {
 		if sel == "" {
 			continue
 		}
+		a, err := idx.Lookup(data, sel)
+		if err != nil {
+			slog.Error("Failed to read ToC of archive", "archive", sel, "error", err)
+			continue
+		}
 		select {
 		case sem <- struct{}{}:
 			w.Add(1)
-			go exportSoundbanks(&w, ctx, filepath.Join(data, sel), dest)
+			go exportSoundbanks(&w, ctx, filepath.Join(data, sel), a, dest)
 		default:
-			exportSoundbanks(nil, ctx, filepath.Join(data, sel), dest)
+			exportSoundbanks(nil, ctx, filepath.Join(data, sel), a, dest)
 		}
 	}
 	w.Wait()
 
+	cancelRefresh()
+	<- done
+	if err := idx.Save(); err != nil {
+		slog.Warn("Failed to save ToC index", "error", err)
+	}
+
 	return nil
 }
 
@@ -881,33 +1230,29 @@ func poll(ctx context.Context, c chan *Result) *Result {
 	}
 }
 
+// writeEntryToFzF writes archive IDs that are not in `listed` into the stdin 
+// of fzf, one per line, and marks them as listed.
 func writeEntryToFzF(
 	ctx context.Context,
 	c chan *Result,
 	cmd *exec.Cmd,
-	entries []os.DirEntry,
+	aids []string,
+	listed map[string]struct{},
 	p io.WriteCloser,
 ) *Result {
-	for i, entry := range entries {
+	for _, aid := range aids {
 		select {
 		case <- ctx.Done():
 			return &Result{[]byte{}, ctx.Err()}
 		case r := <- c:
 			return r
 		default:
-			ext := filepath.Ext(entry.Name())
-			if strings.Compare(ext, ".stream") == 0 { continue }
-			if strings.Compare(ext, ".gpu_resources") == 0 { continue }
-			if strings.Compare(ext, ".ini") == 0 { continue }
-			if strings.Compare(ext, ".data") == 0 { continue }
-			if strings.Contains(ext, "patch") { continue }
-
-			out := entry.Name()
-			if i < len(entries) - 1 {
-				out += "\n"
+			if _, in := listed[aid]; in {
+				continue
 			}
+			listed[aid] = struct{}{}
 
-			if _, err := p.Write([]byte(out)); err != nil {
+			if _, err := p.Write([]byte(aid + "\n")); err != nil {
 				cmd.Cancel()
 				return &Result{[]byte{}, err}
 			}
@@ -994,6 +1339,7 @@ func ExportSoundbanksTUIDB(ctx context.Context, data string, dest string) error
 	cmd.Cancel()
 	p.Close()
 
+	idx := LoadTocIndex()
 	sem := make(chan struct{}, MaxArchiveReder)
 	var w sync.WaitGroup
 	marks := []string{}
@@ -1011,15 +1357,25 @@ func ExportSoundbanksTUIDB(ctx context.Context, data string, dest string) error
 		}
 		marks = append(marks, splits[0])
 		
+		a, err := idx.Lookup(data, splits[0])
+		if err != nil {
+			slog.Error("Failed to read ToC of archive", "archive", splits[0], "error", err)
+			continue
+		}
+		
 		select {
 		case sem <- struct{}{}:
 			w.Add(1)
-			go exportSoundbanks(&w, ctx, filepath.Join(data, splits[0]), dest)
+			go exportSoundbanks(&w, ctx, filepath.Join(data, splits[0]), a, dest)
 		default:
-			exportSoundbanks(nil, ctx, filepath.Join(data, splits[0]), dest)
+			exportSoundbanks(nil, ctx, filepath.Join(data, splits[0]), a, dest)
 		}
 	}
 	w.Wait()
 
+	if err := idx.Save(); err != nil {
+		slog.Warn("Failed to save ToC index", "error", err)
+	}
+
 	return nil
 }
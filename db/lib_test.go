@@ -224,6 +224,86 @@ func BenchmarkGather8(b *testing.B) {
 	}
 }
 
+func BenchmarkGatherMmap0(b *testing.B) {
+	useDiscard()
+	MaxBankParser = 0
+
+	p := "/mnt/D/Program Files/Steam/steamapps/common/Helldivers 2/data/e75f556a740e00c9"
+	m, err := wio.OpenMmapReader(p)
+	if err != nil {
+		slog.Error("Failed to map archive", "path", p)
+		panic(err)
+	}
+
+	defer m.Close()
+	a := parser.Archive{}
+	parser.ParseArchiveMmap(&a, m)
+	for range b.N {
+		b.ResetTimer()
+		parseBanksMmap(&a, make([]database.InsertSoundbankParams, len(a.SoundBnks)), m, "", p)
+	}
+}
+
+func BenchmarkGatherMmap4(b *testing.B) {
+	useDiscard()
+	MaxBankParser = 4
+
+	p := "/mnt/D/Program Files/Steam/steamapps/common/Helldivers 2/data/e75f556a740e00c9"
+	m, err := wio.OpenMmapReader(p)
+	if err != nil {
+		slog.Error("Failed to map archive", "path", p)
+		panic(err)
+	}
+
+	defer m.Close()
+	a := parser.Archive{}
+	parser.ParseArchiveMmap(&a, m)
+	for range b.N {
+		b.ResetTimer()
+		parseBanksMmap(&a, make([]database.InsertSoundbankParams, len(a.SoundBnks)), m, "", p)
+	}
+}
+
+func BenchmarkGatherMmap6(b *testing.B) {
+	useDiscard()
+	MaxBankParser = 6
+
+	p := "/mnt/D/Program Files/Steam/steamapps/common/Helldivers 2/data/e75f556a740e00c9"
+	m, err := wio.OpenMmapReader(p)
+	if err != nil {
+		slog.Error("Failed to map archive", "path", p)
+		panic(err)
+	}
+
+	defer m.Close()
+	a := parser.Archive{}
+	parser.ParseArchiveMmap(&a, m)
+	for range b.N {
+		b.ResetTimer()
+		parseBanksMmap(&a, make([]database.InsertSoundbankParams, len(a.SoundBnks)), m, "", p)
+	}
+}
+
+func BenchmarkGatherMmap8(b *testing.B) {
+	useDiscard()
+	MaxBankParser = 8
+
+	p := "/mnt/D/Program Files/Steam/steamapps/common/Helldivers 2/data/e75f556a740e00c9"
+	m, err := wio.OpenMmapReader(p)
+	if err != nil {
+		slog.Error("Failed to map archive", "path", p)
+		panic(err)
+	}
+
+	defer m.Close()
+	a := parser.Archive{}
+	parser.ParseArchiveMmap(&a, m)
+	for range b.N {
+		b.ResetTimer()
+		parseBanksMmap(&a, make([]database.InsertSoundbankParams, len(a.SoundBnks)), m, "", p)
+	}
+}
+
 func benchmarkGatherInPlace0(b *testing.B) {
 	useDiscard()
 	MaxBankParser = 0
@@ -348,9 +428,13 @@ func TestExportSoundbanks(t *testing.T) {
 	MaxArchiveReder = 0
 	MaxBankWriter = 0
 
+	a, err := parseToc(filepath.Join(data, "a66d7cf238070ca7"))
+	if err != nil {
+		t.Fatal(err)
+	}
 	exportSoundbanks(
 		nil, ctx,
-		filepath.Join(data, "a66d7cf238070ca7"), "output",
+		filepath.Join(data, "a66d7cf238070ca7"), a, "output",
 	)
 }
 
@@ -0,0 +1,207 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+
+	database "dekr0/hd2_audio_db/internal/complete"
+	"dekr0/hd2_audio_db/parser"
+)
+
+// Parts of an archive where a source can live. They match the suffix of the
+// file that holds the part.
+const (
+	PartMain   = "main"
+	PartStream = "stream"
+)
+
+// streamFileID returns the file ID of the WwiseStream asset that holds source
+// `sid` of a sound bank whose wwise dependency path is `rawPath`.
+func streamFileID(rawPath string, sid uint32) uint64 {
+	return parser.Murmur64A(
+		[]byte(path.Dir(rawPath) + "/" + strconv.FormatUint(uint64(sid), 10)),
+	)
+}
+
+// locationParams returns the locations of all sources referenced by a sound
+// bank. A source in DIDX lives in the main archive. A streamed source lives in
+// a WwiseStream asset, which can be in any archive, so it is returned with an
+// empty `Aid` and resolved later by resolveStreams. A source that is in DIDX
+// and streamed at the same time is prefetch + stream.
+func locationParams(
+	aid string,
+	h *parser.AssetHeader,
+	rawPath string,
+	hirc *parser.HIRC,
+	idx *parser.MediaIndex,
+) []database.InsertSourceLocationParams {
+	media := make(map[uint32]parser.Media)
+	if idx != nil {
+		for _, m := range idx.Media {
+			media[m.SourceID] = m
+		}
+	}
+
+	locationInsert := []database.InsertSourceLocationParams{}
+	for _, s := range hirc.Sound {
+		m, embedded := media[s.SourceID]
+		streamed := s.StreamType != 0
+		var prefetch int64 = 0
+		if embedded && streamed {
+			prefetch = 1
+		}
+		if embedded {
+			locationInsert = append(locationInsert, database.InsertSourceLocationParams{
+				Sid: int64(s.SourceID),
+				Aid: aid,
+				Fid: int64(h.FileID),
+				Tid: int64(h.TypeID),
+				Part: PartMain,
+				FileOffset: int64(h.DataOffset + 16 + uint64(idx.DataOffset) + uint64(m.Offset)),
+				Size: int64(m.Size),
+				Prefetch: prefetch,
+			})
+		}
+		if streamed {
+			if rawPath == "" {
+				slog.Warn(
+					"Cannot locate streamed source of sound bank without wwise dependency",
+					"aid", aid, "fid", h.FileID, "sid", s.SourceID,
+				)
+				continue
+			}
+			locationInsert = append(locationInsert, database.InsertSourceLocationParams{
+				Sid: int64(s.SourceID),
+				Aid: "",
+				Fid: int64(streamFileID(rawPath, s.SourceID)),
+				Tid: int64(parser.AssetTypeWwiseStream),
+				Part: PartStream,
+				Prefetch: prefetch,
+			})
+		}
+	}
+	return locationInsert
+}
+
+// resolveStreams fills in the archive, offset and size of streamed sources
+// using the stream part of WwiseStream assets in `assetInsert`. A stream asset
+// duplicated across archives yields one location per archive. Streamed
+// sources without any stream asset are reported and dropped.
+func resolveStreams(
+	locationInsert []database.InsertSourceLocationParams,
+	assetInsert []database.InsertAssetParams,
+) []database.InsertSourceLocationParams {
+	streams := make(map[int64][]*database.InsertAssetParams)
+	for i := range assetInsert {
+		a := &assetInsert[i]
+		if a.Tid == int64(parser.AssetTypeWwiseStream) {
+			streams[a.Fid] = append(streams[a.Fid], a)
+		}
+	}
+
+	resolved := make([]database.InsertSourceLocationParams, 0, len(locationInsert))
+	missing := 0
+	for _, l := range locationInsert {
+		if l.Aid != "" {
+			resolved = append(resolved, l)
+			continue
+		}
+		assets, in := streams[l.Fid]
+		if !in {
+			slog.Warn(
+				"Streamed source without WwiseStream asset",
+				"sid", l.Sid,
+				"fid", uint64(l.Fid),
+			)
+			missing += 1
+			continue
+		}
+		for _, a := range assets {
+			l.Aid = a.Aid
+			l.FileOffset = a.StreamFileOffset
+			l.Size = a.StreamSize
+			resolved = append(resolved, l)
+		}
+	}
+	if missing > 0 {
+		slog.Warn("Streamed sources without WwiseStream asset", "count", missing)
+	}
+	return resolved
+}
+
+// ExtractSource extracts the audio of source `sid` into `dest` as
+// `<sid>.<SourceFormat>`. A stream location is preferred over a location in the main
+// archive since the latter only holds the prefetched head of a prefetch +
+// stream source.
+func ExtractSource(ctx context.Context, data string, dest string, sid uint32) error {
+	stat, err := os.Lstat(dest)
+	if err != nil {
+		if os.IsNotExist(err) {
+			if err := os.Mkdir(dest, 0777); err != nil {
+				return err
+			}
+		}
+	} else if !stat.IsDir() {
+		return fmt.Errorf("%s is a file", dest)
+	}
+
+	l, err := sourceLocation(ctx, sid)
+	if err != nil {
+		return err
+	}
+	src := sourceFile(data, l.Aid, l.Part)
+	if SourceFormat == SourceFormatWem {
+		return extractRange(
+			src, l.FileOffset, l.Size, filepath.Join(dest, fmt.Sprintf("%d.wem", sid)),
+		)
+	}
+	b, err := readRange(src, l.FileOffset, l.Size)
+	if err != nil {
+		return err
+	}
+	return convertSource(
+		b, SourceFormat, filepath.Join(dest, fmt.Sprintf("%d.%s", sid, SourceFormat)),
+	)
+}
+
+// sourceLocation returns the preferred location of source `sid` (a stream 
+// location first) from `source_location` table.
+func sourceLocation(ctx context.Context, sid uint32) (*database.SourceLocation, error) {
+	c, err := conn()
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	locations, err := database.New(c).GetSourceLocation(ctx, int64(sid))
+	if err != nil {
+		return nil, err
+	}
+	if len(locations) == 0 {
+		return nil, fmt.Errorf("Source %d is not in the database", sid)
+	}
+	return &locations[0], c.Close()
+}
+
+// readSource reads the WEM of source `sid` from the archives in `data`.
+func readSource(ctx context.Context, data string, sid uint32) ([]byte, error) {
+	l, err := sourceLocation(ctx, sid)
+	if err != nil {
+		return nil, err
+	}
+	return readRange(sourceFile(data, l.Aid, l.Part), l.FileOffset, l.Size)
+}
+
+// sourceFile returns the path of the file that holds `part` of archive `aid`.
+func sourceFile(data string, aid string, part string) string {
+	p := filepath.Join(data, aid)
+	if part != PartMain {
+		p += "." + part
+	}
+	return p
+}
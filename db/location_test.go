@@ -0,0 +1,132 @@
+package db
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	database "dekr0/hd2_audio_db/internal/complete"
+	wio "dekr0/hd2_audio_db/io"
+	"dekr0/hd2_audio_db/parser"
+)
+
+func TestSourceLocation(t *testing.T) {
+	h := &parser.AssetHeader{
+		FileID: 1,
+		TypeID: uint64(parser.AssetTypeSoundBank),
+		DataOffset: 1000,
+	}
+	hirc := &parser.HIRC{Sound: []parser.Sound{
+		{SourceID: 10, StreamType: 0},
+		{SourceID: 11, StreamType: 1},
+		{SourceID: 12, StreamType: 2},
+		{SourceID: 13, StreamType: 2},
+	}}
+	idx := &parser.MediaIndex{
+		Media: []parser.Media{
+			{SourceID: 10, Offset: 0, Size: 64},
+			{SourceID: 11, Offset: 64, Size: 32},
+		},
+		DataOffset: 100,
+		DataSize: 96,
+	}
+	rawPath := "content/audio/bank"
+	locations := locationParams("a", h, rawPath, hirc, idx)
+	if len(locations) != 5 {
+		t.Fatalf("expect 5 locations, got %d", len(locations))
+	}
+
+	assets := []database.InsertAssetParams{
+		{Aid: "b", Fid: int64(streamFileID(rawPath, 11)), Tid: int64(parser.AssetTypeWwiseStream), StreamFileOffset: 16, StreamSize: 512},
+		{Aid: "c", Fid: int64(streamFileID(rawPath, 12)), Tid: int64(parser.AssetTypeWwiseStream), StreamFileOffset: 32, StreamSize: 256},
+	}
+	locations = resolveStreams(locations, assets)
+
+	expects := []database.InsertSourceLocationParams{
+		{Sid: 10, Aid: "a", Fid: 1, Tid: int64(h.TypeID), Part: PartMain, FileOffset: 1116, Size: 64},
+		{Sid: 11, Aid: "a", Fid: 1, Tid: int64(h.TypeID), Part: PartMain, FileOffset: 1180, Size: 32, Prefetch: 1},
+		{Sid: 11, Aid: "b", Fid: assets[0].Fid, Tid: assets[0].Tid, Part: PartStream, FileOffset: 16, Size: 512, Prefetch: 1},
+		{Sid: 12, Aid: "c", Fid: assets[1].Fid, Tid: assets[1].Tid, Part: PartStream, FileOffset: 32, Size: 256},
+	}
+	if len(locations) != len(expects) {
+		t.Fatalf("expect %d locations, got %v", len(expects), locations)
+	}
+	for i, e := range expects {
+		if locations[i] != e {
+			t.Fatalf("expect %v, got %v", e, locations[i])
+		}
+	}
+}
+
+// Expected file IDs are from a separate MurmurHash64A implementation that
+// reproduces the engine type IDs of TestMurmur64A. They are not taken from a
+// game install.
+func TestStreamFileIDPath(t *testing.T) {
+	tests := []struct {
+		rawPath string
+		sid     uint32
+		fid     uint64
+	}{
+		// "content/audio/weapons/103645740"
+		{"content/audio/weapons/autocannon", 103645740, 0x3C7048C87B936C02},
+		// "content/audio/7"
+		{"content/audio/bank", 7, 0x8A3A0D68AB1AEEC1},
+	}
+	for _, test := range tests {
+		if fid := streamFileID(test.rawPath, test.sid); fid != test.fid {
+			t.Fatalf("%s, %d: expect %#x, got %#x", test.rawPath, test.sid, test.fid, fid)
+		}
+	}
+}
+
+// TestStreamFileID checks the derivation of stream file IDs against a real
+// install. Every streamed source of the sound banks in one archive must
+// resolve to a WwiseStream asset in some archive, as resolveStreams allows.
+func TestStreamFileID(t *testing.T) {
+	data := "/mnt/d/Program Files/Steam/steamapps/common/Helldivers 2/data"
+	aid := "a66d7cf238070ca7"
+	if _, err := os.Stat(filepath.Join(data, aid)); err != nil {
+		t.Skip("No game install")
+	}
+
+	aids, err := listArchives(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	streams := make(map[int64]struct{})
+	for _, id := range aids {
+		m, err := wio.OpenMmapReader(filepath.Join(data, id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		a := parser.Archive{}
+		parser.ParseArchiveMmap(&a, m)
+		m.Close()
+		for _, h := range a.Headers {
+			if h.TypeID == uint64(parser.AssetTypeWwiseStream) {
+				streams[int64(h.FileID)] = struct{}{}
+			}
+		}
+	}
+
+	_, _, _, _, _, _, _, locationInsert := gatherMmap(context.Background(), filepath.Join(data, aid), aid)
+	streamed := 0
+	missing := []int64{}
+	for _, l := range locationInsert {
+		if l.Part != PartStream { continue }
+		streamed += 1
+		if _, in := streams[l.Fid]; !in {
+			missing = append(missing, l.Sid)
+		}
+	}
+	if streamed == 0 {
+		t.Fatalf("Archive %s has no streamed source", aid)
+	}
+	if len(missing) > 0 {
+		t.Fatalf(
+			"%d of %d streamed sources do not resolve: %v",
+			len(missing), streamed, missing,
+		)
+	}
+}
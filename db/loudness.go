@@ -0,0 +1,145 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	database "dekr0/hd2_audio_db/internal/complete"
+	"dekr0/hd2_audio_db/loudness"
+	"dekr0/hd2_audio_db/wem"
+)
+
+// Maximum true peak (dBTP) of a replacement after matching loudness before it
+// is reported as clipping.
+const maxTruePeak = -1.0
+
+func loudnessParams(
+	sid int64, w *wem.Wem, samples []int16,
+) database.InsertSourceLoudnessParams {
+	r := loudness.Measure(samples, int(w.Channels), int(w.SampleRate))
+	return database.InsertSourceLoudnessParams{
+		Sid: sid,
+		Integrated: r.Integrated,
+		TruePeak: r.TruePeak,
+		SamplePeak: r.SamplePeak,
+		Rms: r.RMS,
+		Duration: r.Duration,
+		Version: sourceAnalysisVersion,
+	}
+}
+
+type Loudness struct {
+	Integrated float64 `json:"integrated"`
+	TruePeak   float64 `json:"true_peak"`
+	SamplePeak float64 `json:"sample_peak"`
+	RMS        float64 `json:"rms"`
+	Duration   float64 `json:"duration"`
+}
+
+type LoudnessComparison struct {
+	Sid         uint32    `json:"sid"`
+	// Codec of the original. Only PCM and Wwise IMA ADPCM can be decoded. The
+	// original is left unmeasured and Gain is 0 otherwise.
+	Codec       wem.Codec `json:"codec"`
+	Decodable   bool      `json:"original_decodable"`
+	Original    Loudness  `json:"original"`
+	Replacement Loudness  `json:"replacement"`
+	// Gain in dB that brings the replacement to the integrated loudness of the
+	// original
+	Gain        float64   `json:"gain"`
+	// True peak of the replacement after applying Gain
+	TruePeak    float64   `json:"true_peak_after_gain"`
+	Clipping    bool      `json:"clipping"`
+	Note        string    `json:"note,omitempty"`
+}
+
+// measureWem decodes and measures WEM or WAV `b`.
+func measureWem(b []byte) (Loudness, error) {
+	w, err := wem.Parse(b)
+	if err != nil {
+		return Loudness{}, err
+	}
+	return measureParsed(w, b)
+}
+
+func measureParsed(w *wem.Wem, b []byte) (Loudness, error) {
+	samples, err := wem.Decode(w, b)
+	if err != nil {
+		return Loudness{}, err
+	}
+	r := loudness.Measure(samples, int(w.Channels), int(w.SampleRate))
+	return Loudness{r.Integrated, r.TruePeak, r.SamplePeak, r.RMS, r.Duration}, nil
+}
+
+func compareLoudness(original Loudness, replacement Loudness) LoudnessComparison {
+	c := LoudnessComparison{Decodable: true, Original: original, Replacement: replacement}
+	if original.Integrated > loudness.MinLevel && replacement.Integrated > loudness.MinLevel {
+		c.Gain = original.Integrated - replacement.Integrated
+	}
+	c.TruePeak = replacement.TruePeak + c.Gain
+	c.Clipping = c.TruePeak > maxTruePeak
+	return c
+}
+
+// undecodableLoudness reports that an original of `codec` cannot be decoded.
+// Only the replacement is measured, and clipping is checked without gain.
+func undecodableLoudness(codec wem.Codec, replacement Loudness) LoudnessComparison {
+	return LoudnessComparison{
+		Codec: codec,
+		Replacement: replacement,
+		TruePeak: replacement.TruePeak,
+		Clipping: replacement.TruePeak > maxTruePeak,
+		Note: fmt.Sprintf(
+			"Original not decodable: %s is not supported. Only PCM and " +
+			"Wwise IMA ADPCM originals can be compared.", codec,
+		),
+	}
+}
+
+// CompareLoudness measures source `sid` in the archives of `data` and the
+// replacement WAV (or PCM / Wwise IMA ADPCM WEM) at `replacement`, and writes
+// the comparison into `out` as JSON. Vorbis and Opus originals cannot be
+// decoded. The comparison then only has the replacement and says so in `note`.
+func CompareLoudness(
+	ctx context.Context, data string, sid uint32, replacement string, out io.Writer,
+) error {
+	b, err := readSource(ctx, data, sid)
+	if err != nil {
+		return err
+	}
+	o, err := wem.Parse(b)
+	if err != nil {
+		return fmt.Errorf("Source %d: %w", sid, err)
+	}
+	var original Loudness
+	if o.Codec.Decodable() {
+		original, err = measureParsed(o, b)
+		if err != nil {
+			return fmt.Errorf("Source %d: %w", sid, err)
+		}
+	}
+
+	b, err = os.ReadFile(replacement)
+	if err != nil {
+		return err
+	}
+	measured, err := measureWem(b)
+	if err != nil {
+		return fmt.Errorf("%s: %w", replacement, err)
+	}
+
+	var c LoudnessComparison
+	if o.Codec.Decodable() {
+		c = compareLoudness(original, measured)
+		c.Codec = o.Codec
+	} else {
+		c = undecodableLoudness(o.Codec, measured)
+	}
+	c.Sid = sid
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(&c)
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"dekr0/hd2_audio_db/wem"
+)
+
+// testPCMWav builds a mono 16 bits PCM WAV of `samples` at 24 kHz.
+func testPCMWav(samples []int16) []byte {
+	le := binary.LittleEndian
+	w := []byte("RIFF\x00\x00\x00\x00WAVEfmt ")
+	w = le.AppendUint32(w, 16)
+	w = le.AppendUint16(w, 1)
+	w = le.AppendUint16(w, 1)
+	w = le.AppendUint32(w, 24000)
+	w = le.AppendUint32(w, 48000)
+	w = le.AppendUint16(w, 2)
+	w = le.AppendUint16(w, 16)
+	w = append(w, "data"...)
+	w = le.AppendUint32(w, uint32(len(samples) * 2))
+	for _, s := range samples {
+		w = le.AppendUint16(w, uint16(s))
+	}
+	return w
+}
+
+func TestCompareLoudness(t *testing.T) {
+	tone := testTone(500, 1)
+	louder := make([]int16, len(tone))
+	for i := range tone {
+		louder[i] = tone[i] * 2
+	}
+	original, err := measureWem(testPCMWav(tone))
+	if err != nil {
+		t.Fatal(err)
+	}
+	replacement, err := measureWem(testPCMWav(louder))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if original.Duration != 1 {
+		t.Fatalf("expect 1 second, got %f", original.Duration)
+	}
+
+	c := compareLoudness(original, replacement)
+	if math.Abs(c.Gain + 20 * math.Log10(2)) > 0.05 {
+		t.Fatalf("expect -6.02 dB of gain, got %f", c.Gain)
+	}
+	if math.Abs(c.TruePeak - original.TruePeak) > 0.1 || c.Clipping {
+		t.Fatalf("expect true peak of the original after gain, got %v", c)
+	}
+
+	if _, err := measureWem([]byte("RIFF\x00\x00\x00\x00WAVX")); err == nil {
+		t.Fatal("expect error on invalid WAV")
+	}
+}
+
+func TestUndecodableLoudness(t *testing.T) {
+	if wem.CodecVorbis.Decodable() || wem.CodecOpus.Decodable() || !wem.CodecIMA.Decodable() {
+		t.Fatal("expect only PCM and Wwise IMA ADPCM to be decodable")
+	}
+	replacement, err := measureWem(testPCMWav(testTone(500, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := undecodableLoudness(wem.CodecVorbis, replacement)
+	if c.Decodable || c.Codec != wem.CodecVorbis || c.Gain != 0 || c.Note == "" {
+		t.Fatalf("expect original not decodable, got %v", c)
+	}
+	if c.TruePeak != replacement.TruePeak || c.Original != (Loudness{}) {
+		t.Fatalf("expect only the replacement to be measured, got %v", c)
+	}
+	if c := compareLoudness(replacement, replacement); !c.Decodable || c.Note != "" {
+		t.Fatalf("expect decodable original, got %v", c)
+	}
+}
@@ -0,0 +1,253 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	database "dekr0/hd2_audio_db/internal/complete"
+	"dekr0/hd2_audio_db/wem"
+)
+
+// Fields of a source compared between the original and its replacement
+const (
+	ReplaceCodec      = "codec"
+	ReplaceChannels   = "channels"
+	ReplaceSampleRate = "sample_rate"
+	ReplaceLoop       = "loop"
+	ReplaceLength     = "length"
+	ReplacePrefetch   = "prefetch"
+)
+
+// How much a replacement problem matters. An error is mishandled by the game.
+// A warning plays but likely not as intended.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Maximum difference in seconds of loop points and duration before it is
+// reported.
+const replaceTolerance = 0.01
+
+type ReplacementFormat struct {
+	Codec       wem.Codec `json:"codec"`
+	Channels    uint16    `json:"channels"`
+	ChannelMask uint32    `json:"channel_mask"`
+	SampleRate  uint32    `json:"sample_rate"`
+	SampleCount uint32    `json:"sample_count"`
+	Duration    float64   `json:"duration"`
+	HasLoop     bool      `json:"has_loop"`
+	LoopStart   uint32    `json:"loop_start"`
+	LoopEnd     uint32    `json:"loop_end"`
+	Size        int       `json:"size"`
+}
+
+type ReplacementProblem struct {
+	Field    string `json:"field"`
+	Severity string `json:"severity"`
+	Detail   string `json:"detail"`
+}
+
+type ReplacementReport struct {
+	Sid         uint32               `json:"sid"`
+	Original    ReplacementFormat    `json:"original"`
+	Replacement ReplacementFormat    `json:"replacement"`
+	Problems    []ReplacementProblem `json:"problems"`
+}
+
+// Valid reports whether the replacement has no error. Warnings are allowed.
+func (r *ReplacementReport) Valid() bool {
+	for _, p := range r.Problems {
+		if p.Severity == SeverityError {
+			return false
+		}
+	}
+	return true
+}
+
+func replacementFormat(w *wem.Wem, size int) ReplacementFormat {
+	return ReplacementFormat{
+		Codec: w.Codec,
+		Channels: w.Channels,
+		ChannelMask: w.ChannelMask,
+		SampleRate: w.SampleRate,
+		SampleCount: w.SampleCount,
+		Duration: w.Duration(),
+		HasLoop: w.HasLoop,
+		LoopStart: w.LoopStart,
+		LoopEnd: w.LoopEnd,
+		Size: size,
+	}
+}
+
+func seconds(samples uint32, rate uint32) float64 {
+	if rate == 0 {
+		return 0
+	}
+	return float64(samples) / float64(rate)
+}
+
+// validateReplacement compares replacement `r` (`b` as is) with original `o`.
+// `prefetch` is the size of the head of the original prefetched in the sound
+// bank (0 if the original is not prefetch + stream).
+func validateReplacement(
+	o *wem.Wem, r *wem.Wem, b []byte, prefetch int64,
+) []ReplacementProblem {
+	problems := []ReplacementProblem{}
+	add := func(field string, severity string, format string, a ...any) {
+		problems = append(problems, ReplacementProblem{
+			field, severity, fmt.Sprintf(format, a...),
+		})
+	}
+
+	if r.Codec == wem.CodecUnknown {
+		add(ReplaceCodec, SeverityError, "Unknown format tag 0x%04X", r.FormatTag)
+	} else if r.Codec != o.Codec {
+		add(ReplaceCodec, SeverityError, "Expect %s, got %s", o.Codec, r.Codec)
+	}
+	if r.Channels != o.Channels {
+		add(ReplaceChannels, SeverityError, "Expect %d channels, got %d", o.Channels, r.Channels)
+	} else if o.ChannelMask != 0 && r.ChannelMask != 0 && r.ChannelMask != o.ChannelMask {
+		add(
+			ReplaceChannels, SeverityWarning,
+			"Expect channel mask 0x%X, got 0x%X", o.ChannelMask, r.ChannelMask,
+		)
+	}
+	if r.SampleRate != o.SampleRate {
+		add(ReplaceSampleRate, SeverityError, "Expect %d Hz, got %d Hz", o.SampleRate, r.SampleRate)
+	}
+
+	if uint64(r.DataOffset) + uint64(r.DataSize) > uint64(len(b)) {
+		add(
+			ReplaceLength, SeverityError,
+			"Data chunk of %d bytes at %d exceeds file size %d",
+			r.DataSize, r.DataOffset, len(b),
+		)
+	}
+	if r.SampleCount == 0 {
+		add(ReplaceLength, SeverityError, "No sample")
+	} else if d := r.Duration() - o.Duration(); math.Abs(d) > replaceTolerance {
+		add(
+			ReplaceLength, SeverityWarning,
+			"Duration differs by %.3f seconds (%.3f, got %.3f)",
+			d, o.Duration(), r.Duration(),
+		)
+	}
+
+	if r.HasLoop {
+		if r.LoopStart > r.LoopEnd || r.LoopEnd > r.SampleCount {
+			add(
+				ReplaceLoop, SeverityError,
+				"Loop [%d, %d] is outside of %d samples",
+				r.LoopStart, r.LoopEnd, r.SampleCount,
+			)
+		}
+	}
+	switch {
+	case o.HasLoop && !r.HasLoop:
+		add(ReplaceLoop, SeverityWarning, "Original loops but replacement does not")
+	case !o.HasLoop && r.HasLoop:
+		add(ReplaceLoop, SeverityWarning, "Replacement loops but original does not")
+	case o.HasLoop && r.HasLoop:
+		start := seconds(r.LoopStart, r.SampleRate) - seconds(o.LoopStart, o.SampleRate)
+		end := seconds(r.LoopEnd, r.SampleRate) - seconds(o.LoopEnd, o.SampleRate)
+		if math.Abs(start) > replaceTolerance || math.Abs(end) > replaceTolerance {
+			add(
+				ReplaceLoop, SeverityWarning,
+				"Loop points differ by %.3f and %.3f seconds", start, end,
+			)
+		}
+	}
+
+	if prefetch > 0 {
+		if int64(len(b)) < prefetch {
+			add(
+				ReplacePrefetch, SeverityError,
+				"Size %d is smaller than the prefetched head of %d bytes in the sound bank",
+				len(b), prefetch,
+			)
+		} else {
+			add(
+				ReplacePrefetch, SeverityWarning,
+				"First %d bytes are prefetched in the sound bank, which must be replaced as well",
+				prefetch,
+			)
+		}
+	}
+	return problems
+}
+
+// ValidateReplacement compares the format of replacement WEM or WAV `b` with
+// source `sid` in the archives of `data`: codec, channels, sample rate, loop
+// points, length, and the prefetched head if the source is prefetch + stream.
+func ValidateReplacement(
+	ctx context.Context, data string, sid uint32, b []byte,
+) (*ReplacementReport, error) {
+	c, err := conn()
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	locations, err := database.New(c).GetSourceLocation(ctx, int64(sid))
+	if err != nil {
+		return nil, err
+	}
+	if len(locations) == 0 {
+		return nil, fmt.Errorf("Source %d is not in the database", sid)
+	}
+	c.Close()
+
+	// Stream location first
+	l := &locations[0]
+	var prefetch int64 = 0
+	for _, p := range locations {
+		if p.Prefetch == 1 && p.Part == PartMain {
+			prefetch = p.Size
+			break
+		}
+	}
+
+	ob, err := readRange(sourceFile(data, l.Aid, l.Part), l.FileOffset, l.Size)
+	if err != nil {
+		return nil, err
+	}
+	o, err := wem.Parse(ob)
+	if err != nil {
+		return nil, fmt.Errorf("Source %d: %w", sid, err)
+	}
+	r, err := wem.Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("Replacement: %w", err)
+	}
+
+	return &ReplacementReport{
+		Sid: sid,
+		Original: replacementFormat(o, len(ob)),
+		Replacement: replacementFormat(r, len(b)),
+		Problems: validateReplacement(o, r, b, prefetch),
+	}, nil
+}
+
+// WriteReplacementReport validates the replacement at `replacement` against
+// source `sid` (see ValidateReplacement) and writes the report into `out` as
+// JSON. Problems are not errors. The caller decides what to do with a report
+// that is not valid.
+func WriteReplacementReport(
+	ctx context.Context, data string, sid uint32, replacement string, out io.Writer,
+) (*ReplacementReport, error) {
+	b, err := os.ReadFile(replacement)
+	if err != nil {
+		return nil, err
+	}
+	report, err := ValidateReplacement(ctx, data, sid, b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", replacement, err)
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return report, enc.Encode(report)
+}
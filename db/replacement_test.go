@@ -0,0 +1,59 @@
+package db
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"dekr0/hd2_audio_db/wem"
+)
+
+func TestValidateReplacement(t *testing.T) {
+	tone := testTone(500, 1)
+	o, err := wem.Parse(testPCMWav(tone))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := testPCMWav(tone)
+	r, err := wem.Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if problems := validateReplacement(o, r, b, 0); len(problems) != 0 {
+		t.Fatalf("expect no problem, got %v", problems)
+	}
+
+	// 48 kHz at the same byte rate, i.e., half the duration
+	b = testPCMWav(tone)
+	binary.LittleEndian.PutUint32(b[24:], 48000)
+	r, err = wem.Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	problems := validateReplacement(o, r, b, 0)
+	fields := map[string]string{}
+	for _, p := range problems {
+		fields[p.Field] = p.Severity
+	}
+	if fields[ReplaceSampleRate] != SeverityError || fields[ReplaceLength] != SeverityWarning {
+		t.Fatalf("expect sample rate error and length warning, got %v", problems)
+	}
+	report := ReplacementReport{Problems: problems}
+	if report.Valid() {
+		t.Fatal("expect invalid replacement")
+	}
+
+	b = testPCMWav(tone[:100])
+	r, err = wem.Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	problems = validateReplacement(o, r, b, 1024)
+	fields = map[string]string{}
+	for _, p := range problems {
+		fields[p.Field] = p.Severity
+	}
+	if fields[ReplacePrefetch] != SeverityError {
+		t.Fatalf("expect prefetch error, got %v", problems)
+	}
+}
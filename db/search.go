@@ -0,0 +1,130 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+
+	database "dekr0/hd2_audio_db/internal/complete"
+)
+
+type SearchSoundbank struct {
+	Aid  string `json:"aid"`
+	Fid  int64  `json:"fid"`
+	Path string `json:"path"`
+}
+
+type SearchHierarchy struct {
+	Aid string `json:"aid"`
+	Fid int64  `json:"fid"`
+	Hid int64  `json:"hid"`
+}
+
+type SearchResult struct {
+	Tags       []string          `json:"tags"`
+	Categories []string          `json:"categories"`
+	Archives   []string          `json:"archives"`
+	Soundbanks []SearchSoundbank `json:"soundbanks"`
+	Hierarchy  []SearchHierarchy `json:"hierarchy"`
+	Sources    []int64           `json:"sources"`
+}
+
+type LabelList struct {
+	Tags       []string `json:"tags"`
+	Categories []string `json:"categories"`
+}
+
+// search returns the archives, sound banks, hierarchy objects and sources
+// that have every label in `tags` and `categories`, including labels inherited
+// from the entity that contains them (see `source_label` view).
+func search(
+	ctx context.Context, q *database.Queries, tags []string, categories []string,
+) (*SearchResult, error) {
+	r := SearchResult{
+		Tags: tags,
+		Categories: categories,
+		Soundbanks: []SearchSoundbank{},
+		Hierarchy: []SearchHierarchy{},
+	}
+	tagCount, categoryCount := int64(len(tags)), int64(len(categories))
+
+	var err error
+	r.Archives, err = q.SearchArchive(ctx, database.SearchArchiveParams{
+		Tags: tags, TagCount: tagCount, Categories: categories, CategoryCount: categoryCount,
+	})
+	if err != nil {
+		return nil, err
+	}
+	banks, err := q.SearchSoundbank(ctx, database.SearchSoundbankParams{
+		Tags: tags, TagCount: tagCount, Categories: categories, CategoryCount: categoryCount,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range banks {
+		r.Soundbanks = append(r.Soundbanks, SearchSoundbank{b.Aid, b.Fid, b.Path})
+	}
+	hierarchy, err := q.SearchHierarchy(ctx, database.SearchHierarchyParams{
+		Tags: tags, TagCount: tagCount, Categories: categories, CategoryCount: categoryCount,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range hierarchy {
+		r.Hierarchy = append(r.Hierarchy, SearchHierarchy{h.Aid, h.Fid, h.Hid})
+	}
+	r.Sources, err = q.SearchSource(ctx, database.SearchSourceParams{
+		Tags: tags, TagCount: tagCount, Categories: categories, CategoryCount: categoryCount,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if r.Archives == nil {
+		r.Archives = []string{}
+	}
+	if r.Sources == nil {
+		r.Sources = []int64{}
+	}
+	return &r, nil
+}
+
+// Search writes everything labeled with all of `tags` and `categories`
+// (LabelSeparator separated) into `out` as JSON. If both are empty, every
+// tag and category in the database is written instead.
+func Search(ctx context.Context, tags string, categories string, out io.Writer) error {
+	c, err := conn()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	q := database.New(c)
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+
+	t, cs := splitLabels(tags), splitLabels(categories)
+	if len(t) == 0 && len(cs) == 0 {
+		l := LabelList{Tags: []string{}, Categories: []string{}}
+		allTags, err := q.GetAllTag(ctx)
+		if err != nil {
+			return err
+		}
+		for _, t := range allTags {
+			l.Tags = append(l.Tags, t.Name)
+		}
+		allCategories, err := q.GetAllCategory(ctx)
+		if err != nil {
+			return err
+		}
+		for _, c := range allCategories {
+			l.Categories = append(l.Categories, c.Name)
+		}
+		return enc.Encode(&l)
+	}
+
+	r, err := search(ctx, q, t, cs)
+	if err != nil {
+		return err
+	}
+	return enc.Encode(r)
+}
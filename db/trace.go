@@ -0,0 +1,94 @@
+package db
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	wio "dekr0/hd2_audio_db/io"
+	"dekr0/hd2_audio_db/parser"
+)
+
+// TraceSoundbank parses sound bank `fid` of archive `aid` with tracing 
+// enabled and writes the annotated hex dump of every HIRC object followed by 
+// a report of objects with bytes not consumed into `w`. If parsing panics, 
+// the object being decoded is dumped first and the panic is returned as an 
+// error. The ToC is read from the archive directly so this works without a 
+// database, e.g., right after a game update breaks generation.
+func TraceSoundbank(data string, aid string, fid uint64, w io.Writer) (err error) {
+	p := filepath.Join(data, aid)
+	m, err := wio.OpenMmapReader(p)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	a := parser.Archive{}
+	parser.ParseArchiveMmap(&a, m)
+
+	var h *parser.AssetHeader = nil
+	for _, i := range a.SoundBnks {
+		if a.Headers[i].FileID == fid {
+			h = &a.Headers[i]
+			break
+		}
+	}
+	if h == nil {
+		return fmt.Errorf("Sound bank %d is not in archive %s", fid, aid)
+	}
+	if h.DataSize < 16 {
+		return fmt.Errorf("Sound bank %d of archive %s is too small", fid, aid)
+	}
+
+	bank, err := m.Slice(uint(h.DataOffset + 16), uint(h.DataSize - 16))
+	if err != nil {
+		return err
+	}
+	r := wio.NewInPlaceReader(bank, wio.ByteOrder)
+	t := wio.NewTrace()
+	r.SetTrace(t)
+
+	defer func() {
+		rec := recover()
+		if rec == nil {
+			return
+		}
+		err = fmt.Errorf("Failed to parse sound bank %d of archive %s: %v", fid, aid, rec)
+		if s, ok := t.Open(); ok {
+			fmt.Fprintf(w, "Failed at: %v\n", rec)
+			t.Dump(w, bank, s)
+			fmt.Fprintln(w)
+		}
+		t.Report(w)
+	}()
+
+	if parser.ParseBankInPlace(r, h.DataOffset + uint64(h.DataSize)) == nil {
+		return fmt.Errorf("Sound bank %d of archive %s has no HIRC", fid, aid)
+	}
+
+	for _, s := range t.Spans {
+		if err := t.Dump(w, bank, s); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintln(w)
+	return t.Report(w)
+}
+
+// TraceSoundbankToFile is TraceSoundbank that writes into file `p`, or stdout 
+// if `p` is empty.
+func TraceSoundbankToFile(data string, aid string, fid uint64, p string) error {
+	if p == "" {
+		return TraceSoundbank(data, aid, fid, os.Stdout)
+	}
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := TraceSoundbank(data, aid, fid, f); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	database "dekr0/hd2_audio_db/internal/complete"
+	"dekr0/hd2_audio_db/waveform"
+	"dekr0/hd2_audio_db/wem"
+)
+
+// Maximum number of buckets per channel of a waveform envelope.
+var WaveformBuckets = 1024
+
+func waveformParams(
+	sid int64, w *wem.Wem, samples []int16,
+) database.InsertSourceWaveformParams {
+	e := waveform.FromPCM(samples, int(w.Channels), WaveformBuckets)
+	return database.InsertSourceWaveformParams{
+		Sid: sid,
+		Channels: int64(e.Channels),
+		SampleRate: int64(w.SampleRate),
+		SamplesPerBucket: int64(e.SamplesPerBucket),
+		Peaks: e.Bytes(),
+		Version: sourceAnalysisVersion,
+	}
+}
+
+type Waveform struct {
+	Sid              int64    `json:"sid"`
+	Channels         int      `json:"channels"`
+	SampleRate       int64    `json:"sample_rate"`
+	SamplesPerBucket int      `json:"samples_per_bucket"`
+	// Per channel, per bucket
+	Min              [][]int8 `json:"min"`
+	Max              [][]int8 `json:"max"`
+}
+
+func waveformJSON(w *database.SourceWaveform) (*Waveform, error) {
+	e, err := waveform.Parse(w.Peaks, int(w.Channels), int(w.SamplesPerBucket))
+	if err != nil {
+		return nil, fmt.Errorf("Source %d: %w", w.Sid, err)
+	}
+	return &Waveform{
+		Sid: w.Sid,
+		Channels: e.Channels,
+		SampleRate: w.SampleRate,
+		SamplesPerBucket: e.SamplesPerBucket,
+		Min: e.Min,
+		Max: e.Max,
+	}, nil
+}
+
+// ExportWaveforms writes the waveform envelope of source `sid` (every source
+// in `source_waveform` table if `sid` is 0) into `dest` as `<sid>.json`.
+func ExportWaveforms(ctx context.Context, dest string, sid uint32) error {
+	if err := os.MkdirAll(dest, 0777); err != nil {
+		return err
+	}
+
+	c, err := conn()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	q := database.New(c)
+
+	var waveforms []database.SourceWaveform
+	if sid != 0 {
+		w, err := q.GetSourceWaveform(ctx, int64(sid))
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("Source %d has no waveform (not decodable?)", sid)
+		}
+		if err != nil {
+			return err
+		}
+		waveforms = append(waveforms, w)
+	} else {
+		waveforms, err = q.GetAllSourceWaveform(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	c.Close()
+
+	for i := range waveforms {
+		select {
+		case <- ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		w, err := waveformJSON(&waveforms[i])
+		if err != nil {
+			return err
+		}
+		b, err := json.Marshal(w)
+		if err != nil {
+			return err
+		}
+		p := filepath.Join(dest, strconv.FormatInt(w.Sid, 10) + ".json")
+		if err := os.WriteFile(p, b, 0666); err != nil {
+			return err
+		}
+	}
+	slog.Info("Exported waveforms", "dest", dest, "sources", len(waveforms))
+	return nil
+}
@@ -0,0 +1,287 @@
+package fingerprint
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/bits"
+	"slices"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// Kinds of fingerprint
+const (
+	// Computed from decoded samples. It survives re-encoding, gain changes
+	// and small offsets.
+	KindPCM     = "pcm"
+	// Computed from the raw `data` chunk when the codec cannot be decoded. It
+	// only survives byte identical chunks of audio (e.g., a re-import that
+	// only changes the header or padding).
+	KindPayload = "payload"
+)
+
+// Fingerprint is a sequence of 32 bits sub-fingerprints, one per frame of
+// audio for KindPCM or one per block of payload for KindPayload.
+type Fingerprint []uint32
+
+const numBands = 33
+
+// Frequency range of the bands in Hz.
+const (
+	minFreq = 300.0
+	maxFreq = 3000.0
+)
+
+// Differences of band energy relative to the energy of both frames that are
+// treated as 0.
+const noiseFloor = 1e-4
+
+// frameSize returns the smallest power of two that covers 1 / 8 seconds at
+// `rate` so that frames span the same duration at any sample rate.
+func frameSize(rate int) int {
+	n := 256
+	for n < rate / 8 {
+		n <<= 1
+	}
+	return n
+}
+
+// FromPCM computes the fingerprint of interleaved `samples`. The channels are
+// mixed down to mono. Every sub-fingerprint encodes whether the energy
+// difference between adjacent bands rises or falls compared with the
+// previous frame (Haitsma and Kalker, 2002).
+func FromPCM(samples []int16, channels int, rate int) Fingerprint {
+	if channels <= 0 || rate <= 0 {
+		return Fingerprint{}
+	}
+	n := frameSize(rate)
+	hop := n / 4
+
+	mono := make([]float64, max(len(samples) / channels, n + hop))
+	for i := range len(samples) / channels {
+		var sum float64 = 0
+		for c := range channels {
+			sum += float64(samples[i * channels + c])
+		}
+		mono[i] = sum / float64(channels) / 32768
+	}
+
+	window := make([]float64, n)
+	for i := range window {
+		window[i] = 0.5 - 0.5 * math.Cos(2 * math.Pi * float64(i) / float64(n))
+	}
+	hi := min(maxFreq, float64(rate) / 2)
+	lo := min(minFreq, hi / 4)
+	edges := make([]int, numBands + 1)
+	for m := range edges {
+		f := lo * math.Pow(hi / lo, float64(m) / numBands)
+		edges[m] = int(f * float64(n) / float64(rate))
+	}
+
+	frames := (len(mono) - n) / hop + 1
+	fp := make(Fingerprint, 0, frames - 1)
+	buf := make([]complex128, n)
+	prev := make([]float64, numBands)
+	curr := make([]float64, numBands)
+	for f := range frames {
+		frame := mono[f * hop:f * hop + n]
+		for i := range buf {
+			buf[i] = complex(frame[i] * window[i], 0)
+		}
+		fft(buf)
+		for m := range numBands {
+			from := edges[m]
+			to := max(edges[m + 1], from + 1)
+			var e float64 = 0
+			for _, c := range buf[from:to] {
+				e += real(c) * real(c) + imag(c) * imag(c)
+			}
+			curr[m] = e
+		}
+		if f > 0 {
+			var total float64 = 0
+			for m := range numBands {
+				total += curr[m] + prev[m]
+			}
+			// Differences below the floor are rounding noise of quiet bands.
+			// Clearing them keeps the bits stable across gain changes.
+			floor := total * noiseFloor
+			var sub uint32 = 0
+			for m := range numBands - 1 {
+				d := curr[m] - curr[m + 1] - (prev[m] - prev[m + 1])
+				if d > floor {
+					sub |= 1 << m
+				}
+			}
+			fp = append(fp, sub)
+		}
+		prev, curr = curr, prev
+	}
+	return fp
+}
+
+// fft is an in place radix-2 Cooley-Tukey FFT. len(x) must be a power of two.
+func fft(x []complex128) {
+	n := len(x)
+	shift := 64 - uint(bits.Len(uint(n)) - 1)
+	for i := range n {
+		j := int(bits.Reverse64(uint64(i)) >> shift)
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		sin, cos := math.Sincos(-2 * math.Pi / float64(size))
+		w := complex(cos, sin)
+		for start := 0; start < n; start += size {
+			var t complex128 = 1
+			for k := range size / 2 {
+				a := x[start + k]
+				b := x[start + k + size / 2] * t
+				x[start + k] = a + b
+				x[start + k + size / 2] = a - b
+				t *= w
+			}
+		}
+	}
+}
+
+// Size of a block of payload.
+const payloadBlock = 4096
+
+// FromPayload computes the fingerprint of raw bytes `b`. Every
+// sub-fingerprint is a hash of a block of `b`.
+func FromPayload(b []byte) Fingerprint {
+	fp := make(Fingerprint, 0, (len(b) + payloadBlock - 1) / payloadBlock)
+	for i := 0; i < len(b); i += payloadBlock {
+		fp = append(fp, uint32(xxhash.Sum64(b[i:min(i + payloadBlock, len(b))])))
+	}
+	return fp
+}
+
+// Maximum offset in sub-fingerprints tried when aligning two fingerprints.
+const maxShift = 16
+
+// Similarity returns a confidence in [0, 1] that `a` and `b` are the same
+// audio. It is the bit error rate of the best alignment relative to the error
+// rate expected by chance given the density of set bits (so unrelated audio
+// scores 0 even if most bits are clear), weighted by the overlap over the
+// longer fingerprint.
+func Similarity(a Fingerprint, b Fingerprint) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	longest := float64(max(len(a), len(b)))
+	best := 0.0
+	for shift := -maxShift; shift <= maxShift; shift++ {
+		overlap := 0
+		errs, onesA, onesB := 0, 0, 0
+		for i := max(0, -shift); i < len(a) && i + shift < len(b); i++ {
+			errs += bits.OnesCount32(a[i] ^ b[i + shift])
+			onesA += bits.OnesCount32(a[i])
+			onesB += bits.OnesCount32(b[i + shift])
+			overlap += 1
+		}
+		if overlap == 0 {
+			continue
+		}
+		n := float64(overlap * 32)
+		if errs == 0 {
+			best = max(best, float64(overlap) / longest)
+			continue
+		}
+		pa, pb := float64(onesA) / n, float64(onesB) / n
+		chance := pa * (1 - pb) + pb * (1 - pa)
+		if chance == 0 {
+			continue
+		}
+		s := max(1 - float64(errs) / n / chance, 0)
+		best = max(best, s * float64(overlap) / longest)
+	}
+	return best
+}
+
+// Bytes serializes `f` as little endian u32.
+func (f Fingerprint) Bytes() []byte {
+	b := make([]byte, 0, len(f) * 4)
+	for _, sub := range f {
+		b = binary.LittleEndian.AppendUint32(b, sub)
+	}
+	return b
+}
+
+// Parse is the inverse of Bytes.
+func Parse(b []byte) (Fingerprint, error) {
+	if len(b) % 4 != 0 {
+		return nil, fmt.Errorf("Fingerprint size %d is not a multiple of 4", len(b))
+	}
+	f := make(Fingerprint, len(b) / 4)
+	for i := range f {
+		f[i] = binary.LittleEndian.Uint32(b[i * 4:])
+	}
+	return f, nil
+}
+
+// Sub-fingerprints shared by more fingerprints than this are too common
+// (e.g., silence) to tell them apart and are ignored by Index.
+const maxBucket = 256
+
+// Index finds fingerprints that share sub-fingerprints with a query so that
+// Similarity only runs on a few candidates.
+type Index struct {
+	buckets map[uint32][]int
+}
+
+func NewIndex() *Index {
+	return &Index{buckets: make(map[uint32][]int)}
+}
+
+// Add adds fingerprint `f` under `id`.
+func (x *Index) Add(id int, f Fingerprint) {
+	seen := make(map[uint32]struct{}, len(f))
+	for _, sub := range f {
+		if sub == 0 {
+			continue
+		}
+		if _, in := seen[sub]; in {
+			continue
+		}
+		seen[sub] = struct{}{}
+		x.buckets[sub] = append(x.buckets[sub], id)
+	}
+}
+
+// Candidates returns at most `limit` IDs that share the most sub-fingerprints
+// with `f`, most shared first.
+func (x *Index) Candidates(f Fingerprint, limit int) []int {
+	hits := make(map[int]int)
+	seen := make(map[uint32]struct{}, len(f))
+	for _, sub := range f {
+		if _, in := seen[sub]; in {
+			continue
+		}
+		seen[sub] = struct{}{}
+		bucket := x.buckets[sub]
+		if len(bucket) > maxBucket {
+			continue
+		}
+		for _, id := range bucket {
+			hits[id] += 1
+		}
+	}
+	ids := make([]int, 0, len(hits))
+	for id := range hits {
+		ids = append(ids, id)
+	}
+	slices.SortFunc(ids, func(a int, b int) int {
+		if hits[a] != hits[b] {
+			return hits[b] - hits[a]
+		}
+		return a - b
+	})
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	return ids
+}
@@ -0,0 +1,127 @@
+package fingerprint
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// testSignal generates `seconds` of a tone whose pitch changes every 100 ms
+// according to `seed`, with noise of `noise` amplitude.
+func testSignal(seed int64, seconds float64, rate int, noise float64) []int16 {
+	r := rand.New(rand.NewSource(seed))
+	n := int(seconds * float64(rate))
+	samples := make([]int16, n)
+	freq := 0.0
+	phase := 0.0
+	for i := range samples {
+		if i % (rate / 10) == 0 {
+			freq = 300 + r.Float64() * 2500
+		}
+		phase += 2 * math.Pi * freq / float64(rate)
+		v := 0.5 * math.Sin(phase) + noise * (r.Float64() * 2 - 1)
+		samples[i] = int16(v * 32767)
+	}
+	return samples
+}
+
+func TestSimilarity(t *testing.T) {
+	a := FromPCM(testSignal(1, 2, 48000, 0), 1, 48000)
+	if len(a) == 0 {
+		t.Fatal("expect a non empty fingerprint")
+	}
+	if s := Similarity(a, a); s != 1 {
+		t.Fatalf("expect identical fingerprints to score 1, got %f", s)
+	}
+
+	noisy := FromPCM(testSignal(1, 2, 48000, 0.05), 1, 48000)
+	if s := Similarity(a, noisy); s < 0.6 {
+		t.Fatalf("expect noisy copy to score high, got %f", s)
+	}
+
+	// Quieter and shifted by one hop.
+	shifted := testSignal(1, 2, 48000, 0)
+	shifted = shifted[frameSize(48000) / 4:]
+	for i := range shifted {
+		shifted[i] /= 4
+	}
+	if s := Similarity(a, FromPCM(shifted, 1, 48000)); s < 0.6 {
+		t.Fatalf("expect shifted copy to score high, got %f", s)
+	}
+
+	other := FromPCM(testSignal(2, 2, 48000, 0), 1, 48000)
+	if s := Similarity(a, other); s > 0.3 {
+		t.Fatalf("expect unrelated audio to score low, got %f", s)
+	}
+}
+
+func TestFromPCMStereo(t *testing.T) {
+	mono := testSignal(3, 1, 24000, 0)
+	stereo := make([]int16, 0, len(mono) * 2)
+	for _, s := range mono {
+		stereo = append(stereo, s, s)
+	}
+	if s := Similarity(FromPCM(mono, 1, 24000), FromPCM(stereo, 2, 24000)); s != 1 {
+		t.Fatalf("expect downmix to match mono, got %f", s)
+	}
+	if len(FromPCM(mono[:10], 1, 24000)) != 1 {
+		t.Fatal("expect short audio to be padded to one sub-fingerprint")
+	}
+}
+
+func TestFromPayload(t *testing.T) {
+	b := make([]byte, payloadBlock * 3 + 10)
+	for i := range b {
+		b[i] = byte(i * 7)
+	}
+	a := FromPayload(b)
+	if len(a) != 4 {
+		t.Fatalf("expect 4 blocks, got %d", len(a))
+	}
+	b[payloadBlock * 3] ^= 0xFF
+	s := Similarity(a, FromPayload(b))
+	if s < 0.6 || s >= 1 {
+		t.Fatalf("expect one of four blocks to differ, got %f", s)
+	}
+
+	p, err := Parse(a.Bytes())
+	if err != nil || Similarity(a, p) != 1 || len(p) != len(a) {
+		t.Fatalf("expect round trip, got %v (%v)", p, err)
+	}
+	if _, err := Parse([]byte{1, 2, 3}); err == nil {
+		t.Fatal("expect error on partial sub-fingerprint")
+	}
+}
+
+func TestIndex(t *testing.T) {
+	fps := []Fingerprint{
+		FromPCM(testSignal(1, 1, 48000, 0), 1, 48000),
+		FromPCM(testSignal(2, 1, 48000, 0), 1, 48000),
+		FromPCM(testSignal(3, 1, 48000, 0), 1, 48000),
+	}
+	x := NewIndex()
+	for i, f := range fps {
+		x.Add(i, f)
+	}
+	ids := x.Candidates(FromPCM(testSignal(2, 1, 48000, 0.01), 1, 48000), 1)
+	if len(ids) != 1 || ids[0] != 1 {
+		t.Fatalf("expect candidate 1, got %v", ids)
+	}
+}
+
+func TestFFT(t *testing.T) {
+	x := make([]complex128, 16)
+	for i := range x {
+		x[i] = complex(math.Cos(2 * math.Pi * 3 * float64(i) / 16), 0)
+	}
+	fft(x)
+	for i, c := range x {
+		expect := 0.0
+		if i == 3 || i == 13 {
+			expect = 8
+		}
+		if math.Abs(real(c) - expect) > 1e-9 || math.Abs(imag(c)) > 1e-9 {
+			t.Fatalf("bin %d: expect %f, got %v", i, expect, c)
+		}
+	}
+}
@@ -2,6 +2,9 @@ module dekr0/hd2_audio_db
 
 go 1.23.3
 
-require github.com/mattn/go-sqlite3 v1.14.28
+require (
+	github.com/cespare/xxhash/v2 v2.3.0
+	github.com/mattn/go-sqlite3 v1.14.28
+)
 
 require github.com/google/uuid v1.6.0 // indirect
@@ -0,0 +1,44 @@
+package io
+
+// Decoder is the set of operations shared by Reader and InPlaceReader. Parsers 
+// that only need to decode fixed size fields and seek around should be written 
+// against Decoder so that the same code path serves both the streaming reader 
+// and the in place (mmap backed) reader.
+//
+// Unsafe variants panic on error. Parsers in this repository assume input is 
+// well formed and recover at the top level.
+type Decoder interface {
+	Tell() uint
+	AbsSeekUnsafe(uint)
+	AbsSeek(uint) error
+	RelSeekUnsafe(int)
+	RelSeek(int) error
+
+	FourCCUnsafe() []byte
+	FourCC() ([]byte, error)
+
+	U8Unsafe() uint8
+	U8() (uint8, error)
+	I8Unsafe() int8
+	I8() (int8, error)
+	U16Unsafe() uint16
+	U16() (uint16, error)
+	I16Unsafe() int16
+	I16() (int16, error)
+	U32Unsafe() uint32
+	U32() (uint32, error)
+	I32Unsafe() int32
+	I32() (int32, error)
+	F32Unsafe() float32
+	F32() (float32, error)
+	U64Unsafe() uint64
+	U64() (uint64, error)
+	I64Unsafe() int64
+	I64() (int64, error)
+
+	// Trace returns the attached trace or nil if tracing is disabled.
+	Trace() *Trace
+}
+
+var _ Decoder = (*Reader)(nil)
+var _ Decoder = (*InPlaceReader)(nil)
@@ -0,0 +1,43 @@
+package io
+
+import (
+	"errors"
+	"io"
+)
+
+var OutOfRange error = errors.New("Range is out of the mapped file")
+
+func (m *MmapReader) SliceUnsafe(off uint, n uint) []byte {
+	b, err := m.Slice(off, n)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// NewInPlaceReader creates an InPlaceReader over [off, off + n) of the file. 
+// On Linux, no copy is made. The reader must not be used after Close.
+func (m *MmapReader) NewInPlaceReader(off uint, n uint) (*InPlaceReader, error) {
+	b, err := m.Slice(off, n)
+	if err != nil {
+		return nil, err
+	}
+	return NewInPlaceReader(b, ByteOrder), nil
+}
+
+func (m *MmapReader) NewInPlaceReaderUnsafe(off uint, n uint) *InPlaceReader {
+	r, err := m.NewInPlaceReader(off, n)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func checkRange(size int64, off int64, n int64) error {
+	if off < 0 || n < 0 || off > size || n > size - off {
+		return OutOfRange
+	}
+	return nil
+}
+
+var _ io.ReaderAt = (*MmapReader)(nil)
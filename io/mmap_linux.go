@@ -0,0 +1,83 @@
+//go:build linux
+
+package io
+
+import (
+	"io"
+	"os"
+	"syscall"
+)
+
+// MmapReader maps a whole file as read only memory. Archive ToC and sound bank 
+// data can then be handed to InPlaceReader as slices without any copy or 
+// syscall per read. Every slice returned by Slice and every reader created by 
+// NewInPlaceReader is invalid after Close.
+type MmapReader struct {
+	data []byte
+}
+
+func OpenMmapReader(p string) (*MmapReader, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	// The mapping stays valid after the file is closed
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := stat.Size()
+	if size == 0 {
+		return &MmapReader{data: []byte{}}, nil
+	}
+	if int64(int(size)) != size {
+		return nil, syscall.EFBIG
+	}
+
+	data, err := syscall.Mmap(
+		int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &MmapReader{data: data}, nil
+}
+
+func (m *MmapReader) Size() int64 {
+	return int64(len(m.data))
+}
+
+func (m *MmapReader) ReadAt(b []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, OutOfRange
+	}
+	if off >= int64(len(m.data)) {
+		return 0, io.EOF
+	}
+	n := copy(b, m.data[off:])
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Slice returns [off, off + n) of the mapped file without copying. The 
+// capacity of the returned slice is capped to n so that append never writes 
+// into the mapping.
+func (m *MmapReader) Slice(off uint, n uint) ([]byte, error) {
+	if err := checkRange(int64(len(m.data)), int64(off), int64(n)); err != nil {
+		return nil, err
+	}
+	return m.data[off:off + n:off + n], nil
+}
+
+func (m *MmapReader) Close() error {
+	if len(m.data) == 0 {
+		return nil
+	}
+	data := m.data
+	m.data = nil
+	return syscall.Munmap(data)
+}
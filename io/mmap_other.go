@@ -0,0 +1,50 @@
+//go:build !linux
+
+package io
+
+import (
+	"os"
+)
+
+// MmapReader falls back to positional reads on platforms other than Linux. 
+// Slice allocates and copies, so the API is the same but it's not zero-copy.
+type MmapReader struct {
+	f    *os.File
+	size int64
+}
+
+func OpenMmapReader(p string) (*MmapReader, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &MmapReader{f: f, size: stat.Size()}, nil
+}
+
+func (m *MmapReader) Size() int64 {
+	return m.size
+}
+
+func (m *MmapReader) ReadAt(b []byte, off int64) (int, error) {
+	return m.f.ReadAt(b, off)
+}
+
+func (m *MmapReader) Slice(off uint, n uint) ([]byte, error) {
+	if err := checkRange(m.size, int64(off), int64(n)); err != nil {
+		return nil, err
+	}
+	b := make([]byte, n, n)
+	if _, err := m.f.ReadAt(b, int64(off)); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (m *MmapReader) Close() error {
+	return m.f.Close()
+}
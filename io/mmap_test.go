@@ -0,0 +1,50 @@
+package io
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMmapReader(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "archive")
+	data := []byte{0x11, 0x00, 0x00, 0xF0, 0x02, 0x00, 0x00, 0x00, 'B', 'K'}
+	if err := os.WriteFile(p, data, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := OpenMmapReader(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	if m.Size() != int64(len(data)) {
+		t.Fatalf("expect size %d, got %d", len(data), m.Size())
+	}
+
+	r, err := m.NewInPlaceReader(0, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := r.U32Unsafe(); v != 0xF0000011 {
+		t.Fatalf("expect 0xF0000011, got %x", v)
+	}
+	if v := r.U32Unsafe(); v != 2 {
+		t.Fatalf("expect 2, got %d", v)
+	}
+	if _, err := r.U8(); err == nil {
+		t.Fatal("expect short buffer at the end of the slice")
+	}
+
+	b := make([]byte, 4)
+	n, err := m.ReadAt(b, 8)
+	if n != 2 || err == nil || !bytes.Equal(b[:n], []byte("BK")) {
+		t.Fatalf("expect partial read with error, got %d %v %v", n, b[:n], err)
+	}
+
+	if _, err := m.Slice(8, 3); err != OutOfRange {
+		t.Fatalf("expect OutOfRange, got %v", err)
+	}
+}
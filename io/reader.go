@@ -21,10 +21,11 @@ type Reader struct {
 	r io.ReadSeeker
 	b bufio.Reader
 	o binary.ByteOrder
+	t *Trace
 }
 
 func NewReader(r io.ReadSeeker, o binary.ByteOrder) *Reader {
-	return &Reader{0, r, *bufio.NewReaderSize(r, 4096), o}
+	return &Reader{0, r, *bufio.NewReaderSize(r, 4096), o, nil}
 }
 
 func (r *Reader) ByteOrder() binary.ByteOrder {
@@ -123,6 +124,7 @@ func (r *Reader) U8Unsafe() uint8 {
 func (r *Reader) U8() (uint8, error) {
 	var v uint8
 	err := binary.Read(&r.b, r.o, &v)
+	if err == nil && r.t != nil { r.t.record(r.p, 1, v) }
 	r.p += 1
 	return v, err
 }
@@ -137,6 +139,7 @@ func (r *Reader) I8Unsafe() int8 {
 func (r *Reader) I8() (int8, error) {
 	var v int8
 	err := binary.Read(&r.b, r.o, &v)
+	if err == nil && r.t != nil { r.t.record(r.p, 1, v) }
 	r.p += 1
 	return v, err
 }
@@ -150,6 +153,7 @@ func (r *Reader) U16Unsafe() uint16 {
 func (r *Reader) U16() (uint16, error) {
 	var v uint16
 	err := binary.Read(&r.b, r.o, &v)
+	if err == nil && r.t != nil { r.t.record(r.p, 2, v) }
 	r.p += 2
 	return v, err
 }
@@ -163,6 +167,7 @@ func (r *Reader) I16Unsafe() int16 {
 func (r *Reader) I16() (int16, error) {
 	var v int16
 	err := binary.Read(&r.b, r.o, &v)
+	if err == nil && r.t != nil { r.t.record(r.p, 2, v) }
 	r.p += 2
 	return v, err
 }
@@ -176,6 +181,7 @@ func (r *Reader) U32Unsafe() uint32 {
 func (r *Reader) U32() (uint32, error) {
 	var v uint32
 	err := binary.Read(&r.b, r.o, &v)
+	if err == nil && r.t != nil { r.t.record(r.p, 4, v) }
 	r.p += 4
 	return v, err
 }
@@ -189,6 +195,7 @@ func (r *Reader) I32Unsafe() int32 {
 func (r *Reader) I32() (int32, error) {
 	var v int32
 	err := binary.Read(&r.b, r.o, &v)
+	if err == nil && r.t != nil { r.t.record(r.p, 4, v) }
 	r.p += 4
 	return v, err
 }
@@ -202,6 +209,7 @@ func (r *Reader) F32Unsafe() float32 {
 func (r *Reader) F32() (float32, error) {
 	var v float32
 	err := binary.Read(&r.b, r.o, &v)
+	if err == nil && r.t != nil { r.t.record(r.p, 4, v) }
 	r.p += 4
 	return v, err
 }
@@ -215,6 +223,7 @@ func (r *Reader) U64Unsafe() uint64 {
 func (r *Reader) U64() (uint64, error) {
 	var v uint64
 	err := binary.Read(&r.b, r.o, &v)
+	if err == nil && r.t != nil { r.t.record(r.p, 8, v) }
 	r.p += 8
 	return v, err
 }
@@ -228,20 +237,36 @@ func (r *Reader) I64Unsafe() int64 {
 func (r *Reader) I64() (int64, error) {
 	var v int64
 	err := binary.Read(&r.b, r.o, &v)
+	if err == nil && r.t != nil { r.t.record(r.p, 8, v) }
 	r.p += 8
 	return v, err
 }
 
+func (r *Reader) FourCCUnsafe() []byte {
+	b, err := r.FourCC()
+	if err != nil { panic(err) }
+	return b
+}
+
+func (r *Reader) FourCC() ([]byte, error) {
+	b := make([]byte, 4, 4)
+	at := r.p
+	err := r.ReadFull(b)
+	if err == nil && r.t != nil { r.t.record(at, 4, string(b)) }
+	return b, err
+}
+
 type InPlaceReader struct {
 	curr uint
 	Buff []byte // Escape hatch for accessing this
 	o binary.ByteOrder
+	t *Trace
 }
 
 // NOTES: Make sure pass in a slice of a memory region instead a copy of a 
-// memory region
+// memory region. The slice is never written, so it can be a slice of read only 
+// memory (e.g. MmapReader).
 func NewInPlaceReader(buff []byte, o binary.ByteOrder) *InPlaceReader {
-	buff = append(buff, 0) // pad one byte for EOF
 	return &InPlaceReader{curr: 0, Buff: buff, o: o}
 }
 
@@ -263,8 +288,10 @@ func (r *InPlaceReader) AbsSeekUnsafe(j uint) {
 	}
 }
 
+// Seeking to the end (j == Cap()) is allowed. Any read after that returns 
+// io.ErrShortBuffer.
 func (r *InPlaceReader) AbsSeek(j uint) error {
-	if j >= 0 && j < r.Cap() {
+	if j <= r.Cap() {
 		r.curr = j
 		return nil
 	}
@@ -286,7 +313,7 @@ func (r *InPlaceReader) RelSeek(j int) error {
 		r.curr -= flip 
 	} else {
 		j := uint(j)
-		if j + r.curr >= r.Cap() {
+		if j + r.curr > r.Cap() {
 			return InvalidSeek
 		}
 		r.curr += j
@@ -326,6 +353,7 @@ func (r *InPlaceReader) U8() (uint8, error) {
 	var v uint8
 	_, err := binary.Decode(r.Buff[r.curr:r.curr + 1], r.o, &v)
 	if err == nil {
+		if r.t != nil { r.t.record(r.curr, 1, v) }
 		r.curr += 1
 	} 
 	return v, err
@@ -346,6 +374,7 @@ func (r *InPlaceReader) I8() (int8, error) {
 	
 	_, err := binary.Decode(r.Buff[r.curr:r.curr + 1], r.o, &v)
 	if err == nil {
+		if r.t != nil { r.t.record(r.curr, 1, v) }
 		r.curr += 1
 	} 
 	return v, err
@@ -365,6 +394,7 @@ func (r *InPlaceReader) U16() (uint16, error) {
 	
 	_, err := binary.Decode(r.Buff[r.curr:r.curr + 2], r.o, &v)
 	if err == nil {
+		if r.t != nil { r.t.record(r.curr, 2, v) }
 		r.curr += 2
 	} 
 	return v, err
@@ -384,6 +414,7 @@ func (r *InPlaceReader) I16() (int16, error) {
 	
 	_, err := binary.Decode(r.Buff[r.curr:r.curr + 2], r.o, &v)
 	if err == nil {
+		if r.t != nil { r.t.record(r.curr, 2, v) }
 		r.curr += 2
 	} 
 	return v, err
@@ -403,6 +434,7 @@ func (r *InPlaceReader) U32() (uint32, error) {
 	
 	_, err := binary.Decode(r.Buff[r.curr:r.curr + 4], r.o, &v)
 	if err == nil {
+		if r.t != nil { r.t.record(r.curr, 4, v) }
 		r.curr += 4
 	} 
 	return v, err
@@ -422,6 +454,7 @@ func (r *InPlaceReader) I32() (int32, error) {
 	
 	_, err := binary.Decode(r.Buff[r.curr:r.curr + 4], r.o, &v)
 	if err == nil {
+		if r.t != nil { r.t.record(r.curr, 4, v) }
 		r.curr += 4
 	} 
 	return v, err
@@ -441,6 +474,7 @@ func (r *InPlaceReader) F32() (float32, error) {
 	
 	_, err := binary.Decode(r.Buff[r.curr:r.curr + 4], r.o, &v)
 	if err == nil {
+		if r.t != nil { r.t.record(r.curr, 4, v) }
 		r.curr += 4
 	} 
 	return v, err
@@ -457,6 +491,7 @@ func (r *InPlaceReader) FourCCNoCopy() ([]byte, error) {
 		return nil, io.ErrShortBuffer
 	}
 	b := r.Buff[r.curr:r.curr + 4]
+	if r.t != nil { r.t.record(r.curr, 4, string(b)) }
 	r.curr += 4
 	return b, nil
 }
@@ -475,6 +510,7 @@ func (r *InPlaceReader) FourCC() ([]byte, error) {
 	}
 	
 	b := bytes.Clone(r.Buff[r.curr:r.curr + 4])
+	if r.t != nil { r.t.record(r.curr, 4, string(b)) }
 	r.curr += 4
 	return b, nil
 }
@@ -493,6 +529,7 @@ func (r *InPlaceReader) U64() (uint64, error) {
 	
 	_, err := binary.Decode(r.Buff[r.curr:r.curr + 8], r.o, &v)
 	if err == nil {
+		if r.t != nil { r.t.record(r.curr, 8, v) }
 		r.curr += 8
 	} 
 	return v, err
@@ -512,6 +549,7 @@ func (r *InPlaceReader) I64() (int64, error) {
 	
 	_, err := binary.Decode(r.Buff[r.curr:r.curr + 8], r.o, &v)
 	if err == nil {
+		if r.t != nil { r.t.record(r.curr, 8, v) }
 		r.curr += 8
 	} 
 	return v, err
@@ -0,0 +1,253 @@
+package io
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// TraceField is a single field decoded by a reader while tracing is enabled. 
+// Offset is the position of the reader when the field is decoded.
+type TraceField struct {
+	Name   string
+	Offset uint
+	Width  uint
+	Value  any
+}
+
+// TraceSpan groups the fields decoded for one object, e.g., one HIRC object. 
+// [Begin, End) is the region the object claims to occupy. Fields of the span 
+// are Trace.Fields[First:Last]. Consumed is the position the parser reached 
+// before seeking to End (see Consume). It is 0 if the parser never reports it.
+type TraceSpan struct {
+	Name     string
+	Begin    uint
+	End      uint
+	Consumed uint
+	First    int
+	Last     int
+}
+
+// TraceGap is a region of a span that is not covered by any decoded field. 
+// A skipped gap lies before the consumed position, i.e., the parser seeks 
+// over it on purpose. Otherwise the parser never reaches it.
+type TraceGap struct {
+	Offset  uint
+	Size    uint
+	Skipped bool
+}
+
+// Trace records every field decoded by a Reader or InPlaceReader it is 
+// attached to (see SetTrace). Tracing is meant for investigating layout 
+// changes and is slow. Readers without a trace only pay a nil check.
+type Trace struct {
+	Fields []TraceField
+	Spans  []TraceSpan
+	name   string
+	open   int
+}
+
+func NewTrace() *Trace {
+	return &Trace{open: -1}
+}
+
+// Name names the next decoded field.
+func (t *Trace) Name(n string) {
+	t.name = n
+}
+
+// Begin opens a span for an object occupying [begin, end). Spans do not nest. 
+// Opening a span closes the previous one if it is still open.
+func (t *Trace) Begin(name string, begin uint, end uint) {
+	t.End()
+	t.Spans = append(t.Spans, TraceSpan{
+		Name: name, Begin: begin, End: end, First: len(t.Fields), Last: -1,
+	})
+	t.open = len(t.Spans) - 1
+}
+
+// Consume records position `p` as the position the parser reached in the open 
+// span. Parsers call this before seeking to the end of the object so that 
+// the bytes they never reach are told apart from the bytes they skip.
+func (t *Trace) Consume(p uint) {
+	if t.open < 0 {
+		return
+	}
+	t.Spans[t.open].Consumed = p
+}
+
+func (t *Trace) End() {
+	if t.open < 0 {
+		return
+	}
+	t.Spans[t.open].Last = len(t.Fields)
+	t.open = -1
+}
+
+// Open returns the span that is still open, e.g., the object being decoded 
+// when a parser panics.
+func (t *Trace) Open() (TraceSpan, bool) {
+	if t.open < 0 {
+		return TraceSpan{}, false
+	}
+	s := t.Spans[t.open]
+	s.Last = len(t.Fields)
+	return s, true
+}
+
+func (t *Trace) record(offset uint, width uint, v any) {
+	t.Fields = append(t.Fields, TraceField{t.name, offset, width, v})
+	t.name = ""
+}
+
+func (t *Trace) SpanFields(s TraceSpan) []TraceField {
+	last := s.Last
+	if last < 0 {
+		last = len(t.Fields)
+	}
+	return t.Fields[s.First:last]
+}
+
+// Gaps returns regions of the span that are not covered by any decoded field. 
+// A region across the consumed position is split in two.
+func (t *Trace) Gaps(s TraceSpan) []TraceGap {
+	gaps := []TraceGap{}
+	consumed := max(s.Begin, s.Consumed)
+	gap := func(from uint, to uint) {
+		if from < consumed {
+			gaps = append(gaps, TraceGap{from, min(to, consumed) - from, true})
+		}
+		if to > consumed {
+			from = max(from, consumed)
+			gaps = append(gaps, TraceGap{from, to - from, false})
+		}
+	}
+	curr := s.Begin
+	for _, f := range t.SpanFields(s) {
+		if f.Offset > curr {
+			gap(curr, f.Offset)
+		}
+		curr = max(curr, f.Offset + f.Width)
+	}
+	if curr < s.End {
+		gap(curr, s.End)
+	}
+	return gaps
+}
+
+// Overrun returns the number of bytes decoded or consumed past the end of the 
+// span.
+func (t *Trace) Overrun(s TraceSpan) uint {
+	var end uint = max(s.End, s.Consumed)
+	for _, f := range t.SpanFields(s) {
+		end = max(end, f.Offset + f.Width)
+	}
+	return end - s.End
+}
+
+// Report writes one line per span that has bytes not consumed or decoded past 
+// its end. Skipped bytes are counted but do not cause a line on their own.
+func (t *Trace) Report(w io.Writer) error {
+	for i, s := range t.Spans {
+		if s.Last < 0 && i == t.open {
+			s, _ = t.Open()
+		}
+		var skipped uint = 0
+		var unconsumed uint = 0
+		b := strings.Builder{}
+		for _, g := range t.Gaps(s) {
+			if g.Skipped {
+				skipped += g.Size
+				continue
+			}
+			unconsumed += g.Size
+			fmt.Fprintf(&b, " [%#x, +%d)", g.Offset, g.Size)
+		}
+		overrun := t.Overrun(s)
+		if unconsumed == 0 && overrun == 0 {
+			continue
+		}
+		if _, err := fmt.Fprintf(
+			w, "%-36s %#08x size %-6d skipped %-6d not consumed %-6d overrun %-4d%s\n",
+			s.Name, s.Begin, s.End - s.Begin, skipped, unconsumed, overrun, b.String(),
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Dump writes an annotated hex view of a span. `data` must be the same bytes 
+// the reader decodes from, i.e., data[0] is at reader position 0.
+func (t *Trace) Dump(w io.Writer, data []byte, s TraceSpan) error {
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "%s [%#x, %#x) size %d\n", s.Name, s.Begin, s.End, s.End - s.Begin)
+	hex := func(offset uint, size uint, note string) {
+		for size > 0 {
+			n := min(size, 16)
+			fmt.Fprintf(&b, "  %08x  %-48s  %s\n", offset, hexBytes(data, offset, n), note)
+			offset += n
+			size -= n
+		}
+	}
+	gaps := t.Gaps(s)
+	gap := func(to uint) {
+		for len(gaps) > 0 && gaps[0].Offset < to {
+			g := gaps[0]
+			gaps = gaps[1:]
+			if g.Skipped {
+				hex(g.Offset, g.Size, "<skipped>")
+			} else {
+				hex(g.Offset, g.Size, "<not consumed>")
+			}
+		}
+	}
+	for _, f := range t.SpanFields(s) {
+		gap(f.Offset)
+		name := f.Name
+		if name == "" {
+			name = "?"
+		}
+		fmt.Fprintf(
+			&b, "  %08x  %-48s  %s = %v\n",
+			f.Offset, hexBytes(data, f.Offset, f.Width), name, f.Value,
+		)
+	}
+	gap(s.End)
+	if overrun := t.Overrun(s); overrun > 0 {
+		fmt.Fprintf(&b, "  overrun by %d bytes\n", overrun)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func hexBytes(data []byte, offset uint, n uint) string {
+	b := strings.Builder{}
+	for i := range n {
+		if offset + i >= uint(len(data)) {
+			b.WriteString("-- ")
+			continue
+		}
+		fmt.Fprintf(&b, "%02x ", data[offset + i])
+	}
+	return b.String()
+}
+
+// SetTrace attaches a trace to the reader. Pass nil to disable tracing.
+func (r *Reader) SetTrace(t *Trace) {
+	r.t = t
+}
+
+func (r *Reader) Trace() *Trace {
+	return r.t
+}
+
+
+// SetTrace attaches a trace to the reader. Pass nil to disable tracing.
+func (r *InPlaceReader) SetTrace(t *Trace) {
+	r.t = t
+}
+
+func (r *InPlaceReader) Trace() *Trace {
+	return r.t
+}
@@ -0,0 +1,87 @@
+package io
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTrace(t *testing.T) {
+	data := []byte{
+		0x01, 0x00, 0x00, 0x00, // ID
+		0xAA, 0xBB, // skipped
+		0x02, // flag
+		0xCC, // never reached
+	}
+	tr := NewTrace()
+	r := NewInPlaceReader(data, ByteOrder)
+	r.SetTrace(tr)
+
+	tr.Begin("object", 0, uint(len(data)))
+	tr.Name("ulID")
+	r.U32Unsafe()
+	r.RelSeekUnsafe(2)
+	r.U8Unsafe()
+	tr.Consume(r.Tell())
+	r.AbsSeekUnsafe(uint(len(data)))
+	tr.End()
+
+	s := tr.Spans[0]
+	fields := tr.SpanFields(s)
+	if len(fields) != 2 || fields[0].Name != "ulID" || fields[0].Value != uint32(1) {
+		t.Fatalf("unexpected fields %v", fields)
+	}
+	if fields[1].Offset != 6 || fields[1].Width != 1 || fields[1].Name != "" {
+		t.Fatalf("unexpected field %v", fields[1])
+	}
+	gaps := tr.Gaps(s)
+	if len(gaps) != 2 || gaps[0] != (TraceGap{4, 2, true}) || gaps[1] != (TraceGap{7, 1, false}) {
+		t.Fatalf("unexpected gaps %v", gaps)
+	}
+	// Without the consumed position every gap counts as not consumed
+	s.Consumed = 0
+	if gaps := tr.Gaps(s); gaps[0].Skipped || gaps[1].Skipped {
+		t.Fatalf("unexpected gaps %v", gaps)
+	}
+	s = tr.Spans[0]
+	if tr.Overrun(s) != 0 {
+		t.Fatalf("unexpected overrun %d", tr.Overrun(s))
+	}
+
+	b := bytes.Buffer{}
+	if err := tr.Dump(&b, data, s); err != nil {
+		t.Fatal(err)
+	}
+	dump := b.String()
+	for _, want := range []string{"ulID = 1", "aa bb", "<skipped>", "cc", "<not consumed>"} {
+		if !strings.Contains(dump, want) {
+			t.Fatalf("expect %q in dump:\n%s", want, dump)
+		}
+	}
+
+	// An object that claims fewer bytes than decoded
+	tr.Begin("short", 0, 2)
+	r.AbsSeekUnsafe(0)
+	r.U32Unsafe()
+	if s, ok := tr.Open(); !ok || tr.Overrun(s) != 2 {
+		t.Fatal("expect open span overrun by 2 bytes")
+	}
+	b.Reset()
+	tr.Report(&b)
+	if strings.Count(b.String(), "\n") != 2 {
+		t.Fatalf("expect 2 lines in report:\n%s", b.String())
+	}
+}
+
+func TestTraceReader(t *testing.T) {
+	data := []byte{'H', 'I', 'R', 'C', 0x10, 0x00}
+	tr := NewTrace()
+	r := NewReader(bytes.NewReader(data), ByteOrder)
+	r.SetTrace(tr)
+	r.FourCCUnsafe()
+	r.U16Unsafe()
+	if len(tr.Fields) != 2 || tr.Fields[0].Value != "HIRC" || 
+	   tr.Fields[1].Offset != 4 || tr.Fields[1].Value != uint16(0x10) {
+		t.Fatalf("unexpected fields %v", tr.Fields)
+	}
+}
@@ -0,0 +1,556 @@
+package io
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+var InvalidFourCC error = errors.New("FourCC must be exactly 4 bytes")
+var InvalidPatch error = errors.New("Patch must be on bytes that are already written")
+
+// Encoder is the write counterpart of Decoder. It is implemented by Writer and 
+// BufferWriter.
+type Encoder interface {
+	Tell() uint
+	AbsSeek(uint) error
+	RelSeek(int) error
+	Write([]byte) (int, error)
+
+	FourCC([]byte) error
+	U8(uint8) error
+	I8(int8) error
+	U16(uint16) error
+	I16(int16) error
+	U32(uint32) error
+	I32(int32) error
+	F32(float32) error
+	U64(uint64) error
+	I64(int64) error
+
+	Pad(uint) error
+	Align(uint) error
+	ReserveU32() (uint, error)
+	PatchU32(uint, uint32) error
+	PatchSize(uint) error
+}
+
+var _ Encoder = (*Writer)(nil)
+var _ Encoder = (*BufferWriter)(nil)
+
+// Writer is the counter part of Reader. Writes are buffered by bufio.Writer. 
+// Seeking flushes the buffer first so seeking frequently is discouraged. 
+// Flush must be called once writing is done.
+type Writer struct {
+	p uint
+	n uint // high-water mark of written bytes
+	w io.WriteSeeker
+	b *bufio.Writer
+	o binary.ByteOrder
+	s [8]byte
+}
+
+func NewWriter(w io.WriteSeeker, o binary.ByteOrder) *Writer {
+	return &Writer{p: 0, n: 0, w: w, b: bufio.NewWriterSize(w, 4096), o: o}
+}
+
+func (w *Writer) ByteOrder() binary.ByteOrder {
+	return w.o
+}
+
+func (w *Writer) Tell() uint {
+	return w.p
+}
+
+// Len returns the number of bytes written so far regardless of the current 
+// position.
+func (w *Writer) Len() uint {
+	return w.n
+}
+
+func (w *Writer) Flush() error {
+	return w.b.Flush()
+}
+
+func (w *Writer) AbsSeekUnsafe(p uint) {
+	if err := w.AbsSeek(p); err != nil {
+		panic(err)
+	}
+}
+
+func (w *Writer) AbsSeek(p uint) error {
+	if err := w.b.Flush(); err != nil {
+		return err
+	}
+	n, err := w.w.Seek(int64(p), io.SeekStart)
+	if err != nil {
+		return err
+	}
+	w.p = uint(n)
+	return nil
+}
+
+func (w *Writer) RelSeekUnsafe(p int) {
+	if err := w.RelSeek(p); err != nil {
+		panic(err)
+	}
+}
+
+func (w *Writer) RelSeek(p int) error {
+	if p + int(w.p) < 0 {
+		return NegativeSeek
+	}
+	return w.AbsSeek(uint(p + int(w.p)))
+}
+
+func (w *Writer) WriteUnsafe(d []byte) {
+	if _, err := w.Write(d); err != nil {
+		panic(err)
+	}
+}
+
+func (w *Writer) Write(d []byte) (int, error) {
+	n, err := w.b.Write(d)
+	w.p += uint(n)
+	w.n = max(w.n, w.p)
+	return n, err
+}
+
+func (w *Writer) U8Unsafe(v uint8) {
+	if err := w.U8(v); err != nil { panic(err) }
+}
+
+func (w *Writer) U8(v uint8) error {
+	w.s[0] = v
+	_, err := w.Write(w.s[:1])
+	return err
+}
+
+func (w *Writer) I8Unsafe(v int8) {
+	if err := w.I8(v); err != nil { panic(err) }
+}
+
+func (w *Writer) I8(v int8) error {
+	w.s[0] = uint8(v)
+	_, err := w.Write(w.s[:1])
+	return err
+}
+
+func (w *Writer) U16Unsafe(v uint16) {
+	if err := w.U16(v); err != nil { panic(err) }
+}
+
+func (w *Writer) U16(v uint16) error {
+	w.o.PutUint16(w.s[:2], v)
+	_, err := w.Write(w.s[:2])
+	return err
+}
+
+func (w *Writer) I16Unsafe(v int16) {
+	if err := w.I16(v); err != nil { panic(err) }
+}
+
+func (w *Writer) I16(v int16) error {
+	w.o.PutUint16(w.s[:2], uint16(v))
+	_, err := w.Write(w.s[:2])
+	return err
+}
+
+func (w *Writer) U32Unsafe(v uint32) {
+	if err := w.U32(v); err != nil { panic(err) }
+}
+
+func (w *Writer) U32(v uint32) error {
+	w.o.PutUint32(w.s[:4], v)
+	_, err := w.Write(w.s[:4])
+	return err
+}
+
+func (w *Writer) I32Unsafe(v int32) {
+	if err := w.I32(v); err != nil { panic(err) }
+}
+
+func (w *Writer) I32(v int32) error {
+	w.o.PutUint32(w.s[:4], uint32(v))
+	_, err := w.Write(w.s[:4])
+	return err
+}
+
+func (w *Writer) F32Unsafe(v float32) {
+	if err := w.F32(v); err != nil { panic(err) }
+}
+
+func (w *Writer) F32(v float32) error {
+	w.o.PutUint32(w.s[:4], math.Float32bits(v))
+	_, err := w.Write(w.s[:4])
+	return err
+}
+
+func (w *Writer) U64Unsafe(v uint64) {
+	if err := w.U64(v); err != nil { panic(err) }
+}
+
+func (w *Writer) U64(v uint64) error {
+	w.o.PutUint64(w.s[:8], v)
+	_, err := w.Write(w.s[:8])
+	return err
+}
+
+func (w *Writer) I64Unsafe(v int64) {
+	if err := w.I64(v); err != nil { panic(err) }
+}
+
+func (w *Writer) I64(v int64) error {
+	w.o.PutUint64(w.s[:8], uint64(v))
+	_, err := w.Write(w.s[:8])
+	return err
+}
+
+func (w *Writer) FourCCUnsafe(v []byte) {
+	if err := w.FourCC(v); err != nil { panic(err) }
+}
+
+func (w *Writer) FourCC(v []byte) error {
+	if len(v) != 4 {
+		return InvalidFourCC
+	}
+	_, err := w.Write(v)
+	return err
+}
+
+func (w *Writer) PadUnsafe(n uint) {
+	if err := w.Pad(n); err != nil { panic(err) }
+}
+
+// Pad writes `n` zero bytes.
+func (w *Writer) Pad(n uint) error {
+	clear(w.s[:])
+	for n > 0 {
+		m := min(n, uint(len(w.s)))
+		if _, err := w.Write(w.s[:m]); err != nil {
+			return err
+		}
+		n -= m
+	}
+	return nil
+}
+
+func (w *Writer) AlignUnsafe(a uint) {
+	if err := w.Align(a); err != nil { panic(err) }
+}
+
+// Align pads zero bytes until the position is a multiple of `a`.
+func (w *Writer) Align(a uint) error {
+	if a == 0 {
+		return nil
+	}
+	if r := w.Tell() % a; r != 0 {
+		return w.Pad(a - r)
+	}
+	return nil
+}
+
+func (w *Writer) ReserveU32Unsafe() uint {
+	at, err := w.ReserveU32()
+	if err != nil { panic(err) }
+	return at
+}
+
+// ReserveU32 writes a zero u32 as a placeholder and returns its position so 
+// that it can be patched later by PatchU32 or PatchSize.
+func (w *Writer) ReserveU32() (uint, error) {
+	at := w.Tell()
+	return at, w.U32(0)
+}
+
+func (w *Writer) PatchU32Unsafe(at uint, v uint32) {
+	if err := w.PatchU32(at, v); err != nil { panic(err) }
+}
+
+// PatchU32 overwrites the u32 at position `at` without moving the current 
+// position. The u32 must be within the bytes written so far.
+func (w *Writer) PatchU32(at uint, v uint32) error {
+	curr := w.Tell()
+	if at + 4 > w.Len() {
+		return InvalidPatch
+	}
+	if err := w.AbsSeek(at); err != nil {
+		return err
+	}
+	if err := w.U32(v); err != nil {
+		return err
+	}
+	return w.AbsSeek(curr)
+}
+
+func (w *Writer) PatchSizeUnsafe(at uint) {
+	if err := w.PatchSize(at); err != nil { panic(err) }
+}
+
+// PatchSize patches the placeholder at `at` with the number of bytes written 
+// after it, i.e., the size of a chunk whose size field is at `at` and that 
+// ends at the last written byte.
+func (w *Writer) PatchSize(at uint) error {
+	end := w.Len()
+	if at + 4 > end {
+		return InvalidPatch
+	}
+	return w.PatchU32(at, uint32(end - at - 4))
+}
+
+// BufferWriter is the counter part of InPlaceReader. It writes into a growable 
+// in memory buffer. Writing in the middle of the buffer (after seeking back) 
+// overwrites existing bytes.
+type BufferWriter struct {
+	curr uint
+	Buff []byte // Escape hatch for accessing this
+	o binary.ByteOrder
+	s [8]byte
+}
+
+func NewBufferWriter(capacity uint, o binary.ByteOrder) *BufferWriter {
+	return &BufferWriter{curr: 0, Buff: make([]byte, 0, capacity), o: o}
+}
+
+func (w *BufferWriter) ByteOrder() binary.ByteOrder {
+	return w.o
+}
+
+// Bytes returns all bytes written so far. The slice is only valid until the 
+// next write.
+func (w *BufferWriter) Bytes() []byte {
+	return w.Buff
+}
+
+func (w *BufferWriter) Len() uint {
+	return uint(len(w.Buff))
+}
+
+func (w *BufferWriter) Tell() uint {
+	return w.curr
+}
+
+func (w *BufferWriter) AbsSeekUnsafe(j uint) {
+	if err := w.AbsSeek(j); err != nil {
+		panic(err)
+	}
+}
+
+// Seeking past the end of the written bytes is not allowed. Use Pad instead.
+func (w *BufferWriter) AbsSeek(j uint) error {
+	if j <= w.Len() {
+		w.curr = j
+		return nil
+	}
+	return InvalidSeek
+}
+
+func (w *BufferWriter) RelSeekUnsafe(j int) {
+	if err := w.RelSeek(j); err != nil {
+		panic(err)
+	}
+}
+
+func (w *BufferWriter) RelSeek(j int) error {
+	if j + int(w.curr) < 0 {
+		return InvalidSeek
+	}
+	return w.AbsSeek(uint(j + int(w.curr)))
+}
+
+func (w *BufferWriter) WriteUnsafe(d []byte) {
+	if _, err := w.Write(d); err != nil {
+		panic(err)
+	}
+}
+
+func (w *BufferWriter) Write(d []byte) (int, error) {
+	n := copy(w.Buff[w.curr:], d)
+	w.Buff = append(w.Buff, d[n:]...)
+	w.curr += uint(len(d))
+	return len(d), nil
+}
+
+func (w *BufferWriter) U8Unsafe(v uint8) {
+	if err := w.U8(v); err != nil { panic(err) }
+}
+
+func (w *BufferWriter) U8(v uint8) error {
+	w.s[0] = v
+	_, err := w.Write(w.s[:1])
+	return err
+}
+
+func (w *BufferWriter) I8Unsafe(v int8) {
+	if err := w.I8(v); err != nil { panic(err) }
+}
+
+func (w *BufferWriter) I8(v int8) error {
+	w.s[0] = uint8(v)
+	_, err := w.Write(w.s[:1])
+	return err
+}
+
+func (w *BufferWriter) U16Unsafe(v uint16) {
+	if err := w.U16(v); err != nil { panic(err) }
+}
+
+func (w *BufferWriter) U16(v uint16) error {
+	w.o.PutUint16(w.s[:2], v)
+	_, err := w.Write(w.s[:2])
+	return err
+}
+
+func (w *BufferWriter) I16Unsafe(v int16) {
+	if err := w.I16(v); err != nil { panic(err) }
+}
+
+func (w *BufferWriter) I16(v int16) error {
+	w.o.PutUint16(w.s[:2], uint16(v))
+	_, err := w.Write(w.s[:2])
+	return err
+}
+
+func (w *BufferWriter) U32Unsafe(v uint32) {
+	if err := w.U32(v); err != nil { panic(err) }
+}
+
+func (w *BufferWriter) U32(v uint32) error {
+	w.o.PutUint32(w.s[:4], v)
+	_, err := w.Write(w.s[:4])
+	return err
+}
+
+func (w *BufferWriter) I32Unsafe(v int32) {
+	if err := w.I32(v); err != nil { panic(err) }
+}
+
+func (w *BufferWriter) I32(v int32) error {
+	w.o.PutUint32(w.s[:4], uint32(v))
+	_, err := w.Write(w.s[:4])
+	return err
+}
+
+func (w *BufferWriter) F32Unsafe(v float32) {
+	if err := w.F32(v); err != nil { panic(err) }
+}
+
+func (w *BufferWriter) F32(v float32) error {
+	w.o.PutUint32(w.s[:4], math.Float32bits(v))
+	_, err := w.Write(w.s[:4])
+	return err
+}
+
+func (w *BufferWriter) U64Unsafe(v uint64) {
+	if err := w.U64(v); err != nil { panic(err) }
+}
+
+func (w *BufferWriter) U64(v uint64) error {
+	w.o.PutUint64(w.s[:8], v)
+	_, err := w.Write(w.s[:8])
+	return err
+}
+
+func (w *BufferWriter) I64Unsafe(v int64) {
+	if err := w.I64(v); err != nil { panic(err) }
+}
+
+func (w *BufferWriter) I64(v int64) error {
+	w.o.PutUint64(w.s[:8], uint64(v))
+	_, err := w.Write(w.s[:8])
+	return err
+}
+
+func (w *BufferWriter) FourCCUnsafe(v []byte) {
+	if err := w.FourCC(v); err != nil { panic(err) }
+}
+
+func (w *BufferWriter) FourCC(v []byte) error {
+	if len(v) != 4 {
+		return InvalidFourCC
+	}
+	_, err := w.Write(v)
+	return err
+}
+
+func (w *BufferWriter) PadUnsafe(n uint) {
+	if err := w.Pad(n); err != nil { panic(err) }
+}
+
+// Pad writes `n` zero bytes.
+func (w *BufferWriter) Pad(n uint) error {
+	clear(w.s[:])
+	for n > 0 {
+		m := min(n, uint(len(w.s)))
+		if _, err := w.Write(w.s[:m]); err != nil {
+			return err
+		}
+		n -= m
+	}
+	return nil
+}
+
+func (w *BufferWriter) AlignUnsafe(a uint) {
+	if err := w.Align(a); err != nil { panic(err) }
+}
+
+// Align pads zero bytes until the position is a multiple of `a`.
+func (w *BufferWriter) Align(a uint) error {
+	if a == 0 {
+		return nil
+	}
+	if r := w.Tell() % a; r != 0 {
+		return w.Pad(a - r)
+	}
+	return nil
+}
+
+func (w *BufferWriter) ReserveU32Unsafe() uint {
+	at, err := w.ReserveU32()
+	if err != nil { panic(err) }
+	return at
+}
+
+// ReserveU32 writes a zero u32 as a placeholder and returns its position so 
+// that it can be patched later by PatchU32 or PatchSize.
+func (w *BufferWriter) ReserveU32() (uint, error) {
+	at := w.Tell()
+	return at, w.U32(0)
+}
+
+func (w *BufferWriter) PatchU32Unsafe(at uint, v uint32) {
+	if err := w.PatchU32(at, v); err != nil { panic(err) }
+}
+
+// PatchU32 overwrites the u32 at position `at` without moving the current 
+// position. The u32 must be within the bytes written so far.
+func (w *BufferWriter) PatchU32(at uint, v uint32) error {
+	curr := w.Tell()
+	if at + 4 > w.Len() {
+		return InvalidPatch
+	}
+	if err := w.AbsSeek(at); err != nil {
+		return err
+	}
+	if err := w.U32(v); err != nil {
+		return err
+	}
+	return w.AbsSeek(curr)
+}
+
+func (w *BufferWriter) PatchSizeUnsafe(at uint) {
+	if err := w.PatchSize(at); err != nil { panic(err) }
+}
+
+// PatchSize patches the placeholder at `at` with the number of bytes written 
+// after it, i.e., the size of a chunk whose size field is at `at` and that 
+// ends at the last written byte.
+func (w *BufferWriter) PatchSize(at uint) error {
+	end := w.Len()
+	if at + 4 > end {
+		return InvalidPatch
+	}
+	return w.PatchU32(at, uint32(end - at - 4))
+}
@@ -0,0 +1,182 @@
+package io
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeChunk writes a chunk with a backpatched size and every typed field 
+// once. It is shared by both writers so the output can be compared.
+func writeChunk(t *testing.T, w Encoder) {
+	if err := w.FourCC([]byte{'B', 'K', 'H', 'D'}); err != nil {
+		t.Fatal(err)
+	}
+	at, err := w.ReserveU32()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.U8(0xAB)
+	w.I8(-2)
+	w.U16(0xBEEF)
+	w.I16(-300)
+	w.U32(0xDEADBEEF)
+	w.I32(-70000)
+	w.F32(1.5)
+	w.U64(0x0123456789ABCDEF)
+	w.I64(-1)
+	if err := w.Align(16); err != nil {
+		t.Fatal(err)
+	}
+	if w.Tell() % 16 != 0 {
+		t.Fatalf("expect position aligned to 16, got %d", w.Tell())
+	}
+	if err := w.PatchSize(at); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.FourCC([]byte{'H', 'I'}); err != InvalidFourCC {
+		t.Fatalf("expect InvalidFourCC, got %v", err)
+	}
+	if err := w.PatchU32(w.Tell(), 0); err != InvalidPatch {
+		t.Fatalf("expect InvalidPatch, got %v", err)
+	}
+}
+
+func readChunk(t *testing.T, r Decoder) {
+	if tag := r.FourCCUnsafe(); !bytes.Equal(tag, []byte{'B', 'K', 'H', 'D'}) {
+		t.Fatalf("unexpected tag %s", tag)
+	}
+	if size := r.U32Unsafe(); size != 40 {
+		t.Fatalf("expect chunk size 40, got %d", size)
+	}
+	if v := r.U8Unsafe(); v != 0xAB {
+		t.Fatalf("U8: %x", v)
+	}
+	if v := r.I8Unsafe(); v != -2 {
+		t.Fatalf("I8: %d", v)
+	}
+	if v := r.U16Unsafe(); v != 0xBEEF {
+		t.Fatalf("U16: %x", v)
+	}
+	if v := r.I16Unsafe(); v != -300 {
+		t.Fatalf("I16: %d", v)
+	}
+	if v := r.U32Unsafe(); v != 0xDEADBEEF {
+		t.Fatalf("U32: %x", v)
+	}
+	if v := r.I32Unsafe(); v != -70000 {
+		t.Fatalf("I32: %d", v)
+	}
+	if v := r.F32Unsafe(); v != 1.5 {
+		t.Fatalf("F32: %f", v)
+	}
+	if v := r.U64Unsafe(); v != 0x0123456789ABCDEF {
+		t.Fatalf("U64: %x", v)
+	}
+	if v := r.I64Unsafe(); v != -1 {
+		t.Fatalf("I64: %d", v)
+	}
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	bw := NewBufferWriter(0, ByteOrder)
+	writeChunk(t, bw)
+	if bw.Len() != 48 || bw.Tell() != 48 {
+		t.Fatalf("expect 48 bytes, got %d (at %d)", bw.Len(), bw.Tell())
+	}
+	readChunk(t, NewInPlaceReader(bw.Bytes(), ByteOrder))
+
+	p := filepath.Join(t.TempDir(), "bank")
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := NewWriter(f, ByteOrder)
+	writeChunk(t, w)
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, bw.Bytes()) {
+		t.Fatalf("Writer and BufferWriter disagree:\n%x\n%x", b, bw.Bytes())
+	}
+	readChunk(t, NewReader(bytes.NewReader(b), ByteOrder))
+}
+
+func TestBufferWriterOverwrite(t *testing.T) {
+	w := NewBufferWriter(4, ByteOrder)
+	w.U32Unsafe(0x11111111)
+	w.U32Unsafe(0x22222222)
+	w.AbsSeekUnsafe(2)
+	w.U32Unsafe(0x33333333)
+	want := []byte{0x11, 0x11, 0x33, 0x33, 0x33, 0x33, 0x22, 0x22}
+	if !bytes.Equal(w.Bytes(), want) {
+		t.Fatalf("expect %x, got %x", want, w.Bytes())
+	}
+	if err := w.AbsSeek(9); err != InvalidSeek {
+		t.Fatalf("expect InvalidSeek, got %v", err)
+	}
+}
+
+// patchAfterSeek patches a size field after seeking back into the chunk. The 
+// patch is checked against the bytes written so far, not the position.
+func patchAfterSeek(t *testing.T, w Encoder) {
+	at, err := w.ReserveU32()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.U32(0x11111111)
+	w.U32(0x22222222)
+	if err := w.AbsSeek(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.PatchU32(8, 0x33333333); err != nil {
+		t.Fatalf("expect patch within written bytes, got %v", err)
+	}
+	if err := w.PatchSize(at); err != nil {
+		t.Fatal(err)
+	}
+	if w.Tell() != 0 {
+		t.Fatalf("expect position 0 after patches, got %d", w.Tell())
+	}
+	if err := w.PatchU32(10, 0); err != InvalidPatch {
+		t.Fatalf("expect InvalidPatch, got %v", err)
+	}
+}
+
+func TestPatchAfterSeek(t *testing.T) {
+	want := []byte{8, 0, 0, 0, 0x11, 0x11, 0x11, 0x11, 0x33, 0x33, 0x33, 0x33}
+
+	bw := NewBufferWriter(0, ByteOrder)
+	patchAfterSeek(t, bw)
+	if !bytes.Equal(bw.Bytes(), want) {
+		t.Fatalf("expect %x, got %x", want, bw.Bytes())
+	}
+
+	p := filepath.Join(t.TempDir(), "chunk")
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := NewWriter(f, ByteOrder)
+	patchAfterSeek(t, w)
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if w.Len() != uint(len(want)) {
+		t.Fatalf("expect %d bytes written, got %d", len(want), w.Len())
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, want) {
+		t.Fatalf("expect %x, got %x", want, b)
+	}
+}
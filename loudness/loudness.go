@@ -0,0 +1,280 @@
+package loudness
+
+import (
+	"math"
+	"slices"
+)
+
+// Floor of every level in dB. Silence and audio too short to measure are
+// reported at this level instead of -Inf.
+const MinLevel = -120.0
+
+// Result is the loudness of a piece of audio. Levels are in dB relative to
+// full scale.
+type Result struct {
+	// Integrated loudness (ITU-R BS.1770-4) in LUFS
+	Integrated float64
+	// Maximum of the signal oversampled 4 times in dBTP
+	TruePeak   float64
+	// Maximum of the samples in dBFS
+	SamplePeak float64
+	// RMS of all channels in dBFS
+	RMS        float64
+	// Seconds
+	Duration   float64
+}
+
+// Measure measures interleaved `samples` with `channels` at `rate`.
+func Measure(samples []int16, channels int, rate int) Result {
+	if channels <= 0 || rate <= 0 {
+		return Result{MinLevel, MinLevel, MinLevel, MinLevel, 0}
+	}
+	frames := len(samples) / channels
+	x := make([][]float64, channels)
+	for c := range x {
+		x[c] = make([]float64, frames)
+		for i := range frames {
+			x[c][i] = float64(samples[i * channels + c]) / 32768
+		}
+	}
+
+	r := Result{Duration: float64(frames) / float64(rate)}
+	var sum float64 = 0
+	var peak float64 = 0
+	for _, ch := range x {
+		for _, v := range ch {
+			sum += v * v
+			peak = max(peak, math.Abs(v))
+		}
+	}
+	if frames > 0 {
+		r.RMS = decibel(math.Sqrt(sum / float64(frames * channels)))
+	} else {
+		r.RMS = MinLevel
+	}
+	r.SamplePeak = decibel(peak)
+
+	truePeak := peak
+	for _, ch := range x {
+		truePeak = max(truePeak, oversampledPeak(ch))
+	}
+	r.TruePeak = decibel(truePeak)
+	r.Integrated = integrated(x, rate)
+	return r
+}
+
+func decibel(amplitude float64) float64 {
+	if amplitude <= 0 {
+		return MinLevel
+	}
+	return max(20 * math.Log10(amplitude), MinLevel)
+}
+
+// channelWeights returns the weight of every channel in WAV order. LFE is
+// excluded and surround channels are weighted by +1.5 dB.
+func channelWeights(channels int) []float64 {
+	w := make([]float64, channels)
+	for i := range w {
+		w[i] = 1
+	}
+	switch {
+	case channels == 4: // FL FR BL BR
+		w[2], w[3] = 1.41, 1.41
+	case channels == 5: // FL FR FC BL BR
+		w[3], w[4] = 1.41, 1.41
+	case channels >= 6: // FL FR FC LFE BL BR ...
+		w[3] = 0
+		for i := 4; i < channels; i++ {
+			w[i] = 1.41
+		}
+	}
+	return w
+}
+
+// biquad is a second order IIR filter in direct form I.
+type biquad struct {
+	b0, b1, b2 float64
+	a1, a2     float64
+}
+
+func (f *biquad) apply(x []float64) []float64 {
+	y := make([]float64, len(x))
+	var x1, x2, y1, y2 float64
+	for i, v := range x {
+		out := f.b0 * v + f.b1 * x1 + f.b2 * x2 - f.a1 * y1 - f.a2 * y2
+		x2, x1 = x1, v
+		y2, y1 = y1, out
+		y[i] = out
+	}
+	return y
+}
+
+// kWeighting returns the two stages of the K-weighting filter at `rate`. The
+// coefficients are derived by bilinear transform so that any sample rate
+// works, not only 48 kHz for which BS.1770 lists them.
+func kWeighting(rate int) (biquad, biquad) {
+	fs := float64(rate)
+
+	// High shelf modeling the acoustic effect of the head
+	f0 := 1681.974450955533
+	g := 3.999843853973347
+	q := 0.7071752369554196
+	k := math.Tan(math.Pi * f0 / fs)
+	vh := math.Pow(10, g / 20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k / q + k * k
+	shelf := biquad{
+		b0: (vh + vb * k / q + k * k) / a0,
+		b1: 2 * (k * k - vh) / a0,
+		b2: (vh - vb * k / q + k * k) / a0,
+		a1: 2 * (k * k - 1) / a0,
+		a2: (1 - k / q + k * k) / a0,
+	}
+
+	// High pass (RLB weighting)
+	f0 = 38.13547087602444
+	q = 0.5003270373238773
+	k = math.Tan(math.Pi * f0 / fs)
+	a0 = 1 + k / q + k * k
+	highPass := biquad{
+		b0: 1,
+		b1: -2,
+		b2: 1,
+		a1: 2 * (k * k - 1) / a0,
+		a2: (1 - k / q + k * k) / a0,
+	}
+	return shelf, highPass
+}
+
+const (
+	blockSeconds   = 0.4
+	hopSeconds     = 0.1
+	absoluteGate   = -70.0
+	relativeGate   = -10.0
+)
+
+func blockLoudness(power float64) float64 {
+	if power <= 0 {
+		return math.Inf(-1)
+	}
+	return -0.691 + 10 * math.Log10(power)
+}
+
+// integrated computes the gated integrated loudness of channels `x`. Audio
+// shorter than a gating block (400 ms) is measured as a single block so that
+// short sounds still have a loudness.
+func integrated(x [][]float64, rate int) float64 {
+	weights := channelWeights(len(x))
+	z := make([][]float64, len(x))
+	for c, ch := range x {
+		if weights[c] == 0 {
+			continue
+		}
+		shelf, highPass := kWeighting(rate)
+		y := highPass.apply(shelf.apply(ch))
+		// Prefix sum of squares for the mean square of every block
+		z[c] = make([]float64, len(y) + 1)
+		for i, v := range y {
+			z[c][i + 1] = z[c][i] + v * v
+		}
+	}
+	frames := 0
+	if len(x) > 0 {
+		frames = len(x[0])
+	}
+	if frames == 0 {
+		return MinLevel
+	}
+
+	block := int(blockSeconds * float64(rate))
+	hop := int(hopSeconds * float64(rate))
+	if frames < block {
+		block = frames
+	}
+	powers := []float64{}
+	for start := 0; start + block <= frames; start += hop {
+		var p float64 = 0
+		for c := range z {
+			if z[c] == nil {
+				continue
+			}
+			p += weights[c] * (z[c][start + block] - z[c][start]) / float64(block)
+		}
+		powers = append(powers, p)
+	}
+
+	gated := func(threshold float64) []float64 {
+		return slices.DeleteFunc(slices.Clone(powers), func(p float64) bool {
+			return blockLoudness(p) <= threshold
+		})
+	}
+	mean := func(ps []float64) float64 {
+		var sum float64 = 0
+		for _, p := range ps {
+			sum += p
+		}
+		return sum / float64(len(ps))
+	}
+	abs := gated(absoluteGate)
+	if len(abs) == 0 {
+		return MinLevel
+	}
+	rel := gated(blockLoudness(mean(abs)) + relativeGate)
+	if len(rel) == 0 {
+		return MinLevel
+	}
+	return max(blockLoudness(mean(rel)), MinLevel)
+}
+
+// Number of phases and taps per phase of the interpolation filter used for
+// true peak.
+const (
+	oversample = 4
+	phaseTaps  = 12
+)
+
+var interpolation = func() [oversample][phaseTaps]float64 {
+	var h [oversample][phaseTaps]float64
+	n := oversample * phaseTaps
+	center := float64(n - 1) / 2
+	for i := range n {
+		t := (float64(i) - center) / oversample
+		sinc := 1.0
+		if t != 0 {
+			sinc = math.Sin(math.Pi * t) / (math.Pi * t)
+		}
+		// Blackman window
+		w := 0.42 - 0.5 * math.Cos(2 * math.Pi * float64(i) / float64(n - 1)) +
+			0.08 * math.Cos(4 * math.Pi * float64(i) / float64(n - 1))
+		h[i % oversample][i / oversample] = sinc * w
+	}
+	for p := range h {
+		var sum float64 = 0
+		for _, v := range h[p] {
+			sum += v
+		}
+		for i := range h[p] {
+			h[p][i] /= sum
+		}
+	}
+	return h
+}()
+
+// oversampledPeak returns the maximum absolute value of `x` interpolated
+// `oversample` times.
+func oversampledPeak(x []float64) float64 {
+	var peak float64 = 0
+	for i := range len(x) + phaseTaps {
+		for p := range oversample {
+			var v float64 = 0
+			for k := range phaseTaps {
+				j := i - k
+				if j >= 0 && j < len(x) {
+					v += interpolation[p][k] * x[j]
+				}
+			}
+			peak = max(peak, math.Abs(v))
+		}
+	}
+	return peak
+}
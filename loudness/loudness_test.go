@@ -0,0 +1,81 @@
+package loudness
+
+import (
+	"math"
+	"testing"
+)
+
+func testSine(freq float64, amplitude float64, phase float64, seconds float64, rate int, channels int) []int16 {
+	frames := int(seconds * float64(rate))
+	samples := make([]int16, 0, frames * channels)
+	for i := range frames {
+		v := amplitude * math.Sin(2 * math.Pi * freq * float64(i) / float64(rate) + phase)
+		for range channels {
+			samples = append(samples, int16(math.Round(v * 32767)))
+		}
+	}
+	return samples
+}
+
+func near(a float64, b float64, tolerance float64) bool {
+	return math.Abs(a - b) <= tolerance
+}
+
+func TestMeasureSine(t *testing.T) {
+	// A 997 Hz sine at 0 dBFS in one channel measures -3.01 LUFS.
+	r := Measure(testSine(997, 0.5, 0, 3, 48000, 1), 1, 48000)
+	if !near(r.Integrated, -3.01 - 6.02, 0.1) {
+		t.Fatalf("expect -9.03 LUFS, got %f", r.Integrated)
+	}
+	if !near(r.RMS, -9.03, 0.05) || !near(r.SamplePeak, -6.02, 0.05) {
+		t.Fatalf("unexpected RMS %f or sample peak %f", r.RMS, r.SamplePeak)
+	}
+	if r.Duration != 3 {
+		t.Fatalf("expect 3 seconds, got %f", r.Duration)
+	}
+
+	// Both channels of a stereo signal add up.
+	r = Measure(testSine(997, 0.5, 0, 3, 44100, 2), 2, 44100)
+	if !near(r.Integrated, -6.02, 0.1) {
+		t.Fatalf("expect -6.02 LUFS, got %f", r.Integrated)
+	}
+}
+
+func TestTruePeak(t *testing.T) {
+	// Samples of a sine at a quarter of the sample rate shifted by 45 degrees
+	// never hit the crest.
+	r := Measure(testSine(12000, 0.5, math.Pi / 4, 0.5, 48000, 1), 1, 48000)
+	if !near(r.SamplePeak, -9.03, 0.05) {
+		t.Fatalf("expect sample peak at -9.03 dBFS, got %f", r.SamplePeak)
+	}
+	if !near(r.TruePeak, -6.02, 0.3) {
+		t.Fatalf("expect true peak at -6.02 dBTP, got %f", r.TruePeak)
+	}
+}
+
+func TestMeasureShort(t *testing.T) {
+	short := Measure(testSine(997, 0.5, 0, 0.1, 48000, 1), 1, 48000)
+	if !near(short.Integrated, -9.03, 0.3) {
+		t.Fatalf("expect short sound to be measured as a single block, got %f", short.Integrated)
+	}
+	silence := Measure(make([]int16, 48000), 1, 48000)
+	if silence.Integrated != MinLevel || silence.TruePeak != MinLevel || silence.RMS != MinLevel {
+		t.Fatalf("expect silence at %f, got %v", MinLevel, silence)
+	}
+	empty := Measure(nil, 2, 48000)
+	if empty.Integrated != MinLevel || empty.Duration != 0 {
+		t.Fatalf("unexpected empty result %v", empty)
+	}
+}
+
+func TestGating(t *testing.T) {
+	// One second of tone followed by nine seconds far below the relative gate.
+	// Only blocks that overlap the tone pass the gate: 7 full blocks and 3
+	// blocks with 75 %, 50 % and 25 % of the tone.
+	tone := testSine(997, 0.5, 0, 1, 48000, 1)
+	quiet := testSine(997, 0.005, 0, 9, 48000, 1)
+	r := Measure(append(tone, quiet...), 1, 48000)
+	if !near(r.Integrated, -9.03 + 10 * math.Log10(8.5 / 10), 0.05) {
+		t.Fatalf("expect quiet part to be gated, got %f", r.Integrated)
+	}
+}
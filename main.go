@@ -6,6 +6,7 @@ import (
 	"flag"
 	"fmt"
 	"log/slog"
+	"math"
 	"os"
 	"time"
 )
@@ -16,7 +17,8 @@ func main() {
 		"insert_archive",
 		false, 
 		"Insert records of all archives in the `data` folder into `archive` " + 
-		"table.",
+		"table. Tags and categories are read from `archive_csv`. Records of " +
+		"existing archives are updated.",
 	)
 	generate := flag.Bool(
 		"generate",
@@ -47,6 +49,110 @@ func main() {
 		"a sound bank name. Multi-selective is enable. Use `Tab` to select / " +
 		"deselect",
 	)
+	extractAsset := flag.Bool(
+		"extract_asset",
+		false,
+		"Extract main data, stream part and GPU resource part of an asset " +
+		"specified by `aid`, `fid` and `tid`. Offsets are read from `asset` " +
+		"table.",
+	)
+	extractSource := flag.Bool(
+		"extract_source",
+		false,
+		"Extract the audio of the source specified by `sid` into `dest` in " +
+		"`format`. Location is read from `source_location` table.",
+	)
+	traceSoundbank := flag.Bool(
+		"trace_soundbank",
+		false,
+		"Parse a sound bank specified by `aid` and `fid` with tracing enabled. " +
+		"Write an annotated hex dump of every hierarchy object and a report of " +
+		"bytes not consumed into `dest` (stdout if `dest` is not provided).",
+	)
+	fsck := flag.Bool(
+		"fsck",
+		false,
+		"Verify ToC, asset ranges, type counts, sound bank headers and wwise " +
+		"dependencies of all archives in `data` folder. Write a JSON report " +
+		"into `dest` (stdout if `dest` is not provided). Exit with 2 if any " +
+		"problem is found.",
+	)
+	analyzeToc := flag.Bool(
+		"analyze_toc",
+		false,
+		"Gather unknown fields of the ToC of all archives in `data` folder and " +
+		"test them against known properties (alignment, sizes, counts). Write " +
+		"a JSON report into `dest` (stdout if `dest` is not provided).",
+	)
+	localized := flag.Bool(
+		"localized",
+		false,
+		"List the sound bank specified by `aid` and `fid` in every language " +
+		"using `soundbank` table.",
+	)
+	matchSources := flag.Bool(
+		"match_sources",
+		false,
+		"Map source IDs of the previous build (`PREV_GOOSE_DBSTRING`, or " +
+		"`${GOOSE_DBSTRING}_backup` if not set) to source IDs of the current build by content " +
+		"hash and audio fingerprint. Write `old_sid,new_sid,confidence,method` " +
+		"as CSV into `dest` (stdout if `dest` is not provided).",
+	)
+	duplicates := flag.Bool(
+		"duplicates",
+		false,
+		"Write groups of sources that carry the same audio under different " +
+		"source IDs (`source_duplicate` table), along with the sound banks " +
+		"using them, as JSON into `dest` (stdout if `dest` is not provided).",
+	)
+	compareLoudness := flag.Bool(
+		"compare_loudness",
+		false,
+		"Compare the loudness of the source specified by `sid` with the WAV " +
+		"at `replacement`. Write integrated loudness, true peak, RMS, and the " +
+		"gain that matches the original as JSON into `dest` (stdout if " +
+		"`dest` is not provided). Only PCM and Wwise IMA ADPCM originals are " +
+		"decoded. A Vorbis or Opus original is reported as not decodable and " +
+		"only the replacement is measured.",
+	)
+	validateReplacement := flag.Bool(
+		"validate_replacement",
+		false,
+		"Validate the WEM or WAV at `replacement` against the source specified " +
+		"by `sid`: codec, channels, sample rate, loop points, length and " +
+		"prefetched head. Write a JSON report into `dest` (stdout if `dest` " +
+		"is not provided). Exit with 2 if the replacement is not valid.",
+	)
+	exportWaveform := flag.Bool(
+		"export_waveform",
+		false,
+		"Export the waveform envelope of the source specified by `sid` (every " +
+		"decodable source if `sid` is not provided) from `source_waveform` " +
+		"table into `dest` folder as `<sid>.json`.",
+	)
+	importLabel := flag.Bool(
+		"import_label",
+		false,
+		"Import the label JSON files in `label_dir` into `label_entry` table, " +
+		"and names, descriptions and tags into `hierarchy`, `hierarchy_tag` " +
+		"and `source_tag` table. Labels of a previous import are replaced. " +
+		"Write a JSON report into `dest` (stdout if `dest` is not provided). " +
+		"Invalid files are reported and skipped.",
+	)
+	searchLabel := flag.Bool(
+		"search",
+		false,
+		"Search archives, sound banks, hierarchy objects and sources that " +
+		"have every tag in `tags` and every category in `categories`. Write " +
+		"the result as JSON into `dest` (stdout if `dest` is not provided). " +
+		"List all tags and categories if neither is provided.",
+	)
+	skipHash := flag.Bool(
+		"skip_hash",
+		false,
+		"Do not hash the content of assets when generating `asset` table. " +
+		"Unchanged assets compared with the previous build are not marked.",
+	)
 	insertArchiveDeadline := flag.Uint64(
 		"insert_archive_deadline",
 		12,
@@ -57,6 +163,12 @@ func main() {
 		560,
 		"deadline for generating database in seconds",
 	)
+	hashDeadline := flag.Uint64(
+		"hash_deadline",
+		1800,
+		"seconds added to `generate_deadline` for hashing every byte of the " +
+		"data folder (not added with `skip_hash`)",
+	)
 	exportIdDeadline := flag.Uint64(
 		"export_id_deadline",
 		4,
@@ -64,9 +176,73 @@ func main() {
 	)
 	data := flag.String("data", "", "")
 	dest := flag.String("dest", "", "")
+	aid := flag.String("aid", "", "archive ID")
+	fid := flag.Uint64("fid", 0, "file ID of an asset")
+	tid := flag.Uint64("tid", 0, "type ID of an asset")
+	sid := flag.Uint64("sid", 0, "source ID of an audio source")
+	tags := flag.String("tags", "", "tags separated by `;`")
+	categories := flag.String("categories", "", "categories separated by `;`")
+	replacement := flag.String("replacement", "", "replacement audio (WAV or WEM) of a source")
+	format := flag.String(
+		"format",
+		db.SourceFormatWem,
+		"format of extracted sources: `wem` (as is), `ogg` (Wwise Vorbis only), " +
+		"`wav` (PCM and Wwise IMA ADPCM only), or `opus` (Wwise Opus only). " +
+		"Besides `extract_source`, `extract_asset` converts the stream part of " +
+		"a WwiseStream asset, and `extract_asset` and sound bank extraction " +
+		"convert the sources embedded in sound banks.",
+	)
+	codebooks := flag.String(
+		"codebooks",
+		"",
+		"packed codebook library (e.g. `packed_codebooks_aoTuV_603.bin`) for " +
+		"Wwise Vorbis with external codebooks. The embedded aoTuV 6.03 library " +
+		"is used if not provided.",
+	)
+	inlineCodebooks := flag.Bool(
+		"inline_codebooks",
+		false,
+		"Wwise Vorbis sources store codebooks inline instead of referencing a " +
+		"codebook library. `codebooks` is ignored.",
+	)
+	archiveCsv := flag.String(
+		"archive_csv",
+		db.ArchiveCSV,
+		"folder of `<category>.csv` files that assign tags and categories to " +
+		"archives when inserting `archive` table",
+	)
+	labelDir := flag.String(
+		"label_dir",
+		db.LabelDir,
+		"folder of the label JSON files",
+	)
+	tocIndex := flag.String(
+		"toc_index",
+		"",
+		"path of the persistent ToC index used by sound bank extraction " +
+		"(default to `hd2_audio_db/toc_index.gob` in the user cache directory)",
+	)
 
 	flag.Parse()
 
+	if *skipHash {
+		db.HashAssets = false
+	}
+	if *tocIndex != "" {
+		db.TocIndexPath = *tocIndex
+	}
+	switch *format {
+	case db.SourceFormatWem, db.SourceFormatOgg, db.SourceFormatWav, db.SourceFormatOpus:
+		db.SourceFormat = *format
+	default:
+		slog.Error("Unknown source format", "format", *format)
+		os.Exit(1)
+	}
+	db.CodebookPath = *codebooks
+	db.InlineCodebooks = *inlineCodebooks
+	db.ArchiveCSV = *archiveCsv
+	db.LabelDir = *labelDir
+
 	if *data != "" {
 		slog.Info("Using data path from argument.")
 		stat, err := os.Lstat(*data)
@@ -106,7 +282,11 @@ func main() {
 	}
 
 	if *generate {
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second * time.Duration(*generationDeadline))
+		deadline := *generationDeadline
+		if db.HashAssets {
+			deadline += *hashDeadline
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second * time.Duration(deadline))
 		defer cancel()
 		if err := db.Generate(ctx, *data); err != nil {
 			slog.Error(
@@ -174,5 +354,291 @@ func main() {
 		os.Exit(0)
 	}
 
+	if *extractAsset {
+		if *dest == "" {
+			slog.Error("Destination for output asset is not provided")
+			os.Exit(1)
+		}
+		if *aid == "" || *fid == 0 || *tid == 0 {
+			slog.Error("`aid`, `fid` and `tid` are required to locate an asset")
+			os.Exit(1)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second * 16)
+		defer cancel()
+		if err := db.ExtractAsset(ctx, *data, *dest, *aid, *fid, *tid); err != nil {
+			slog.Error("Failed to extract asset", "error", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if *extractSource {
+		if *dest == "" {
+			slog.Error("Destination for output source is not provided")
+			os.Exit(1)
+		}
+		if *sid == 0 || *sid > math.MaxUint32 {
+			slog.Error("A valid `sid` is required to locate a source")
+			os.Exit(1)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second * 16)
+		defer cancel()
+		if err := db.ExtractSource(ctx, *data, *dest, uint32(*sid)); err != nil {
+			slog.Error("Failed to extract source", "error", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if *traceSoundbank {
+		if *aid == "" || *fid == 0 {
+			slog.Error("`aid` and `fid` are required to locate a sound bank")
+			os.Exit(1)
+		}
+		if err := db.TraceSoundbankToFile(*data, *aid, *fid, *dest); err != nil {
+			slog.Error("Failed to trace sound bank", "error", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if *fsck {
+		out := os.Stdout
+		if *dest != "" {
+			f, err := os.Create(*dest)
+			if err != nil {
+				slog.Error("Failed to create report", "error", err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			out = f
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second * 120)
+		defer cancel()
+		report, err := db.Fsck(ctx, *data, out)
+		if err != nil {
+			slog.Error("Failed to verify archives", "error", err)
+			os.Exit(1)
+		}
+		if len(report.Problems) > 0 {
+			slog.Error(
+				"Found problems in archives",
+				"archives", report.Archives,
+				"problems", len(report.Problems),
+			)
+			os.Exit(2)
+		}
+		os.Exit(0)
+	}
+
+	if *analyzeToc {
+		out := os.Stdout
+		if *dest != "" {
+			f, err := os.Create(*dest)
+			if err != nil {
+				slog.Error("Failed to create report", "error", err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			out = f
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second * 120)
+		defer cancel()
+		if _, err := db.AnalyzeToc(ctx, *data, out); err != nil {
+			slog.Error("Failed to analyze ToC", "error", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if *localized {
+		if *aid == "" || *fid == 0 {
+			slog.Error("`aid` and `fid` are required to locate a sound bank")
+			os.Exit(1)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second * 8)
+		defer cancel()
+		if err := db.WriteLocalizedSoundbanks(ctx, *aid, *fid, os.Stdout); err != nil {
+			slog.Error("Failed to list localized sound banks", "error", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if *matchSources {
+		out := os.Stdout
+		if *dest != "" {
+			f, err := os.Create(*dest)
+			if err != nil {
+				slog.Error("Failed to create report", "error", err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			out = f
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second * 120)
+		defer cancel()
+		if err := db.MatchSources(ctx, out); err != nil {
+			slog.Error("Failed to match sources with previous build", "error", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if *duplicates {
+		out := os.Stdout
+		if *dest != "" {
+			f, err := os.Create(*dest)
+			if err != nil {
+				slog.Error("Failed to create report", "error", err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			out = f
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second * 60)
+		defer cancel()
+		if err := db.WriteDuplicates(ctx, out); err != nil {
+			slog.Error("Failed to write duplicate sources", "error", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if *compareLoudness {
+		if *sid == 0 || *sid > math.MaxUint32 {
+			slog.Error("A valid `sid` is required to locate a source")
+			os.Exit(1)
+		}
+		if *replacement == "" {
+			slog.Error("`replacement` is not provided")
+			os.Exit(1)
+		}
+		out := os.Stdout
+		if *dest != "" {
+			f, err := os.Create(*dest)
+			if err != nil {
+				slog.Error("Failed to create report", "error", err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			out = f
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second * 16)
+		defer cancel()
+		if err := db.CompareLoudness(ctx, *data, uint32(*sid), *replacement, out); err != nil {
+			slog.Error("Failed to compare loudness", "error", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if *validateReplacement {
+		if *sid == 0 || *sid > math.MaxUint32 {
+			slog.Error("A valid `sid` is required to locate a source")
+			os.Exit(1)
+		}
+		if *replacement == "" {
+			slog.Error("`replacement` is not provided")
+			os.Exit(1)
+		}
+		out := os.Stdout
+		if *dest != "" {
+			f, err := os.Create(*dest)
+			if err != nil {
+				slog.Error("Failed to create report", "error", err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			out = f
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second * 16)
+		defer cancel()
+		report, err := db.WriteReplacementReport(ctx, *data, uint32(*sid), *replacement, out)
+		if err != nil {
+			slog.Error("Failed to validate replacement", "error", err)
+			os.Exit(1)
+		}
+		if !report.Valid() {
+			slog.Error(
+				"Replacement is not valid",
+				"sid", *sid,
+				"problems", len(report.Problems),
+			)
+			os.Exit(2)
+		}
+		os.Exit(0)
+	}
+
+	if *importLabel {
+		out := os.Stdout
+		if *dest != "" {
+			f, err := os.Create(*dest)
+			if err != nil {
+				slog.Error("Failed to create report", "error", err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			out = f
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second * 60)
+		defer cancel()
+		if _, err := db.ImportLabels(ctx, db.LabelDir, out); err != nil {
+			slog.Error("Failed to import labels", "error", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if *searchLabel {
+		out := os.Stdout
+		if *dest != "" {
+			f, err := os.Create(*dest)
+			if err != nil {
+				slog.Error("Failed to create report", "error", err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			out = f
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second * 60)
+		defer cancel()
+		if err := db.Search(ctx, *tags, *categories, out); err != nil {
+			slog.Error("Failed to search by tags and categories", "error", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if *exportWaveform {
+		if *dest == "" {
+			slog.Error("`dest` is not provided")
+			os.Exit(1)
+		}
+		if *sid > math.MaxUint32 {
+			slog.Error("Invalid `sid`")
+			os.Exit(1)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second * 120)
+		defer cancel()
+		if err := db.ExportWaveforms(ctx, *dest, uint32(*sid)); err != nil {
+			slog.Error("Failed to export waveforms", "error", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	flag.Usage()
 }
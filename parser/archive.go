@@ -20,10 +20,13 @@ const (
 	AssetTypeSoundBank       AssetType = 6006249203084351385
 	AssetTypeWwiseDependency           = 12624162998411505776
 	AssetTypeWwiseStream               = 5785811756662211598
+	AssetTypeStrings                   = 979299457696010195
 )
 
 const MagicValue uint32 = 0xF0000011
 
+const sizeOfArchiveHeader = 72
+
 func ParseArchiveHeader(a *Archive, r *io.Reader) {
 	if r.U32Unsafe() != MagicValue {
 		panic(NotHelldiversGameArchive)
@@ -34,7 +37,7 @@ func ParseArchiveHeader(a *Archive, r *io.Reader) {
 	r.ReadFullUnsafe(a.Unk4Data[:])
 	a.AssetTypeCnts = make([]AssetTypeCnt, a.NumTypes, a.NumTypes)
 	for i := range a.AssetTypeCnts {
-		r.U64Unsafe()
+		a.AssetTypeCnts[i].Unknown = r.U64Unsafe()
 		a.AssetTypeCnts[i].Type = r.U64Unsafe()
 		a.AssetTypeCnts[i].Num = r.U64Unsafe()
 		if a.AssetTypeCnts[i].Type == uint64(AssetTypeSoundBank) {
@@ -42,14 +45,14 @@ func ParseArchiveHeader(a *Archive, r *io.Reader) {
 		} else if a.AssetTypeCnts[i].Type == uint64(AssetTypeWwiseDependency) {
 			a.Deps = make([]uint32, 0, a.AssetTypeCnts[i].Num)
 		}
-		r.U32Unsafe()
-		r.U32Unsafe()
+		a.AssetTypeCnts[i].UnknownU32A = r.U32Unsafe()
+		a.AssetTypeCnts[i].UnknownU32B = r.U32Unsafe()
 	}
 	a.Headers = make([]AssetHeader, a.NumFiles, a.NumFiles)
 }
 
 func ParseArchiveHeaderCache(a *Archive, r *io.Reader) {
-	data := make([]byte, 72, 72)
+	data := make([]byte, sizeOfArchiveHeader, sizeOfArchiveHeader)
 	r.ReadFullUnsafe(data)
 
 	ir := io.NewInPlaceReader(data, io.ByteOrder)
@@ -62,13 +65,13 @@ func ParseArchiveHeaderCache(a *Archive, r *io.Reader) {
 	a.Unk4Data = [56]byte(ir.ReadNoCopyUnsafe(56))
 	a.AssetTypeCnts = make([]AssetTypeCnt, a.NumTypes, a.NumTypes)
 
-	size := a.NumTypes * (sizeOfAssetCnt + 16)
+	size := a.NumTypes * sizeOfAssetCnt
 	data = make([]byte, size, size)
 	r.ReadFullUnsafe(data)
 
 	ir = io.NewInPlaceReader(data, io.ByteOrder)
 	for i := range a.AssetTypeCnts {
-		ir.RelSeekUnsafe(8)
+		a.AssetTypeCnts[i].Unknown = ir.U64Unsafe()
 		a.AssetTypeCnts[i].Type = ir.U64Unsafe()
 		a.AssetTypeCnts[i].Num = ir.U64Unsafe()
 		if a.AssetTypeCnts[i].Type == uint64(AssetTypeSoundBank) {
@@ -76,7 +79,8 @@ func ParseArchiveHeaderCache(a *Archive, r *io.Reader) {
 		} else if a.AssetTypeCnts[i].Type == uint64(AssetTypeWwiseDependency) {
 			a.Deps = make([]uint32, 0, a.AssetTypeCnts[i].Num)
 		}
-		ir.RelSeekUnsafe(8)
+		a.AssetTypeCnts[i].UnknownU32A = ir.U32Unsafe()
+		a.AssetTypeCnts[i].UnknownU32B = ir.U32Unsafe()
 	}
 	a.Headers = make([]AssetHeader, a.NumFiles, a.NumFiles)
 }
@@ -132,9 +136,45 @@ func ParseAssetHeadersCachePerThread(a *Archive, r *io.Reader) {
 }
 
 func ParseAssetHeaders(a *Archive, r *io.Reader) {
+	data := make([]byte, a.NumFiles * sizeOfAssetHeader, a.NumFiles * sizeOfAssetHeader)
+	r.ReadFullUnsafe(data)
+	parseAssetHeaders(a, data)
+}
+
+// ParseArchiveHeaderInPlace is the same as ParseArchiveHeader except it reads 
+// from an InPlaceReader, e.g. one that is created from MmapReader.
+func ParseArchiveHeaderInPlace(a *Archive, r *io.InPlaceReader) {
+	if r.U32Unsafe() != MagicValue {
+		panic(NotHelldiversGameArchive)
+	}
+	a.NumTypes = r.U32Unsafe()
+	a.NumFiles = r.U32Unsafe()
+	a.Unknown = r.U32Unsafe()
+	a.Unk4Data = [56]byte(r.ReadNoCopyUnsafe(56))
+	a.AssetTypeCnts = make([]AssetTypeCnt, a.NumTypes, a.NumTypes)
+	for i := range a.AssetTypeCnts {
+		a.AssetTypeCnts[i].Unknown = r.U64Unsafe()
+		a.AssetTypeCnts[i].Type = r.U64Unsafe()
+		a.AssetTypeCnts[i].Num = r.U64Unsafe()
+		if a.AssetTypeCnts[i].Type == uint64(AssetTypeSoundBank) {
+			a.SoundBnks = make([]uint32, 0, a.AssetTypeCnts[i].Num)
+		} else if a.AssetTypeCnts[i].Type == uint64(AssetTypeWwiseDependency) {
+			a.Deps = make([]uint32, 0, a.AssetTypeCnts[i].Num)
+		}
+		a.AssetTypeCnts[i].UnknownU32A = r.U32Unsafe()
+		a.AssetTypeCnts[i].UnknownU32B = r.U32Unsafe()
+	}
+	a.Headers = make([]AssetHeader, a.NumFiles, a.NumFiles)
+}
+
+// ParseAssetHeadersInPlace is the same as ParseAssetHeaders except the asset 
+// headers are decoded directly from the buffer of r without copying.
+func ParseAssetHeadersInPlace(a *Archive, r *io.InPlaceReader) {
+	parseAssetHeaders(a, r.ReadNoCopyUnsafe(uint(a.NumFiles * sizeOfAssetHeader)))
+}
+
+func parseAssetHeaders(a *Archive, data []byte) {
 	if MaxParser > 1 && MaxParser < a.NumFiles {
-		data := make([]byte, a.NumFiles*80, a.NumFiles*80)
-		r.ReadFullUnsafe(data)
 		var w sync.WaitGroup
 		base := a.NumFiles / MaxParser
 		prev := uint32(0)
@@ -155,8 +195,6 @@ func ParseAssetHeaders(a *Archive, r *io.Reader) {
 		}
 		w.Wait()
 	} else {
-		data := make([]byte, a.NumFiles * sizeOfAssetHeader, a.NumFiles * sizeOfAssetHeader)
-		r.ReadFullUnsafe(data)
 		r := io.NewInPlaceReader(data, io.ByteOrder)
 		for i := range a.NumFiles {
 			a.Headers[i].FileID = r.U64Unsafe()
@@ -218,3 +256,21 @@ func parseAssetHeader(
 	a.depMu.Unlock()
 	w.Done()
 }
+
+// ParseArchiveMmap parses the archive header and all asset headers of an 
+// archive mapped by m. Only the ToC region is sliced, so it stays cheap with 
+// the non-Linux fallback of MmapReader where slicing means copying.
+func ParseArchiveMmap(a *Archive, m *io.MmapReader) {
+	r := m.NewInPlaceReaderUnsafe(0, 12)
+	if r.U32Unsafe() != MagicValue {
+		panic(NotHelldiversGameArchive)
+	}
+	numTypes := uint(r.U32Unsafe())
+	numFiles := uint(r.U32Unsafe())
+	size := sizeOfArchiveHeader + 
+	        numTypes * sizeOfAssetCnt + 
+	        numFiles * sizeOfAssetHeader
+	r = m.NewInPlaceReaderUnsafe(0, size)
+	ParseArchiveHeaderInPlace(a, r)
+	ParseAssetHeadersInPlace(a, r)
+}
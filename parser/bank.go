@@ -3,8 +3,8 @@ package parser
 import (
 	"bytes"
 	wio "dekr0/hd2_audio_db/io"
+	"fmt"
 	"io"
-	"log/slog"
 )
 
 type HircType uint8
@@ -60,21 +60,74 @@ var HircTypeName []string = []string{
     "Time Modulator",
 }
 
+var bkhdTag []byte = []byte{'B', 'K', 'H', 'D'}
+var hircTag []byte = []byte{'H', 'I', 'R', 'C'}
+var didxTag []byte = []byte{'D', 'I', 'D', 'X'}
+var dataTag []byte = []byte{'D', 'A', 'T', 'A'}
+
+// ParseBKHD parses the bank header. `r` must be positioned at the BKHD tag, 
+// i.e., right after the 16 bytes header of the game.
+func ParseBKHD(r wio.Decoder) (*BKHD, error) {
+	tag, err := r.FourCC()
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(tag, bkhdTag) {
+		return nil, fmt.Errorf("Expect BKHD, found %q", tag)
+	}
+	size, err := r.U32()
+	if err != nil {
+		return nil, err
+	}
+	if size < 12 {
+		return nil, fmt.Errorf("BKHD is too small (%d bytes)", size)
+	}
+	bkhd := BKHD{}
+	name(r, "dwBankGeneratorVersion")
+	if bkhd.Version, err = r.U32(); err != nil {
+		return nil, err
+	}
+	name(r, "dwSoundBankID")
+	if bkhd.BankID, err = r.U32(); err != nil {
+		return nil, err
+	}
+	name(r, "dwLanguageID")
+	if bkhd.LanguageID, err = r.U32(); err != nil {
+		return nil, err
+	}
+	return &bkhd, r.RelSeek(int(size) - 12)
+}
+
+// ParseBank parses a sound bank with a reader positioned right after the 16 
+// bytes header of the game. `end` is the absolute position where the data of 
+// the sound bank ends.
 func ParseBank(r *wio.Reader, end uint64) *HIRC {
-	tag := make([]byte, 4, 4)
-	var size uint32
-	var err error
-	for r.Tell() <= uint(end) {
-		err = r.ReadFull(tag)
+	return parseBank(r, uint(end))
+}
+
+// ParseBankInPlace parses a sound bank with a reader that spans exactly the 
+// data of the sound bank (without the 16 bytes header of the game). `end` is 
+// ignored since the end of the buffer is the end of the sound bank.
+func ParseBankInPlace(r *wio.InPlaceReader, end uint64) *HIRC {
+	return parseBank(r, r.Cap())
+}
+
+func parseBank(r wio.Decoder, end uint) *HIRC {
+	for r.Tell() < end {
+		tag, err := r.FourCC()
 		if err != nil {
-			if err == io.EOF {
+			if err == io.EOF || 
+			   err == io.ErrUnexpectedEOF || 
+			   err == io.ErrShortBuffer {
 				break
 			}
 			panic(err)
 		}
-		size = r.U32Unsafe()
-		if bytes.Compare(tag, []byte{'H', 'I', 'R', 'C'}) != 0 {
-			r.RelSeekUnsafe(int(size))
+		size := r.U32Unsafe()
+		if !bytes.Equal(tag, hircTag) {
+			if err := r.RelSeek(int(size)); err != nil {
+				break
+			}
 			continue
 		}
 		end := r.Tell() + uint(size)
@@ -87,7 +140,96 @@ func ParseBank(r *wio.Reader, end uint64) *HIRC {
 	return nil
 }
 
-func parseHIRC(r *wio.Reader) *HIRC {
+const sizeOfMedia = 12
+
+// ParseMediaIndex parses DIDX and locates DATA of a sound bank with a reader 
+// positioned right after the 16 bytes header of the game. `end` is the 
+// absolute position where the data of the sound bank ends. A sound bank 
+// without DIDX yields an empty index.
+func ParseMediaIndex(r wio.Decoder, end uint) (*MediaIndex, error) {
+	start := r.Tell()
+	idx := MediaIndex{Media: []Media{}}
+	hasData := false
+	for r.Tell() < end {
+		tag, err := r.FourCC()
+		if err != nil {
+			return nil, err
+		}
+		size, err := r.U32()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case bytes.Equal(tag, didxTag):
+			if size % sizeOfMedia != 0 {
+				return nil, fmt.Errorf("DIDX size %d is not a multiple of %d", size, sizeOfMedia)
+			}
+			idx.Media = make([]Media, size / sizeOfMedia)
+			for i := range idx.Media {
+				name(r, "sourceID")
+				if idx.Media[i].SourceID, err = r.U32(); err != nil {
+					return nil, err
+				}
+				name(r, "uOffset")
+				if idx.Media[i].Offset, err = r.U32(); err != nil {
+					return nil, err
+				}
+				name(r, "uSize")
+				if idx.Media[i].Size, err = r.U32(); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		case bytes.Equal(tag, dataTag):
+			idx.DataOffset = r.Tell() - start
+			idx.DataSize = size
+			hasData = true
+		}
+		if err := r.RelSeek(int(size)); err != nil {
+			return nil, err
+		}
+	}
+	if !hasData {
+		if len(idx.Media) > 0 {
+			return nil, fmt.Errorf("DIDX has %d media but DATA is missing", len(idx.Media))
+		}
+		return &idx, nil
+	}
+	for _, media := range idx.Media {
+		if uint64(media.Offset) + uint64(media.Size) > uint64(idx.DataSize) {
+			return nil, fmt.Errorf(
+				"Media %d [%d, +%d) exceeds DATA size %d",
+				media.SourceID, media.Offset, media.Size, idx.DataSize,
+			)
+		}
+	}
+	return &idx, nil
+}
+
+func hircTypeName(t HircType) string {
+	if int(t) < len(HircTypeName) && t > 0 {
+		return HircTypeName[t]
+	}
+	return fmt.Sprintf("Unknown (%#x)", uint8(t))
+}
+
+// name names the next field decoded by `r` when tracing is enabled.
+func name(r wio.Decoder, n string) {
+	if t := r.Trace(); t != nil {
+		t.Name(n)
+	}
+}
+
+// consumed records the position `r` reached in the object being traced before 
+// the parser seeks to the end of the object.
+func consumed(r wio.Decoder) {
+	if t := r.Trace(); t != nil {
+		t.Consume(r.Tell())
+	}
+}
+
+func parseHIRC(r wio.Decoder) *HIRC {
+	name(r, "numReleasableHircItem")
 	n := r.U32Unsafe()
 
 	hirc := HIRC{
@@ -96,9 +238,15 @@ func parseHIRC(r *wio.Reader) *HIRC {
 		Sound: make([]Sound, 0, n / 2),
 	}
 
+	tr := r.Trace()
 	for i := range n {
+		name(r, "eHircType")
 		t := r.U8Unsafe()
+		name(r, "dwSectionSize")
 		size := r.U32Unsafe()
+		if tr != nil {
+			tr.Begin(hircTypeName(HircType(t)), r.Tell(), r.Tell() + uint(size))
+		}
 
 		hirc.Hierarchy[i].Type = HircType(t)
 		switch hirc.Hierarchy[i].Type {
@@ -123,9 +271,7 @@ func parseHIRC(r *wio.Reader) *HIRC {
 		case HircTypeMusicSegment:
 			parseMusicSegment(r, size, i, hirc.Hierarchy)
 		case HircTypeMusicTrack:
-			if err := r.RelSeek(int(size)); err != nil {
-				slog.Error("Failed to skip music track", "error", err)
-			}
+			hirc.Sound = parseMusicTrack(r, size, i, hirc.Hierarchy, hirc.Sound)
 		case HircTypeMusicSwitchCntr:
 			parseMusicSwitchCntr(r, size, i, hirc.Hierarchy)
 		case HircTypeMusicRanSeqCntr:
@@ -149,19 +295,24 @@ func parseHIRC(r *wio.Reader) *HIRC {
 		case HircTimeModulator:
 			parseTimeModulator(r, size, i, hirc.Hierarchy)
 		}
+		if tr != nil {
+			tr.End()
+		}
 	}
 	return &hirc
 }
 
-func parseState(r *wio.Reader, size uint32, i uint32, hirc []Hierarchy) {
+func parseState(r wio.Decoder, size uint32, i uint32, hirc []Hierarchy) {
 	begin := r.Tell()
 	end := begin + uint(size)
+	name(r, "ulID")
 	hirc[i].ID = r.U32Unsafe()
+	consumed(r)
 	r.AbsSeekUnsafe(end)
 }
 
 func parseSound(
-	r *wio.Reader,
+	r wio.Decoder,
 	size uint32,
 	i uint32,
 	hirc []Hierarchy,
@@ -170,6 +321,7 @@ func parseSound(
 	begin := r.Tell()
 	end := begin + uint(size)
 
+	name(r, "ulID")
 	hirc[i].ID = r.U32Unsafe()
 
 	sound := Sound{Idx: i}
@@ -180,23 +332,31 @@ func parseSound(
 
 	hirc[i].Parent = parseBaseParam(r)
 
+	consumed(r)
 	r.AbsSeekUnsafe(end)
 
 	return sounds
 }
 
-func parseBankSourceData(r *wio.Reader, sound *Sound) {
+func parseBankSourceData(r wio.Decoder, sound *Sound) {
+	name(r, "ulPluginID")
 	sound.PluginID = r.U32Unsafe()
+	name(r, "StreamType")
 	sound.StreamType = r.U8Unsafe()
+	name(r, "sourceID")
 	sound.SourceID = r.U32Unsafe()
+	name(r, "uCacheID")
 	r.U32Unsafe() // cache ID 150; 141 don't have this
+	name(r, "uInMemoryMediaSize")
 	sound.InMemoryMediaSize = r.U32Unsafe()
+	name(r, "uSourceBits")
 	sound.SourceBits = r.U8Unsafe()
 	sound.PluginParamSize = 0
 
 	hasParam := (sound.PluginID & 0x0F) == 2 && 
 	            (sound.PluginID != 0)
 	if hasParam {
+		name(r, "uSize")
 		sound.PluginParamSize = r.U32Unsafe()
 		if sound.PluginParamSize > 0 {
 			r.RelSeekUnsafe(int(sound.PluginParamSize))
@@ -204,10 +364,11 @@ func parseBankSourceData(r *wio.Reader, sound *Sound) {
 	}
 }
 
-func parseBaseParam(r *wio.Reader) uint32 {
+func parseBaseParam(r wio.Decoder) uint32 {
 	r.RelSeekUnsafe(1) // BitIsOverrideParentFx
 
 	// FxChunk
+	name(r, "uNumFx")
 	uniqueNumFX := r.U8Unsafe()
 	if uniqueNumFX > 0 {
 		r.RelSeekUnsafe(1)
@@ -216,6 +377,7 @@ func parseBaseParam(r *wio.Reader) uint32 {
 
 	// FxChunkMetadata
 	r.RelSeekUnsafe(1)
+	name(r, "uNumFxMetadata")
 	uniqueNumFXMetadata := r.U8Unsafe()
 	r.RelSeekUnsafe(int(uniqueNumFXMetadata) * 6)
 
@@ -225,75 +387,93 @@ func parseBaseParam(r *wio.Reader) uint32 {
 	// v150
 	r.RelSeekUnsafe(4) // BitOverrideAttachmentParams + OverrideBusId
 
+	name(r, "DirectParentID")
 	return r.U32Unsafe()
 }
 
-func parseAction(r *wio.Reader, size uint32, i uint32, hirc []Hierarchy) {
+func parseAction(r wio.Decoder, size uint32, i uint32, hirc []Hierarchy) {
 	begin := r.Tell()
 	end := begin + uint(size)
+	name(r, "ulID")
 	hirc[i].ID = r.U32Unsafe()
 	r.RelSeekUnsafe(2) // ulActionType
+	name(r, "idExt")
 	hirc[i].Parent = r.U32Unsafe() // idExt
+	consumed(r)
 	r.AbsSeekUnsafe(end)
 }
 
-func parseEvent(r *wio.Reader, size uint32, i uint32, hirc []Hierarchy) {
+func parseEvent(r wio.Decoder, size uint32, i uint32, hirc []Hierarchy) {
 	begin := r.Tell()
 	end := begin + uint(size)
+	name(r, "ulID")
 	hirc[i].ID = r.U32Unsafe()
+	consumed(r)
 	r.AbsSeekUnsafe(end)
 }
 
-func parseRanSeqCntr(r *wio.Reader, size uint32, i uint32, hirc []Hierarchy) {
+func parseRanSeqCntr(r wio.Decoder, size uint32, i uint32, hirc []Hierarchy) {
 	begin := r.Tell()
 	end := begin + uint(size)
+	name(r, "ulID")
 	hirc[i].ID = r.U32Unsafe()
 	hirc[i].Parent = parseBaseParam(r)
+	consumed(r)
 	r.AbsSeekUnsafe(end)
 }
 
-func parseSwitchCntr(r *wio.Reader, size uint32, i uint32, hirc []Hierarchy) {
+func parseSwitchCntr(r wio.Decoder, size uint32, i uint32, hirc []Hierarchy) {
 	begin := r.Tell()
 	end := begin + uint(size)
+	name(r, "ulID")
 	hirc[i].ID = r.U32Unsafe()
 	hirc[i].Parent = parseBaseParam(r)
+	consumed(r)
 	r.AbsSeekUnsafe(end)
 }
 
-func parseActorMixer(r *wio.Reader, size uint32, i uint32, hirc []Hierarchy) {
+func parseActorMixer(r wio.Decoder, size uint32, i uint32, hirc []Hierarchy) {
 	begin := r.Tell()
 	end := begin + uint(size)
+	name(r, "ulID")
 	hirc[i].ID = r.U32Unsafe()
 	hirc[i].Parent = parseBaseParam(r)
+	consumed(r)
 	r.AbsSeekUnsafe(end)
 }
 
-func parseBus(r *wio.Reader, size uint32, i uint32, hirc []Hierarchy) {
+func parseBus(r wio.Decoder, size uint32, i uint32, hirc []Hierarchy) {
 	begin := r.Tell()
 	end := begin + uint(size)
+	name(r, "ulID")
 	hirc[i].ID = r.U32Unsafe()
+	consumed(r)
 	r.AbsSeekUnsafe(end)
 }
 
-func parseLayerCntr(r *wio.Reader, size uint32, i uint32, hirc []Hierarchy) {
+func parseLayerCntr(r wio.Decoder, size uint32, i uint32, hirc []Hierarchy) {
 	begin := r.Tell()
 	end := begin + uint(size)
+	name(r, "ulID")
 	hirc[i].ID = r.U32Unsafe()
 	hirc[i].Parent = parseBaseParam(r)
+	consumed(r)
 	r.AbsSeekUnsafe(end)
 }
 
-func parseMusicSegment(r *wio.Reader, size uint32, i uint32, hirc []Hierarchy) {
+func parseMusicSegment(r wio.Decoder, size uint32, i uint32, hirc []Hierarchy) {
 	begin := r.Tell()
 	end := begin + uint(size)
+	name(r, "ulID")
 	hirc[i].ID = r.U32Unsafe()
 	r.RelSeekUnsafe(1) // uFlags
 	hirc[i].Parent = parseBaseParam(r)
+	consumed(r)
 	r.AbsSeekUnsafe(end)
 }
 
 func parseMusicTrack(
-	r *wio.Reader,
+	r wio.Decoder,
 	size uint32,
 	i uint32,
 	hirc []Hierarchy,
@@ -301,10 +481,12 @@ func parseMusicTrack(
 ) []Sound {
 	begin := r.Tell()
 	end := begin + uint(size)
+	name(r, "ulID")
 	hirc[i].ID = r.U32Unsafe()
 
 	r.RelSeekUnsafe(1) // uFlags
 
+	name(r, "numSources")
 	numSources := r.U32Unsafe()
 	for range numSources {
 		sound := Sound{Idx: i}
@@ -312,6 +494,7 @@ func parseMusicTrack(
 		sounds = append(sounds, sound)
 	}
 
+	name(r, "numPlayListItem")
 	numPlayListItem := r.U32Unsafe()
 	// trackID
 	// sourceID
@@ -325,6 +508,7 @@ func parseMusicTrack(
 		r.RelSeekUnsafe(4) // numSubTrack
 	}
 
+	name(r, "numClipAutomationItem")
 	numClipAutomationItem := r.U32Unsafe()
 	for range numClipAutomationItem {
 		// uClipIndex
@@ -339,547 +523,166 @@ func parseMusicTrack(
 
 	hirc[i].Parent = parseBaseParam(r)
 
+	consumed(r)
 	r.AbsSeekUnsafe(end)
 
 	return sounds
 }
 
 func parseMusicSwitchCntr(
-	r *wio.Reader,
+	r wio.Decoder,
 	size uint32,
 	i uint32,
 	hirc []Hierarchy,
 ) {
 	begin := r.Tell()
 	end := begin + uint(size)
+	name(r, "ulID")
 	hirc[i].ID = r.U32Unsafe()
 	r.RelSeekUnsafe(1) // uFlags
 	hirc[i].Parent = parseBaseParam(r)
+	consumed(r)
 	r.AbsSeekUnsafe(end)
 }
 
 func parseMusicRanSeqCntr(
-	r *wio.Reader,
+	r wio.Decoder,
 	size uint32,
 	i uint32,
 	hirc []Hierarchy,
 ) {
 	begin := r.Tell()
 	end := begin + uint(size)
+	name(r, "ulID")
 	hirc[i].ID = r.U32Unsafe()
 	r.RelSeekUnsafe(1) // uFlags
 	hirc[i].Parent = parseBaseParam(r)
+	consumed(r)
 	r.AbsSeekUnsafe(end)
 }
 
 func parseAttenuation(
-	r *wio.Reader,
+	r wio.Decoder,
 	size uint32,
 	i uint32,
 	hirc []Hierarchy,
 ) {
 	begin := r.Tell()
 	end := begin + uint(size)
+	name(r, "ulID")
 	hirc[i].ID = r.U32Unsafe()
+	consumed(r)
 	r.AbsSeekUnsafe(end)
 }
 
 func parseDialogueEvent(
-	r *wio.Reader,
+	r wio.Decoder,
 	size uint32,
 	i uint32,
 	hirc []Hierarchy,
 ) {
 	begin := r.Tell()
 	end := begin + uint(size)
+	name(r, "ulID")
 	hirc[i].ID = r.U32Unsafe()
+	consumed(r)
 	r.AbsSeekUnsafe(end)
 }
 
 func parseFxShareSet(
-	r *wio.Reader,
+	r wio.Decoder,
 	size uint32,
 	i uint32,
 	hirc []Hierarchy,
 ) {
 	begin := r.Tell()
 	end := begin + uint(size)
+	name(r, "ulID")
 	hirc[i].ID = r.U32Unsafe()
+	consumed(r)
 	r.AbsSeekUnsafe(end)
 }
 
 func parseFxShareCustom(
-	r *wio.Reader,
+	r wio.Decoder,
 	size uint32,
 	i uint32,
 	hirc []Hierarchy,
 ) {
 	begin := r.Tell()
 	end := begin + uint(size)
+	name(r, "ulID")
 	hirc[i].ID = r.U32Unsafe()
+	consumed(r)
 	r.AbsSeekUnsafe(end)
 }
 
 func parseAuxBus(
-	r *wio.Reader,
+	r wio.Decoder,
 	size uint32,
 	i uint32,
 	hirc []Hierarchy,
 ) {
 	begin := r.Tell()
 	end := begin + uint(size)
+	name(r, "ulID")
 	hirc[i].ID = r.U32Unsafe()
+	consumed(r)
 	r.AbsSeekUnsafe(end)
 }
 
 func parseLFOModulator(
-	r *wio.Reader,
+	r wio.Decoder,
 	size uint32,
 	i uint32,
 	hirc []Hierarchy,
 ) {
 	begin := r.Tell()
 	end := begin + uint(size)
+	name(r, "ulID")
 	hirc[i].ID = r.U32Unsafe()
+	consumed(r)
 	r.AbsSeekUnsafe(end)
 }
 
 func parseEnvelopeModulator(
-	r *wio.Reader,
+	r wio.Decoder,
 	size uint32,
 	i uint32,
 	hirc []Hierarchy,
 ) {
 	begin := r.Tell()
 	end := begin + uint(size)
+	name(r, "ulID")
 	hirc[i].ID = r.U32Unsafe()
+	consumed(r)
 	r.AbsSeekUnsafe(end)
 }
 
 func parseAudioDevice(
-	r *wio.Reader,
+	r wio.Decoder,
 	size uint32,
 	i uint32,
 	hirc []Hierarchy,
 ) {
 	begin := r.Tell()
 	end := begin + uint(size)
+	name(r, "ulID")
 	hirc[i].ID = r.U32Unsafe()
+	consumed(r)
 	r.AbsSeekUnsafe(end)
 }
 
 func parseTimeModulator(
-	r *wio.Reader,
-	size uint32,
-	i uint32,
-	hirc []Hierarchy,
-) {
-	begin := r.Tell()
-	end := begin + uint(size)
-	hirc[i].ID = r.U32Unsafe()
-	r.AbsSeekUnsafe(end)
-}
-
-func ParseBankInPlace(r *wio.InPlaceReader, end uint64) *HIRC {
-	for r.Len() > 0 {
-		tag, err := r.FourCC()
-		if err != nil {
-			break
-		}
-		size := r.U32Unsafe()
-		if bytes.Compare(tag, []byte{'H', 'I', 'R', 'C'}) != 0 {
-			if err := r.RelSeek(int(size)); err != nil {
-				break
-			}
-			continue
-		}
-		hirc := parseHIRCInPlace(r)
-		return hirc
-	}
-	return nil
-}
-
-func parseHIRCInPlace(r *wio.InPlaceReader) *HIRC {
-	n := r.U32Unsafe()
-
-	hirc := HIRC{
-		Hierarchy: make([]Hierarchy, n, n),
-		Sound: make([]Sound, 0, n / 2),
-	}
-
-	for i := range n {
-		t := r.U8Unsafe()
-		size := r.U32Unsafe()
-
-		hirc.Hierarchy[i].Type = HircType(t)
-		switch hirc.Hierarchy[i].Type {
-		case HircTypeState:
-			parseStateInPlace(r, size, i, hirc.Hierarchy)
-		case HircTypeSound:
-			hirc.Sound = parseSoundInPlace(r, size, i, hirc.Hierarchy, hirc.Sound)
-		case HircTypeAction:
-			parseActionInPlace(r, size, i, hirc.Hierarchy)
-		case HircTypeEvent:
-			parseEventInPlace(r, size, i, hirc.Hierarchy)
-		case HircTypeRanSeqCntr:
-			parseRanSeqCntrInPlace(r, size, i, hirc.Hierarchy)
-		case HircTypeSwitchCntr:
-			parseSwitchCntrInPlace(r, size, i, hirc.Hierarchy)
-		case HircTypeActorMixer:
-			parseActorMixerInPlace(r, size, i, hirc.Hierarchy)
-		case HircTypeBus:
-			parseBusInPlace(r, size, i, hirc.Hierarchy)
-		case HircTypeLayerCntr:
-			parseLayerCntrInPlace(r, size, i, hirc.Hierarchy)
-		case HircTypeMusicSegment:
-			parseMusicSegmentInPlace(r, size, i, hirc.Hierarchy)
-		case HircTypeMusicTrack:
-			hirc.Sound = parseMusicTrackInPlace(r, size, i, hirc.Hierarchy, hirc.Sound)
-		case HircTypeMusicSwitchCntr:
-			parseMusicSwitchCntrInPlace(r, size, i, hirc.Hierarchy)
-		case HircTypeMusicRanSeqCntr:
-			parseMusicRanSeqCntrInPlace(r, size, i, hirc.Hierarchy)
-		case HircTypeAttenuation:
-			parseAttenuationInPlace(r, size, i, hirc.Hierarchy)
-		case HircTypeDialogueEvent:
-			parseDialogueEventInPlace(r, size, i, hirc.Hierarchy)
-		case HircTypeFxShareSet:
-			parseFxShareSetInPlace(r, size, i, hirc.Hierarchy)
-		case HircTypeFxCustom:
-			parseFxShareCustomInPlace(r, size, i, hirc.Hierarchy)
-		case HircTypeAuxBus:
-			parseAuxBusInPlace(r, size, i, hirc.Hierarchy)
-		case HircTypeLFOModulator:
-			parseLFOModulatorInPlace(r, size, i, hirc.Hierarchy)
-		case HircEnvelopeModulator:
-			parseEnvelopeModulatorInPlace(r, size, i, hirc.Hierarchy)
-		case HircAudioDevice:
-			parseAudioDeviceInPlace(r, size, i, hirc.Hierarchy)
-		case HircTimeModulator:
-			parseTimeModulatorInPlace(r, size, i, hirc.Hierarchy)
-		}
-	}
-	return &hirc
-}
-
-func parseStateInPlace(r *wio.InPlaceReader, size uint32, i uint32, hirc []Hierarchy) {
-	begin := r.Tell()
-	end := begin + uint(size)
-	hirc[i].ID = r.U32Unsafe()
-	r.AbsSeekUnsafe(end)
-}
-
-func parseSoundInPlace(
-	r *wio.InPlaceReader,
-	size uint32,
-	i uint32,
-	hirc []Hierarchy,
-	sounds []Sound,
-) []Sound {
-	begin := r.Tell()
-	end := begin + uint(size)
-
-	hirc[i].ID = r.U32Unsafe()
-
-	sound := Sound{Idx: i}
-
-	parseBankSourceDataInPlace(r, &sound)
-
-	sounds = append(sounds, sound)
-
-	hirc[i].Parent = parseBaseParamInPlace(r)
-
-	r.AbsSeekUnsafe(end)
-
-	return sounds
-}
-
-func parseBankSourceDataInPlace(r *wio.InPlaceReader, sound *Sound) {
-	sound.PluginID = r.U32Unsafe()
-	sound.StreamType = r.U8Unsafe()
-	sound.SourceID = r.U32Unsafe()
-	sound.InMemoryMediaSize = r.U32Unsafe()
-	sound.SourceBits = r.U8Unsafe()
-	sound.PluginParamSize = 0
-
-	hasParam := (sound.PluginID & 0x0F) == 2 && 
-	            (sound.PluginID != 0)
-	if hasParam {
-		sound.PluginParamSize = r.U32Unsafe()
-		if sound.PluginParamSize > 0 {
-			r.RelSeekUnsafe(int(sound.PluginParamSize))
-		}
-	}
-}
-
-func parseBaseParamInPlace(r *wio.InPlaceReader) uint32 {
-	r.RelSeekUnsafe(1) // BitIsOverrideParentFx
-
-	// FxChunk
-	uniqueNumFX := r.U8Unsafe()
-	if uniqueNumFX > 0 {
-		r.RelSeekUnsafe(1)
-		r.RelSeekUnsafe(int(uniqueNumFX) * 7)
-	}
-
-	// FxChunkMetadata
-	r.RelSeekUnsafe(1)
-	uniqueNumFXMetadata := r.U8Unsafe()
-	r.RelSeekUnsafe(int(uniqueNumFXMetadata) * 6)
-
-	r.RelSeekUnsafe(1 + 4) // BitOverrideAttachmentParams + OverrideBusId
-
-	return r.U32Unsafe()
-}
-
-func parseActionInPlace(r *wio.InPlaceReader, size uint32, i uint32, hirc []Hierarchy) {
-	begin := r.Tell()
-	end := begin + uint(size)
-	hirc[i].ID = r.U32Unsafe()
-	r.RelSeekUnsafe(2) // ulActionType
-	hirc[i].Parent = r.U32Unsafe() // idExt
-	r.AbsSeekUnsafe(end)
-}
-
-func parseEventInPlace(r *wio.InPlaceReader, size uint32, i uint32, hirc []Hierarchy) {
-	begin := r.Tell()
-	end := begin + uint(size)
-	hirc[i].ID = r.U32Unsafe()
-	r.AbsSeekUnsafe(end)
-}
-
-func parseRanSeqCntrInPlace(r *wio.InPlaceReader, size uint32, i uint32, hirc []Hierarchy) {
-	begin := r.Tell()
-	end := begin + uint(size)
-	hirc[i].ID = r.U32Unsafe()
-	hirc[i].Parent = parseBaseParamInPlace(r)
-	r.AbsSeekUnsafe(end)
-}
-
-func parseSwitchCntrInPlace(r *wio.InPlaceReader, size uint32, i uint32, hirc []Hierarchy) {
-	begin := r.Tell()
-	end := begin + uint(size)
-	hirc[i].ID = r.U32Unsafe()
-	hirc[i].Parent = parseBaseParamInPlace(r)
-	r.AbsSeekUnsafe(end)
-}
-
-func parseActorMixerInPlace(r *wio.InPlaceReader, size uint32, i uint32, hirc []Hierarchy) {
-	begin := r.Tell()
-	end := begin + uint(size)
-	hirc[i].ID = r.U32Unsafe()
-	hirc[i].Parent = parseBaseParamInPlace(r)
-	r.AbsSeekUnsafe(end)
-}
-
-func parseBusInPlace(r *wio.InPlaceReader, size uint32, i uint32, hirc []Hierarchy) {
-	begin := r.Tell()
-	end := begin + uint(size)
-	hirc[i].ID = r.U32Unsafe()
-	r.AbsSeekUnsafe(end)
-}
-
-func parseLayerCntrInPlace(r *wio.InPlaceReader, size uint32, i uint32, hirc []Hierarchy) {
-	begin := r.Tell()
-	end := begin + uint(size)
-	hirc[i].ID = r.U32Unsafe()
-	hirc[i].Parent = parseBaseParamInPlace(r)
-	r.AbsSeekUnsafe(end)
-}
-
-func parseMusicSegmentInPlace(r *wio.InPlaceReader, size uint32, i uint32, hirc []Hierarchy) {
-	begin := r.Tell()
-	end := begin + uint(size)
-	hirc[i].ID = r.U32Unsafe()
-	r.RelSeekUnsafe(1) // uFlags
-	hirc[i].Parent = parseBaseParamInPlace(r)
-	r.AbsSeekUnsafe(end)
-}
-
-func parseMusicTrackInPlace(
-	r *wio.InPlaceReader,
-	size uint32,
-	i uint32,
-	hirc []Hierarchy,
-	sounds []Sound,
-) []Sound {
-	begin := r.Tell()
-	end := begin + uint(size)
-	hirc[i].ID = r.U32Unsafe()
-
-	r.RelSeekUnsafe(1) // uFlags
-
-	numSources := r.U32Unsafe()
-	for range numSources {
-		sound := Sound{Idx: i}
-		parseBankSourceDataInPlace(r, &sound)
-		sounds = append(sounds, sound)
-	}
-
-	numPlayListItem := r.U32Unsafe()
-	// trackID
-	// sourceID
-	// eventID
-	// fPlayAt
-	// fBeginTrimOffset
-	// fEndTrimOffset
-	// fSrcDuration
-	r.RelSeekUnsafe(int(numPlayListItem) * (3 * 4 + 4 * 8))
-	if numPlayListItem > 0 {
-		r.RelSeekUnsafe(4) // numSubTrack
-	}
-
-	numClipAutomationItem := r.U32Unsafe()
-	for range numClipAutomationItem {
-		// uClipIndex
-		// eAutoType
-		r.RelSeekUnsafe(2 * 4)
-		// uNumPoints
-		// From
-		// To
-		// Interp
-		r.RelSeekUnsafe(int(r.U32Unsafe()) * (3 * 4))
-	}
-
-	hirc[i].Parent = parseBaseParamInPlace(r)
-
-	r.AbsSeekUnsafe(end)
-
-	return sounds
-}
-
-func parseMusicSwitchCntrInPlace(
-	r *wio.InPlaceReader,
-	size uint32,
-	i uint32,
-	hirc []Hierarchy,
-) {
-	begin := r.Tell()
-	end := begin + uint(size)
-	hirc[i].ID = r.U32Unsafe()
-	r.RelSeekUnsafe(1) // uFlags
-	hirc[i].Parent = parseBaseParamInPlace(r)
-	r.AbsSeekUnsafe(end)
-}
-
-func parseMusicRanSeqCntrInPlace(
-	r *wio.InPlaceReader,
-	size uint32,
-	i uint32,
-	hirc []Hierarchy,
-) {
-	begin := r.Tell()
-	end := begin + uint(size)
-	hirc[i].ID = r.U32Unsafe()
-	r.RelSeekUnsafe(1) // uFlags
-	hirc[i].Parent = parseBaseParamInPlace(r)
-	r.AbsSeekUnsafe(end)
-}
-
-func parseAttenuationInPlace(
-	r *wio.InPlaceReader,
-	size uint32,
-	i uint32,
-	hirc []Hierarchy,
-) {
-	begin := r.Tell()
-	end := begin + uint(size)
-	hirc[i].ID = r.U32Unsafe()
-	r.AbsSeekUnsafe(end)
-}
-
-func parseDialogueEventInPlace(
-	r *wio.InPlaceReader,
-	size uint32,
-	i uint32,
-	hirc []Hierarchy,
-) {
-	begin := r.Tell()
-	end := begin + uint(size)
-	hirc[i].ID = r.U32Unsafe()
-	r.AbsSeekUnsafe(end)
-}
-
-func parseFxShareSetInPlace(
-	r *wio.InPlaceReader,
-	size uint32,
-	i uint32,
-	hirc []Hierarchy,
-) {
-	begin := r.Tell()
-	end := begin + uint(size)
-	hirc[i].ID = r.U32Unsafe()
-	r.AbsSeekUnsafe(end)
-}
-
-func parseFxShareCustomInPlace(
-	r *wio.InPlaceReader,
-	size uint32,
-	i uint32,
-	hirc []Hierarchy,
-) {
-	begin := r.Tell()
-	end := begin + uint(size)
-	hirc[i].ID = r.U32Unsafe()
-	r.AbsSeekUnsafe(end)
-}
-
-func parseAuxBusInPlace(
-	r *wio.InPlaceReader,
-	size uint32,
-	i uint32,
-	hirc []Hierarchy,
-) {
-	begin := r.Tell()
-	end := begin + uint(size)
-	hirc[i].ID = r.U32Unsafe()
-	r.AbsSeekUnsafe(end)
-}
-
-func parseLFOModulatorInPlace(
-	r *wio.InPlaceReader,
-	size uint32,
-	i uint32,
-	hirc []Hierarchy,
-) {
-	begin := r.Tell()
-	end := begin + uint(size)
-	hirc[i].ID = r.U32Unsafe()
-	r.AbsSeekUnsafe(end)
-}
-
-func parseEnvelopeModulatorInPlace(
-	r *wio.InPlaceReader,
-	size uint32,
-	i uint32,
-	hirc []Hierarchy,
-) {
-	begin := r.Tell()
-	end := begin + uint(size)
-	hirc[i].ID = r.U32Unsafe()
-	r.AbsSeekUnsafe(end)
-}
-
-func parseAudioDeviceInPlace(
-	r *wio.InPlaceReader,
-	size uint32,
-	i uint32,
-	hirc []Hierarchy,
-) {
-	begin := r.Tell()
-	end := begin + uint(size)
-	hirc[i].ID = r.U32Unsafe()
-	r.AbsSeekUnsafe(end)
-}
-
-func parseTimeModulatorInPlace(
-	r *wio.InPlaceReader,
+	r wio.Decoder,
 	size uint32,
 	i uint32,
 	hirc []Hierarchy,
 ) {
 	begin := r.Tell()
 	end := begin + uint(size)
+	name(r, "ulID")
 	hirc[i].ID = r.U32Unsafe()
+	consumed(r)
 	r.AbsSeekUnsafe(end)
 }
@@ -0,0 +1,302 @@
+package parser
+
+import (
+	"bytes"
+	wio "dekr0/hd2_audio_db/io"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// testBank builds a minimal v150 sound bank (BKHD + HIRC) without the 16 bytes 
+// header of the game. `n` is the number of sound / action pairs in HIRC. An 
+// event and a random / sequence container are appended at the end.
+func testBank(n int) []byte {
+	le := binary.LittleEndian
+
+	hirc := bytes.Buffer{}
+	obj := func(t HircType, body []byte) {
+		hirc.WriteByte(byte(t))
+		binary.Write(&hirc, le, uint32(len(body)))
+		hirc.Write(body)
+	}
+	baseParam := func(b *bytes.Buffer, parent uint32) {
+		b.Write([]byte{0, 0}) // BitIsOverrideParentFx, uniqueNumFX
+		b.Write([]byte{0, 0}) // FxChunkMetadata
+		b.Write([]byte{0, 0, 0, 0}) // BitOverrideAttachmentParams + OverrideBusId
+		binary.Write(b, le, parent)
+	}
+	for i := range n {
+		b := bytes.Buffer{}
+		binary.Write(&b, le, uint32(1000 + i)) // ID
+		binary.Write(&b, le, uint32(0x00040001)) // PluginID
+		b.WriteByte(uint8(i % 3)) // StreamType
+		binary.Write(&b, le, uint32(5000 + i)) // SourceID
+		binary.Write(&b, le, uint32(0)) // cache ID
+		binary.Write(&b, le, uint32(128 * i)) // InMemoryMediaSize
+		b.WriteByte(0) // SourceBits
+		baseParam(&b, 9000)
+		b.Write(make([]byte, 16)) // remaining of the sound that is not parsed
+		obj(HircTypeSound, b.Bytes())
+
+		b.Reset()
+		binary.Write(&b, le, uint32(2000 + i)) // ID
+		binary.Write(&b, le, uint16(0x0403)) // ulActionType
+		binary.Write(&b, le, uint32(1000 + i)) // idExt
+		b.Write(make([]byte, 8))
+		obj(HircTypeAction, b.Bytes())
+	}
+
+	b := bytes.Buffer{}
+	binary.Write(&b, le, uint32(3000))
+	b.Write(make([]byte, 4))
+	obj(HircTypeEvent, b.Bytes())
+
+	b.Reset()
+	binary.Write(&b, le, uint32(9000))
+	baseParam(&b, 0)
+	b.Write(make([]byte, 24))
+	obj(HircTypeRanSeqCntr, b.Bytes())
+
+	return wrapBank(hirc.Bytes(), uint32(n * 2 + 2))
+}
+
+// wrapBank puts `n` HIRC objects `hirc` into a v150 sound bank.
+func wrapBank(hirc []byte, n uint32) []byte {
+	le := binary.LittleEndian
+
+	bank := bytes.Buffer{}
+	bank.WriteString("BKHD")
+	binary.Write(&bank, le, uint32(12))
+	binary.Write(&bank, le, uint32(150))
+	binary.Write(&bank, le, uint32(0xdeadbeef))
+	binary.Write(&bank, le, uint32(0))
+	bank.WriteString("HIRC")
+	binary.Write(&bank, le, uint32(4 + len(hirc)))
+	binary.Write(&bank, le, n)
+	bank.Write(hirc)
+	return bank.Bytes()
+}
+
+func TestParseBankParity(t *testing.T) {
+	bank := testBank(8)
+
+	r := wio.NewReader(bytes.NewReader(bank), wio.ByteOrder)
+	a := ParseBank(r, uint64(len(bank)))
+	if a == nil {
+		t.Fatal("ParseBank does not find HIRC")
+	}
+	b := ParseBankInPlace(
+		wio.NewInPlaceReader(bank, wio.ByteOrder), uint64(len(bank)),
+	)
+	if b == nil {
+		t.Fatal("ParseBankInPlace does not find HIRC")
+	}
+	if !reflect.DeepEqual(a, b) {
+		t.Fatalf("ParseBank and ParseBankInPlace disagree:\n%v\n%v", a, b)
+	}
+
+	if a.Header != 18 || len(a.Hierarchy) != 18 || len(a.Sound) != 8 {
+		t.Fatalf(
+			"expect 18 objects with 8 sounds, got %d objects with %d sounds",
+			len(a.Hierarchy), len(a.Sound),
+		)
+	}
+	for i, s := range a.Sound {
+		h := a.Hierarchy[s.Idx]
+		if h.Type != HircTypeSound || h.ID != uint32(1000 + i) || h.Parent != 9000 {
+			t.Fatalf("unexpected sound hierarchy %v", h)
+		}
+		if s.SourceID != uint32(5000 + i) || s.StreamType != uint8(i % 3) {
+			t.Fatalf("unexpected sound %v", s)
+		}
+	}
+	if h := a.Hierarchy[1]; h.Type != HircTypeAction || h.Parent != 1000 {
+		t.Fatalf("unexpected action hierarchy %v", h)
+	}
+}
+
+func TestParseBankMusicTrack(t *testing.T) {
+	le := binary.LittleEndian
+
+	b := bytes.Buffer{}
+	binary.Write(&b, le, uint32(4000)) // ID
+	b.WriteByte(0) // uFlags
+	binary.Write(&b, le, uint32(2)) // numSources
+	for i := range 2 {
+		binary.Write(&b, le, uint32(0x00040001)) // PluginID
+		b.WriteByte(2) // StreamType (stream)
+		binary.Write(&b, le, uint32(6000 + i)) // SourceID
+		binary.Write(&b, le, uint32(0)) // cache ID
+		binary.Write(&b, le, uint32(0)) // InMemoryMediaSize
+		b.WriteByte(0) // SourceBits
+	}
+	binary.Write(&b, le, uint32(1)) // numPlayListItem
+	b.Write(make([]byte, 3 * 4 + 4 * 8))
+	binary.Write(&b, le, uint32(1)) // numSubTrack
+	binary.Write(&b, le, uint32(1)) // numClipAutomationItem
+	b.Write(make([]byte, 2 * 4))
+	binary.Write(&b, le, uint32(2)) // uNumPoints
+	b.Write(make([]byte, 2 * 3 * 4))
+	b.Write([]byte{0, 0, 0, 0, 0, 0, 0, 0}) // FX, metadata, attachment, bus
+	binary.Write(&b, le, uint32(7000)) // DirectParentID
+	b.Write(make([]byte, 8)) // remaining of the track that is not parsed
+
+	hirc := bytes.Buffer{}
+	hirc.WriteByte(byte(HircTypeMusicTrack))
+	binary.Write(&hirc, le, uint32(b.Len()))
+	hirc.Write(b.Bytes())
+	bank := wrapBank(hirc.Bytes(), 1)
+
+	r := wio.NewReader(bytes.NewReader(bank), wio.ByteOrder)
+	a := ParseBank(r, uint64(len(bank)))
+	if a == nil {
+		t.Fatal("ParseBank does not find HIRC")
+	}
+	if h := a.Hierarchy[0]; h.ID != 4000 || h.Parent != 7000 {
+		t.Fatalf("unexpected music track hierarchy %v", h)
+	}
+	if len(a.Sound) != 2 {
+		t.Fatalf("expect 2 music track sources, got %d", len(a.Sound))
+	}
+	for i, s := range a.Sound {
+		if s.Idx != 0 || s.SourceID != uint32(6000 + i) || s.StreamType != 2 {
+			t.Fatalf("unexpected music track source %v", s)
+		}
+	}
+}
+
+func TestParseBankNoHIRC(t *testing.T) {
+	bank := testBank(1)
+	bank = bank[:20] // BKHD only
+
+	r := wio.NewReader(bytes.NewReader(bank), wio.ByteOrder)
+	if ParseBank(r, uint64(len(bank))) != nil {
+		t.Fatal("expect nil HIRC")
+	}
+	ir := wio.NewInPlaceReader(bank, wio.ByteOrder)
+	if ParseBankInPlace(ir, uint64(len(bank))) != nil {
+		t.Fatal("expect nil HIRC")
+	}
+}
+
+func BenchmarkParseBank(b *testing.B) {
+	bank := testBank(4096)
+	b.ResetTimer()
+	for range b.N {
+		r := wio.NewReader(bytes.NewReader(bank), wio.ByteOrder)
+		ParseBank(r, uint64(len(bank)))
+	}
+}
+
+func BenchmarkParseBankInPlace(b *testing.B) {
+	bank := testBank(4096)
+	b.ResetTimer()
+	for range b.N {
+		ParseBankInPlace(
+			wio.NewInPlaceReader(bank, wio.ByteOrder), uint64(len(bank)),
+		)
+	}
+}
+
+func TestParseBankTrace(t *testing.T) {
+	bank := testBank(2)
+	r := wio.NewInPlaceReader(bank, wio.ByteOrder)
+	tr := wio.NewTrace()
+	r.SetTrace(tr)
+	hirc := ParseBankInPlace(r, uint64(len(bank)))
+	if hirc == nil {
+		t.Fatal("ParseBankInPlace does not find HIRC")
+	}
+	if len(tr.Spans) != len(hirc.Hierarchy) {
+		t.Fatalf("expect %d spans, got %d", len(hirc.Hierarchy), len(tr.Spans))
+	}
+	s := tr.Spans[0]
+	if s.Name != HircTypeName[HircTypeSound] {
+		t.Fatalf("unexpected span %v", s)
+	}
+	if fields := tr.SpanFields(s); fields[0].Name != "ulID" || 
+	   fields[0].Value != uint32(1000) {
+		t.Fatalf("unexpected fields %v", fields)
+	}
+	// The 16 bytes padding after the base parameters is never read. Bytes 
+	// before it are skipped on purpose, e.g., BitIsOverrideParentFx.
+	if s.Consumed != s.End - 16 {
+		t.Fatalf("expect consumed at %d, got %d", s.End - 16, s.Consumed)
+	}
+	gaps := tr.Gaps(s)
+	last := gaps[len(gaps) - 1]
+	if last.Size != 16 || last.Offset + 16 != s.End || last.Skipped {
+		t.Fatalf("unexpected gaps %v", gaps)
+	}
+	for _, g := range gaps[:len(gaps) - 1] {
+		if !g.Skipped {
+			t.Fatalf("expect skipped gap before consumed position, got %v", g)
+		}
+	}
+}
+
+func TestParseBKHD(t *testing.T) {
+	bank := testBank(1)
+	r := wio.NewInPlaceReader(bank, wio.ByteOrder)
+	bkhd, err := ParseBKHD(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bkhd.Version != 150 || bkhd.BankID != 0xdeadbeef || bkhd.LanguageID != 0 {
+		t.Fatalf("unexpected BKHD %v", bkhd)
+	}
+	if r.Tell() != 20 {
+		t.Fatalf("expect reader at the end of BKHD (20), got %d", r.Tell())
+	}
+
+	r = wio.NewInPlaceReader(bank[20:], wio.ByteOrder)
+	if _, err := ParseBKHD(r); err == nil {
+		t.Fatal("expect error when BKHD tag is missing")
+	}
+}
+
+func TestParseMediaIndex(t *testing.T) {
+	le := binary.LittleEndian
+	bank := bytes.Buffer{}
+	bank.Write(testBank(0)[:20]) // BKHD
+	bank.WriteString("DIDX")
+	binary.Write(&bank, le, uint32(24))
+	for _, m := range []Media{{5000, 0, 16}, {5001, 16, 8}} {
+		binary.Write(&bank, le, m)
+	}
+	bank.WriteString("DATA")
+	binary.Write(&bank, le, uint32(24))
+	dataOffset := bank.Len()
+	bank.Write(make([]byte, 24))
+	bank.Write(testBank(2)[20:]) // HIRC
+
+	data := bank.Bytes()
+	idx, err := ParseMediaIndex(wio.NewInPlaceReader(data, wio.ByteOrder), uint(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx.DataOffset != uint(dataOffset) || idx.DataSize != 24 || len(idx.Media) != 2 {
+		t.Fatalf("unexpected media index %v", idx)
+	}
+	if idx.Media[1] != (Media{5001, 16, 8}) {
+		t.Fatalf("unexpected media %v", idx.Media[1])
+	}
+	hirc := ParseBankInPlace(wio.NewInPlaceReader(data, wio.ByteOrder), 0)
+	if hirc == nil || len(hirc.Sound) != 2 {
+		t.Fatal("expect HIRC after DIDX and DATA to be parsed")
+	}
+
+	le.PutUint32(data[dataOffset - 12:], 9) // size of media 5001
+	if _, err := ParseMediaIndex(wio.NewInPlaceReader(data, wio.ByteOrder), uint(len(data))); err == nil {
+		t.Fatal("expect error on media beyond DATA")
+	}
+
+	idx, err = ParseMediaIndex(wio.NewInPlaceReader(testBank(1), wio.ByteOrder), uint(len(testBank(1))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(idx.Media) != 0 {
+		t.Fatalf("expect empty media index, got %v", idx)
+	}
+}
@@ -0,0 +1,71 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	wio "dekr0/hd2_audio_db/io"
+)
+
+// WwiseDependency is the payload of a wwise dependency asset. It shares the 
+// file ID of the sound bank it describes.
+//
+// Layout:
+//   - u32 Tag: meaning unknown. Kept as is;
+//   - u32 Size: length of the path in bytes, NUL terminator included if any;
+//   - Path: UTF-8 path of the sound bank, e.g. `content/audio/xxx`;
+//   - Extra: bytes after the path, if any. Not parsed. No list of referenced 
+//   media or banks is known to be stored in the payload.
+type WwiseDependency struct {
+	Tag   uint32
+	Size  uint32
+	Path  string
+	Extra []byte
+}
+
+// ParseWwiseDependency parses the payload of a wwise dependency. The path 
+// must be valid UTF-8. Trailing NULs of the path are removed.
+func ParseWwiseDependency(data []byte) (*WwiseDependency, error) {
+	r := wio.NewInPlaceReader(data, wio.ByteOrder)
+	tag, err := r.U32()
+	if err != nil {
+		return nil, fmt.Errorf("Wwise dependency is too small (%d bytes)", len(data))
+	}
+	size, err := r.U32()
+	if err != nil {
+		return nil, fmt.Errorf("Wwise dependency is too small (%d bytes)", len(data))
+	}
+	path, err := r.ReadNoCopy(uint(size))
+	if err != nil {
+		return nil, fmt.Errorf(
+			"Path length %d exceeds payload size %d", size, len(data) - 8,
+		)
+	}
+	path = bytes.TrimRight(path, "\x00")
+	if !utf8.Valid(path) {
+		return nil, fmt.Errorf("Path is not valid UTF-8")
+	}
+	return &WwiseDependency{
+		Tag: tag,
+		Size: size,
+		Path: string(path),
+		Extra: bytes.Clone(r.ExhaustNoCopy()),
+	}, nil
+}
+
+// Slug turns the path into a name that is safe to use as a file name. `/` 
+// and any character other than ASCII letters, digits, `.`, `-` and `_` 
+// become `_`.
+func (d *WwiseDependency) Slug() string {
+	return strings.Map(func(c rune) rune {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+			return c
+		case c == '.' || c == '-' || c == '_':
+			return c
+		}
+		return '_'
+	}, d.Path)
+}
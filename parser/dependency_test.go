@@ -0,0 +1,36 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestParseWwiseDependency(t *testing.T) {
+	data := []byte{0xAA, 0xBB, 0xCC, 0xDD, 12, 0, 0, 0}
+	data = append(data, []byte("a/b c\\d.bnk\x00")...)
+	d, err := ParseWwiseDependency(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Tag != 0xDDCCBBAA || d.Size != 12 || d.Path != "a/b c\\d.bnk" || len(d.Extra) != 0 {
+		t.Fatalf("unexpected dependency %v", d)
+	}
+	if slug := d.Slug(); slug != "a_b_c_d.bnk" {
+		t.Fatalf("unexpected slug %s", slug)
+	}
+
+	d, err = ParseWwiseDependency(append(data, 1, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Extra) != 2 {
+		t.Fatalf("expect 2 extra bytes, got %v", d.Extra)
+	}
+
+	data[4] = 13
+	if _, err := ParseWwiseDependency(data); err == nil {
+		t.Fatal("expect error on path length beyond payload")
+	}
+	if _, err := ParseWwiseDependency(data[:6]); err == nil {
+		t.Fatal("expect error on truncated payload")
+	}
+}
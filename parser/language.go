@@ -0,0 +1,101 @@
+package parser
+
+import "strings"
+
+// Language names used by Wwise. The language ID stored in BKHD is the short 
+// ID (32-bit FNV-1 of the lowercase name) of one of these names. SFX banks, 
+// i.e., banks that are not localized, use the ID of "SFX".
+var LanguageNames []string = []string{
+	"SFX",
+	"Arabic",
+	"Bulgarian",
+	"Chinese(HK)",
+	"Chinese(PRC)",
+	"Chinese(Taiwan)",
+	"Czech",
+	"Danish",
+	"Dutch",
+	"English(Australia)",
+	"English(India)",
+	"English(UK)",
+	"English(US)",
+	"Finnish",
+	"French(Canada)",
+	"French(France)",
+	"German",
+	"Greek",
+	"Hebrew",
+	"Hungarian",
+	"Indonesian",
+	"Italian",
+	"Japanese",
+	"Korean",
+	"Latin",
+	"Norwegian",
+	"Polish",
+	"Portuguese(Brazil)",
+	"Portuguese(Portugal)",
+	"Romanian",
+	"Russian",
+	"Slovenian",
+	"Spanish(Mexico)",
+	"Spanish(Spain)",
+	"Spanish(US)",
+	"Swedish",
+	"Thai",
+	"Turkish",
+	"Ukrainian",
+	"Vietnamese",
+}
+
+var languageByID map[uint32]string = func() map[uint32]string {
+	m := make(map[uint32]string, len(LanguageNames))
+	for _, name := range LanguageNames {
+		m[ShortID(name)] = name
+	}
+	return m
+}()
+
+// ShortID computes the Wwise short ID of a name, i.e., 32-bit FNV-1 of the 
+// lowercase name.
+func ShortID(name string) uint32 {
+	var h uint32 = 2166136261
+	for _, c := range []byte(strings.ToLower(name)) {
+		h *= 16777619
+		h ^= uint32(c)
+	}
+	return h
+}
+
+// LanguageName returns the Wwise language name of a language ID. The second 
+// return value is false if the ID is not a known language.
+func LanguageName(id uint32) (string, bool) {
+	name, in := languageByID[id]
+	return name, in
+}
+
+// IsLanguageSegment reports whether a segment of a path names a language, 
+// e.g., "english(us)", "english_us" or "englishus". SFX is not a language 
+// segment.
+func IsLanguageSegment(s string) bool {
+	s = normalizeLanguage(s)
+	if s == "" {
+		return false
+	}
+	for _, name := range LanguageNames[1:] {
+		if normalizeLanguage(name) == s {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeLanguage(s string) string {
+	b := strings.Builder{}
+	for _, c := range []byte(strings.ToLower(s)) {
+		if c >= 'a' && c <= 'z' {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,31 @@
+package parser
+
+import "testing"
+
+func TestShortID(t *testing.T) {
+	if id := ShortID("SFX"); id != 393239870 {
+		t.Fatalf("SFX: expect 393239870, got %d", id)
+	}
+	if id := ShortID("English(US)"); id != 684519430 {
+		t.Fatalf("English(US): expect 684519430, got %d", id)
+	}
+	if name, ok := LanguageName(684519430); !ok || name != "English(US)" {
+		t.Fatalf("expect English(US), got %s", name)
+	}
+	if _, ok := LanguageName(0); ok {
+		t.Fatal("expect unknown language")
+	}
+}
+
+func TestIsLanguageSegment(t *testing.T) {
+	for _, s := range []string{"english(us)", "English_US", "frenchfrance", "German"} {
+		if !IsLanguageSegment(s) {
+			t.Fatalf("expect %s to be a language segment", s)
+		}
+	}
+	for _, s := range []string{"sfx", "content", "vo", ""} {
+		if IsLanguageSegment(s) {
+			t.Fatalf("expect %s not to be a language segment", s)
+		}
+	}
+}
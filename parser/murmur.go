@@ -0,0 +1,32 @@
+package parser
+
+import "encoding/binary"
+
+// Murmur64A is MurmurHash64A with seed 0. The game uses it to turn resource 
+// names into file IDs and type IDs, e.g., `wwise_bank` becomes 
+// AssetTypeSoundBank.
+func Murmur64A(key []byte) uint64 {
+	const m uint64 = 0xc6a4a7935bd1e995
+	const r = 47
+
+	h := uint64(len(key)) * m
+	for len(key) >= 8 {
+		k := binary.LittleEndian.Uint64(key)
+		k *= m
+		k ^= k >> r
+		k *= m
+		h ^= k
+		h *= m
+		key = key[8:]
+	}
+	if len(key) > 0 {
+		for i := len(key) - 1; i >= 0; i-- {
+			h ^= uint64(key[i]) << (8 * i)
+		}
+		h *= m
+	}
+	h ^= h >> r
+	h *= m
+	h ^= h >> r
+	return h
+}
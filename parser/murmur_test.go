@@ -0,0 +1,19 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestMurmur64A(t *testing.T) {
+	expects := map[string]uint64{
+		"wwise_bank": uint64(AssetTypeSoundBank),
+		"wwise_dep": AssetTypeWwiseDependency,
+		"wwise_stream": AssetTypeWwiseStream,
+		"strings": AssetTypeStrings,
+	}
+	for name, expect := range expects {
+		if h := Murmur64A([]byte(name)); h != expect {
+			t.Fatalf("%s: expect %#x, got %#x", name, expect, h)
+		}
+	}
+}
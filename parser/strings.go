@@ -0,0 +1,127 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+
+	wio "dekr0/hd2_audio_db/io"
+)
+
+// Strings is the payload of a string table asset. One asset holds the text of
+// one language.
+//
+// Layout:
+//   - u32 Magic: meaning unknown. Kept as is;
+//   - u32 Version: meaning unknown. Kept as is;
+//   - u32 count: number of strings;
+//   - u32 Language: Stingray language ID of the table (see StringLanguage);
+//   - u32[count] IDs of the strings;
+//   - u32[count] offsets of the strings, relative to the start of the asset;
+//   - NUL terminated UTF-8 strings.
+type Strings struct {
+	Magic    uint32
+	Version  uint32
+	Language uint32
+	Entries  []StringEntry
+}
+
+type StringEntry struct {
+	ID   uint32
+	Text string
+}
+
+const sizeOfStringsHeader = 16
+
+// Stingray language codes. The language ID of a string table is not a Wwise 
+// short ID. It is the upper 32 bits of Murmur64A of one of these codes, e.g., 
+// `us` is 0x03F97B57. The 16 language IDs used by the game decode to these 
+// codes.
+var StringLanguageCodes []string = []string{
+	"bp", // Portuguese (Brazil)
+	"de",
+	"es",
+	"fr",
+	"gb", // English (UK)
+	"it",
+	"jp",
+	"ko",
+	"ms", // Spanish (Mexico)
+	"nl",
+	"pl",
+	"pt",
+	"ru",
+	"sc", // Chinese (simplified)
+	"tc", // Chinese (traditional)
+	"us", // English (US)
+}
+
+var stringLanguageByID map[uint32]string = func() map[uint32]string {
+	m := make(map[uint32]string, len(StringLanguageCodes))
+	for _, code := range StringLanguageCodes {
+		m[StringLanguageID(code)] = code
+	}
+	return m
+}()
+
+// StringLanguageID computes the language ID of a string table from a 
+// Stingray language code.
+func StringLanguageID(code string) uint32 {
+	return uint32(Murmur64A([]byte(code)) >> 32)
+}
+
+// StringLanguage returns the Stingray language code of a string table 
+// language ID. The second return value is false if the ID is not a known 
+// language.
+func StringLanguage(id uint32) (string, bool) {
+	code, in := stringLanguageByID[id]
+	return code, in
+}
+
+// ParseStrings parses the payload of a string table asset. A string that is
+// not NUL terminated or not valid UTF-8 is an error.
+func ParseStrings(data []byte) (*Strings, error) {
+	r := wio.NewInPlaceReader(data, wio.ByteOrder)
+	if r.Len() < sizeOfStringsHeader {
+		return nil, fmt.Errorf("String table is too small (%d bytes)", len(data))
+	}
+	s := &Strings{}
+	s.Magic = r.U32Unsafe()
+	s.Version = r.U32Unsafe()
+	count := r.U32Unsafe()
+	s.Language = r.U32Unsafe()
+	if uint64(count) * 8 > uint64(r.Len()) {
+		return nil, fmt.Errorf(
+			"String count %d exceeds payload size %d", count, len(data),
+		)
+	}
+
+	s.Entries = make([]StringEntry, count)
+	for i := range s.Entries {
+		s.Entries[i].ID = r.U32Unsafe()
+	}
+	for i := range s.Entries {
+		offset := r.U32Unsafe()
+		if uint(offset) >= r.Cap() {
+			return nil, fmt.Errorf(
+				"Offset %d of string %d exceeds payload size %d",
+				offset, s.Entries[i].ID, len(data),
+			)
+		}
+		text := data[offset:]
+		end := bytes.IndexByte(text, 0)
+		if end < 0 {
+			return nil, fmt.Errorf(
+				"String %d at %d is not NUL terminated", s.Entries[i].ID, offset,
+			)
+		}
+		text = text[:end]
+		if !utf8.Valid(text) {
+			return nil, fmt.Errorf(
+				"String %d at %d is not valid UTF-8", s.Entries[i].ID, offset,
+			)
+		}
+		s.Entries[i].Text = string(text)
+	}
+	return s, nil
+}
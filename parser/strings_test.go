@@ -0,0 +1,73 @@
+package parser
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func testStrings(texts ...string) []byte {
+	data := binary.LittleEndian.AppendUint32(nil, 0xF0000000)
+	data = binary.LittleEndian.AppendUint32(data, 0x1000)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(texts)))
+	data = binary.LittleEndian.AppendUint32(data, 0x12345678)
+	for i := range texts {
+		data = binary.LittleEndian.AppendUint32(data, uint32(100 + i))
+	}
+	offset := uint32(len(data) + 4 * len(texts))
+	for _, text := range texts {
+		data = binary.LittleEndian.AppendUint32(data, offset)
+		offset += uint32(len(text) + 1)
+	}
+	for _, text := range texts {
+		data = append(data, text...)
+		data = append(data, 0)
+	}
+	return data
+}
+
+func TestParseStrings(t *testing.T) {
+	data := testStrings("Reinforcing!", "", "Démocratie")
+	s, err := ParseStrings(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Magic != 0xF0000000 || s.Version != 0x1000 || s.Language != 0x12345678 {
+		t.Fatalf("unexpected header %v", s)
+	}
+	expects := []StringEntry{{100, "Reinforcing!"}, {101, ""}, {102, "Démocratie"}}
+	if len(s.Entries) != len(expects) {
+		t.Fatalf("expect %d strings, got %d", len(expects), len(s.Entries))
+	}
+	for i, e := range expects {
+		if s.Entries[i] != e {
+			t.Fatalf("expect %v, got %v", e, s.Entries[i])
+		}
+	}
+
+	if _, err := ParseStrings(data[:len(data) - 1]); err == nil {
+		t.Fatal("expect error on string without NUL terminator")
+	}
+	if _, err := ParseStrings(data[:12]); err == nil {
+		t.Fatal("expect error on truncated header")
+	}
+	binary.LittleEndian.PutUint32(data[8:], 1 << 30)
+	if _, err := ParseStrings(data); err == nil {
+		t.Fatal("expect error on count beyond payload")
+	}
+}
+
+func TestStringLanguage(t *testing.T) {
+	if id := StringLanguageID("us"); id != 0x03F97B57 {
+		t.Fatalf("us: expect 0x03F97B57, got %#08x", id)
+	}
+	if id := StringLanguageID("fr"); id != 4271961631 {
+		t.Fatalf("fr: expect 4271961631, got %d", id)
+	}
+	if code, ok := StringLanguage(3124347884); !ok || code != "de" {
+		t.Fatalf("expect de, got %s", code)
+	}
+	// Wwise short ID of English(US) is not a string table language
+	if _, ok := StringLanguage(ShortID("English(US)")); ok {
+		t.Fatal("expect unknown language")
+	}
+}
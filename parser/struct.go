@@ -16,10 +16,15 @@ type Archive struct {
 	depMu         sync.Mutex
 }
 
-const sizeOfAssetCnt = 16
+const sizeOfAssetCnt = 32
+// Semantics of the unknown fields are not verified. See `-analyze_toc` and 
+// "Archive ToC Fields" in documentation.md.
 type AssetTypeCnt struct {
-	Type uint64 `json:"Type"`
-	Num  uint64 `json:"Num"`
+	Unknown     uint64 `json:"Unknown"`
+	Type        uint64 `json:"Type"`
+	Num         uint64 `json:"Num"`
+	UnknownU32A uint32 `json:"UnknownU32A"`
+	UnknownU32B uint32 `json:"UnknownU32B"`
 }
 
 const sizeOfAssetHeader = 80
@@ -42,6 +47,27 @@ type AssetHeader struct {
 	UUID          string
 }
 
+type BKHD struct {
+	Version    uint32
+	BankID     uint32
+	LanguageID uint32
+}
+
+// MediaIndex is the content of DIDX. DataOffset is the position of the 
+// payload of DATA relative to the start of the sound bank (without the 16 
+// bytes header of the game). Offset of each media is relative to DataOffset.
+type MediaIndex struct {
+	Media      []Media
+	DataOffset uint
+	DataSize   uint32
+}
+
+type Media struct {
+	SourceID uint32
+	Offset   uint32
+	Size     uint32
+}
+
 type HIRC struct {
 	Header    uint32
 	Hierarchy []Hierarchy
@@ -12,3 +12,57 @@ DELETE FROM hierarchy;
 
 -- name: DeleteAllSound :exec
 DELETE FROM sound;
+
+-- name: DeleteAllArchiveToc :exec
+DELETE FROM archive_toc;
+
+-- name: DeleteAllAssetType :exec
+DELETE FROM asset_type;
+
+-- name: DeleteAllString :exec
+DELETE FROM string;
+
+-- name: DeleteAllSourceLocation :exec
+DELETE FROM source_location;
+
+-- name: DeleteAllSourceAudio :exec
+DELETE FROM source_audio;
+
+-- name: DeleteAllSourceFingerprint :exec
+DELETE FROM source_fingerprint;
+
+-- name: DeleteAllSourceDuplicate :exec
+DELETE FROM source_duplicate;
+
+-- name: DeleteAllSourceLoudness :exec
+DELETE FROM source_loudness;
+
+-- name: DeleteAllSourceWaveform :exec
+DELETE FROM source_waveform;
+
+-- name: DeleteAllTag :exec
+DELETE FROM tag;
+
+-- name: DeleteAllCategory :exec
+DELETE FROM category;
+
+-- name: DeleteArchiveTag :exec
+DELETE FROM archive_tag WHERE aid = ?;
+
+-- name: DeleteArchiveCategory :exec
+DELETE FROM archive_category WHERE aid = ?;
+
+-- name: DeleteAllSoundbankTag :exec
+DELETE FROM soundbank_tag;
+
+-- name: DeleteAllSoundbankCategory :exec
+DELETE FROM soundbank_category;
+
+-- name: DeleteAllHierarchyTag :exec
+DELETE FROM hierarchy_tag;
+
+-- name: DeleteAllSourceTag :exec
+DELETE FROM source_tag;
+
+-- name: DeleteAllLabelEntry :exec
+DELETE FROM label_entry;
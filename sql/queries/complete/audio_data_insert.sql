@@ -3,7 +3,10 @@ INSERT INTO archive (
     aid, tags, categories, date_modified
 ) VALUES (
     ?, ?, ?, ?
-);
+) ON CONFLICT (aid) DO UPDATE SET
+    tags = excluded.tags,
+    categories = excluded.categories,
+    date_modified = excluded.date_modified;
 
 -- name: InsertAsset :exec
 INSERT INTO asset (
@@ -11,11 +14,15 @@ INSERT INTO asset (
     data_offset, stream_file_offset, gpu_rsrc_offset,
     unknown_01, unknown_02,
     data_size, stream_size, gpu_rsrc_size,
-    unknown_03, unknown_04
-) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+    unknown_03, unknown_04,
+    data_hash, stream_hash, gpu_rsrc_hash, unchanged, hash_failed
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
 
 -- name: InsertSoundbank :exec
-INSERT INTO soundbank (aid, fid, path, name, categories) VALUES (?, ?, ?, ?, ?);
+INSERT INTO soundbank (
+    aid, fid, path, name, categories,
+    bank_id, language, group_path, raw_path
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);
 
 -- name: InsertHierarchy :exec
 INSERT INTO hierarchy (
@@ -26,3 +33,82 @@ INSERT INTO hierarchy (
 
 -- name: InsertSound :exec
 INSERT INTO sound (aid, fid, hid, sid) VALUES (?, ?, ?, ?);
+
+-- name: InsertArchiveToc :exec
+INSERT INTO archive_toc (
+    aid, num_types, num_files, unknown, unk4_data
+) VALUES (?, ?, ?, ?, ?);
+
+-- name: InsertAssetType :exec
+INSERT INTO asset_type (
+    aid, tid, num, unknown_01, unknown_02, unknown_03
+) VALUES (?, ?, ?, ?, ?, ?);
+
+-- name: InsertString :exec
+INSERT INTO string (aid, fid, language, sid, text) VALUES (?, ?, ?, ?, ?);
+
+-- name: InsertSourceLocation :exec
+INSERT OR IGNORE INTO source_location (
+    sid, aid, fid, tid, part, file_offset, size, prefetch
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?);
+
+-- name: InsertSourceAudio :exec
+INSERT INTO source_audio (
+    sid, codec, format_tag, channels, sample_rate, sample_count, duration,
+    has_loop, loop_start, loop_end, cue_count
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+
+-- name: InsertSourceFingerprint :exec
+INSERT INTO source_fingerprint (
+    sid, kind, content_hash, fingerprint, version
+) VALUES (?, ?, ?, ?, ?);
+
+-- name: InsertSourceDuplicate :exec
+INSERT INTO source_duplicate (gid, sid, method, confidence) VALUES (?, ?, ?, ?);
+
+-- name: InsertSourceLoudness :exec
+INSERT INTO source_loudness (
+    sid, integrated, true_peak, sample_peak, rms, duration, version
+) VALUES (?, ?, ?, ?, ?, ?, ?);
+
+-- name: InsertSourceWaveform :exec
+INSERT INTO source_waveform (
+    sid, channels, sample_rate, samples_per_bucket, peaks, version
+) VALUES (?, ?, ?, ?, ?, ?);
+
+-- name: InsertTag :one
+INSERT INTO tag (name) VALUES (?)
+ON CONFLICT (name) DO UPDATE SET name = excluded.name
+RETURNING tag_id;
+
+-- name: InsertCategory :one
+INSERT INTO category (name) VALUES (?)
+ON CONFLICT (name) DO UPDATE SET name = excluded.name
+RETURNING category_id;
+
+-- name: InsertArchiveTag :exec
+INSERT OR IGNORE INTO archive_tag (aid, tag_id) VALUES (?, ?);
+
+-- name: InsertArchiveCategory :exec
+INSERT OR IGNORE INTO archive_category (aid, category_id) VALUES (?, ?);
+
+-- name: InsertSoundbankTag :exec
+INSERT OR IGNORE INTO soundbank_tag (aid, fid, tag_id) VALUES (?, ?, ?);
+
+-- name: InsertSoundbankCategory :exec
+INSERT OR IGNORE INTO soundbank_category (aid, fid, category_id) VALUES (?, ?, ?);
+
+-- name: InsertHierarchyTag :exec
+INSERT OR IGNORE INTO hierarchy_tag (aid, fid, hid, tag_id) VALUES (?, ?, ?, ?);
+
+-- name: InsertSourceTag :exec
+INSERT OR IGNORE INTO source_tag (sid, tag_id) VALUES (?, ?);
+
+-- name: InsertLabelEntry :exec
+INSERT OR IGNORE INTO label_entry (
+    file, target, id, name, description, tags
+) VALUES (?, ?, ?, ?, ?, ?);
+
+-- name: InsertHierarchyTagByHid :exec
+INSERT OR IGNORE INTO hierarchy_tag (aid, fid, hid, tag_id)
+SELECT aid, fid, hid, ? FROM hierarchy WHERE hid = ?;
@@ -9,3 +9,85 @@ SELECT hid FROM hierarchy GROUP BY hid HAVING COUNT(*) = 1;
 
 -- name: SourceIdUnique :many
 SELECT sid FROM sound GROUP BY sid HAVING COUNT(*) = 1;
+
+-- name: GetAsset :one
+SELECT * FROM asset WHERE aid = ? AND fid = ? AND tid = ?;
+
+-- name: GetAllAssetHash :many
+SELECT aid, fid, tid, data_hash, stream_hash, gpu_rsrc_hash, hash_failed FROM asset;
+
+-- name: GetSoundbankGroup :many
+SELECT * FROM soundbank WHERE group_path = ? ORDER BY language;
+
+-- name: GetSoundbank :one
+SELECT * FROM soundbank WHERE aid = ? AND fid = ?;
+
+-- name: GetSourceLocation :many
+SELECT * FROM source_location WHERE sid = ? ORDER BY part DESC, aid, fid;
+
+-- name: GetAllSourceFingerprint :many
+SELECT * FROM source_fingerprint ORDER BY sid;
+
+-- name: GetAllSourceDuplicate :many
+SELECT * FROM source_duplicate ORDER BY gid, sid;
+
+-- name: GetSourceSoundbankPath :many
+SELECT DISTINCT soundbank.path FROM sound
+JOIN soundbank ON soundbank.aid = sound.aid AND soundbank.fid = sound.fid
+WHERE sound.sid = ? ORDER BY soundbank.path;
+
+-- name: GetSourceLoudness :one
+SELECT * FROM source_loudness WHERE sid = ?;
+
+-- name: GetAllSourceLoudness :many
+SELECT * FROM source_loudness ORDER BY sid;
+
+-- name: GetAllSourceWaveform :many
+SELECT * FROM source_waveform ORDER BY sid;
+
+-- name: GetSourceWaveform :one
+SELECT * FROM source_waveform WHERE sid = ?;
+
+-- name: GetAllTag :many
+SELECT * FROM tag ORDER BY name;
+
+-- name: GetAllCategory :many
+SELECT * FROM category ORDER BY name;
+
+-- name: SearchArchive :many
+SELECT aid FROM archive_label
+GROUP BY aid
+HAVING COUNT(DISTINCT CASE WHEN kind = 'tag' AND name IN (sqlc.slice('tags')) THEN name END) = sqlc.arg('tag_count')
+   AND COUNT(DISTINCT CASE WHEN kind = 'category' AND name IN (sqlc.slice('categories')) THEN name END) = sqlc.arg('category_count')
+ORDER BY aid;
+
+-- name: SearchSoundbank :many
+SELECT soundbank.aid, soundbank.fid, soundbank.path FROM soundbank
+JOIN soundbank_label ON soundbank_label.aid = soundbank.aid AND soundbank_label.fid = soundbank.fid
+GROUP BY soundbank.aid, soundbank.fid
+HAVING COUNT(DISTINCT CASE WHEN soundbank_label.kind = 'tag' AND soundbank_label.name IN (sqlc.slice('tags')) THEN soundbank_label.name END) = sqlc.arg('tag_count')
+   AND COUNT(DISTINCT CASE WHEN soundbank_label.kind = 'category' AND soundbank_label.name IN (sqlc.slice('categories')) THEN soundbank_label.name END) = sqlc.arg('category_count')
+ORDER BY soundbank.path;
+
+-- name: SearchHierarchy :many
+SELECT aid, fid, hid FROM hierarchy_label
+GROUP BY aid, fid, hid
+HAVING COUNT(DISTINCT CASE WHEN kind = 'tag' AND name IN (sqlc.slice('tags')) THEN name END) = sqlc.arg('tag_count')
+   AND COUNT(DISTINCT CASE WHEN kind = 'category' AND name IN (sqlc.slice('categories')) THEN name END) = sqlc.arg('category_count')
+ORDER BY aid, fid, hid;
+
+-- name: SearchSource :many
+SELECT sid FROM source_label
+GROUP BY sid
+HAVING COUNT(DISTINCT CASE WHEN kind = 'tag' AND name IN (sqlc.slice('tags')) THEN name END) = sqlc.arg('tag_count')
+   AND COUNT(DISTINCT CASE WHEN kind = 'category' AND name IN (sqlc.slice('categories')) THEN name END) = sqlc.arg('category_count')
+ORDER BY sid;
+
+-- name: GetAllSoundSid :many
+SELECT DISTINCT sid FROM sound;
+
+-- name: GetAllHierarchyHid :many
+SELECT DISTINCT hid FROM hierarchy;
+
+-- name: GetAllStreamSource :many
+SELECT DISTINCT fid, sid FROM source_location WHERE part = 'stream';
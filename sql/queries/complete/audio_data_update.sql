@@ -0,0 +1,5 @@
+-- name: ResetHierarchyLabel :exec
+UPDATE hierarchy SET label = '', tags = '', description = '';
+
+-- name: UpdateHierarchyLabel :exec
+UPDATE hierarchy SET label = ?, tags = ?, description = ? WHERE hid = ?;
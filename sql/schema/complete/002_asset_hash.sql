@@ -0,0 +1,15 @@
+-- +goose Up
+-- xxhash64 of data, stream and GPU resource part of an asset. Stored as the 
+-- bit pattern of a signed 64-bit integer. 0 means the part is empty or not 
+-- hashed.
+ALTER TABLE asset ADD COLUMN data_hash INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE asset ADD COLUMN stream_hash INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE asset ADD COLUMN gpu_rsrc_hash INTEGER NOT NULL DEFAULT 0;
+-- 1 if all three hashes match the same asset in the previous build
+ALTER TABLE asset ADD COLUMN unchanged INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE asset DROP COLUMN unchanged;
+ALTER TABLE asset DROP COLUMN gpu_rsrc_hash;
+ALTER TABLE asset DROP COLUMN stream_hash;
+ALTER TABLE asset DROP COLUMN data_hash;
@@ -0,0 +1,26 @@
+-- +goose Up
+-- Raw fields of the archive header and per type entries whose meaning is not 
+-- verified yet. See `-analyze_toc` for evidence gathered across all archives.
+CREATE TABLE archive_toc (
+    aid TEXT PRIMARY KEY,
+    num_types INTEGER NOT NULL,
+    num_files INTEGER NOT NULL,
+    unknown INTEGER NOT NULL,
+    unk4_data BLOB NOT NULL,
+    FOREIGN KEY (aid) REFERENCES archive(aid)
+);
+
+CREATE TABLE asset_type (
+    aid TEXT NOT NULL,
+    tid INTEGER NOT NULL,
+    num INTEGER NOT NULL,
+    unknown_01 INTEGER NOT NULL, -- u64 before type ID
+    unknown_02 INTEGER NOT NULL, -- 1st u32 after count
+    unknown_03 INTEGER NOT NULL, -- 2nd u32 after count
+    PRIMARY KEY (aid, tid),
+    FOREIGN KEY (aid) REFERENCES archive(aid)
+);
+
+-- +goose Down
+DROP TABLE asset_type;
+DROP TABLE archive_toc;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- Sound bank ID and language name from BKHD. `group_path` is the wwise 
+-- dependency path with the language segment removed so that the same bank in 
+-- different languages shares the same `group_path`.
+ALTER TABLE soundbank ADD COLUMN bank_id INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE soundbank ADD COLUMN language TEXT NOT NULL DEFAULT '';
+ALTER TABLE soundbank ADD COLUMN group_path TEXT NOT NULL DEFAULT '';
+CREATE INDEX soundbank_group_path ON soundbank(group_path);
+
+-- +goose Down
+DROP INDEX soundbank_group_path;
+ALTER TABLE soundbank DROP COLUMN group_path;
+ALTER TABLE soundbank DROP COLUMN language;
+ALTER TABLE soundbank DROP COLUMN bank_id;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- Path stored in the wwise dependency as is. `path` is the slug of it.
+ALTER TABLE soundbank ADD COLUMN raw_path TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE soundbank DROP COLUMN raw_path;
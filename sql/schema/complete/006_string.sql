@@ -0,0 +1,18 @@
+-- +goose Up
+-- Localized text from string table assets. `language` is the Stingray 
+-- language code (e.g. `us`, `gb`, `fr`) if the language ID of the table is 
+-- known, otherwise the ID in hex. It is not a Wwise language name. `sid` is 
+-- not known to match any Wwise ID (see "String Tables" in documentation.md).
+CREATE TABLE string (
+    aid TEXT NOT NULL,
+    fid INTEGER NOT NULL,
+    language TEXT NOT NULL,
+    sid INTEGER NOT NULL,
+    text TEXT NOT NULL,
+    FOREIGN KEY (aid) REFERENCES archive(aid)
+);
+CREATE INDEX string_sid ON string(sid);
+
+-- +goose Down
+DROP INDEX string_sid;
+DROP TABLE string;
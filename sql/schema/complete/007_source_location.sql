@@ -0,0 +1,20 @@
+-- +goose Up
+-- Physical locations of every source referenced by a sound bank. `part` is 
+-- `main` for a source embedded in a sound bank (offset into the archive) or 
+-- `stream` for a source in a WwiseStream asset (offset into the `.stream` 
+-- file). `prefetch` is set when a source is both embedded and streamed.
+CREATE TABLE source_location (
+    sid INTEGER NOT NULL,
+    aid TEXT NOT NULL,
+    fid INTEGER NOT NULL,
+    tid INTEGER NOT NULL,
+    part TEXT NOT NULL,
+    file_offset INTEGER NOT NULL,
+    size INTEGER NOT NULL,
+    prefetch INTEGER NOT NULL,
+    PRIMARY KEY (sid, aid, fid, part),
+    FOREIGN KEY (aid) REFERENCES archive(aid)
+);
+
+-- +goose Down
+DROP TABLE source_location;
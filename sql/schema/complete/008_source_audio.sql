@@ -0,0 +1,24 @@
+-- +goose Up
+-- Format of every source derived from its WEM header. `duration` is in 
+-- seconds. Loop points are in samples and only meaningful if `has_loop` is 
+-- set. E.g., mono 48k sources shorter than 200 ms:
+--   SELECT sid FROM source_audio 
+--   WHERE channels = 1 AND sample_rate = 48000 AND duration < 0.2;
+CREATE TABLE source_audio (
+    sid INTEGER PRIMARY KEY,
+    codec TEXT NOT NULL,
+    format_tag INTEGER NOT NULL,
+    channels INTEGER NOT NULL,
+    sample_rate INTEGER NOT NULL,
+    sample_count INTEGER NOT NULL,
+    duration REAL NOT NULL,
+    has_loop INTEGER NOT NULL,
+    loop_start INTEGER NOT NULL,
+    loop_end INTEGER NOT NULL,
+    cue_count INTEGER NOT NULL
+);
+CREATE INDEX source_audio_format ON source_audio(channels, sample_rate, duration);
+
+-- +goose Down
+DROP INDEX source_audio_format;
+DROP TABLE source_audio;
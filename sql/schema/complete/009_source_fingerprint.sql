@@ -0,0 +1,16 @@
+-- +goose Up
+-- Fingerprint of every source for tracking sources across game updates. 
+-- `kind` is `pcm` if the source was decoded, or `payload` if the fingerprint 
+-- is computed from the raw `data` chunk. `content_hash` is the xxhash of the 
+-- raw `data` chunk. `fingerprint` is a sequence of little endian u32.
+CREATE TABLE source_fingerprint (
+    sid INTEGER PRIMARY KEY,
+    kind TEXT NOT NULL,
+    content_hash INTEGER NOT NULL,
+    fingerprint BLOB NOT NULL
+);
+CREATE INDEX source_fingerprint_content_hash ON source_fingerprint(content_hash);
+
+-- +goose Down
+DROP INDEX source_fingerprint_content_hash;
+DROP TABLE source_fingerprint;
@@ -0,0 +1,21 @@
+-- +goose Up
+-- Sources that carry the same audio under different source IDs. Sources of a 
+-- group share `gid`, the smallest source ID of the group. `method` is `hash` 
+-- if every source of the group has the same content hash, or `fingerprint` 
+-- if some sources are only near-identical. `confidence` is the similarity of 
+-- a source to the source `gid`. E.g., banks that hold a duplicate:
+--   SELECT source_duplicate.gid, soundbank.path FROM source_duplicate
+--   JOIN sound ON sound.sid = source_duplicate.sid
+--   JOIN soundbank ON soundbank.aid = sound.aid AND soundbank.fid = sound.fid;
+CREATE TABLE source_duplicate (
+    gid INTEGER NOT NULL,
+    sid INTEGER NOT NULL,
+    method TEXT NOT NULL,
+    confidence REAL NOT NULL,
+    PRIMARY KEY (gid, sid)
+);
+CREATE INDEX source_duplicate_sid ON source_duplicate(sid);
+
+-- +goose Down
+DROP INDEX source_duplicate_sid;
+DROP TABLE source_duplicate;
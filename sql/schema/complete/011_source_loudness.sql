@@ -0,0 +1,17 @@
+-- +goose Up
+-- Loudness of every source that can be decoded (PCM and Wwise IMA ADPCM). 
+-- `integrated` is the integrated loudness of ITU-R BS.1770-4 in LUFS. 
+-- `true_peak` is in dBTP. `sample_peak` and `rms` are in dBFS. Levels are 
+-- floored at -120. `duration` is in seconds. E.g., loudest sources:
+--   SELECT sid, integrated FROM source_loudness ORDER BY integrated DESC;
+CREATE TABLE source_loudness (
+    sid INTEGER PRIMARY KEY,
+    integrated REAL NOT NULL,
+    true_peak REAL NOT NULL,
+    sample_peak REAL NOT NULL,
+    rms REAL NOT NULL,
+    duration REAL NOT NULL
+);
+
+-- +goose Down
+DROP TABLE source_loudness;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- Waveform envelope of every source that can be decoded, for drawing 
+-- waveforms without decoding audio. `peaks` holds a pair of signed 8 bits 
+-- (min, max) per channel per bucket, bucket major. Every bucket spans 
+-- `samples_per_bucket` samples of each channel.
+CREATE TABLE source_waveform (
+    sid INTEGER PRIMARY KEY,
+    channels INTEGER NOT NULL,
+    sample_rate INTEGER NOT NULL,
+    samples_per_bucket INTEGER NOT NULL,
+    peaks BLOB NOT NULL
+);
+
+-- +goose Down
+DROP TABLE source_waveform;
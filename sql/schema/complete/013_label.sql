@@ -0,0 +1,181 @@
+-- +goose Up
+-- Tags and categories, and the archives, sound banks, hierarchy objects and
+-- sources they are assigned to. `archive.tags`, `archive.categories`,
+-- `soundbank.categories` and `hierarchy.tags` hold the same labels joined
+-- with `;`.
+CREATE TABLE tag (
+    tag_id INTEGER PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE category (
+    category_id INTEGER PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE archive_tag (
+    aid TEXT NOT NULL,
+    tag_id INTEGER NOT NULL,
+    PRIMARY KEY (aid, tag_id),
+    FOREIGN KEY (aid) REFERENCES archive(aid),
+    FOREIGN KEY (tag_id) REFERENCES tag(tag_id)
+);
+CREATE INDEX archive_tag_tag_id ON archive_tag(tag_id);
+
+CREATE TABLE archive_category (
+    aid TEXT NOT NULL,
+    category_id INTEGER NOT NULL,
+    PRIMARY KEY (aid, category_id),
+    FOREIGN KEY (aid) REFERENCES archive(aid),
+    FOREIGN KEY (category_id) REFERENCES category(category_id)
+);
+CREATE INDEX archive_category_category_id ON archive_category(category_id);
+
+CREATE TABLE soundbank_tag (
+    aid TEXT NOT NULL,
+    fid INTEGER NOT NULL,
+    tag_id INTEGER NOT NULL,
+    PRIMARY KEY (aid, fid, tag_id),
+    FOREIGN KEY (tag_id) REFERENCES tag(tag_id)
+);
+CREATE INDEX soundbank_tag_tag_id ON soundbank_tag(tag_id);
+
+CREATE TABLE soundbank_category (
+    aid TEXT NOT NULL,
+    fid INTEGER NOT NULL,
+    category_id INTEGER NOT NULL,
+    PRIMARY KEY (aid, fid, category_id),
+    FOREIGN KEY (category_id) REFERENCES category(category_id)
+);
+CREATE INDEX soundbank_category_category_id ON soundbank_category(category_id);
+
+CREATE TABLE hierarchy_tag (
+    aid TEXT NOT NULL,
+    fid INTEGER NOT NULL,
+    hid INTEGER NOT NULL,
+    tag_id INTEGER NOT NULL,
+    PRIMARY KEY (aid, fid, hid, tag_id),
+    FOREIGN KEY (tag_id) REFERENCES tag(tag_id)
+);
+CREATE INDEX hierarchy_tag_tag_id ON hierarchy_tag(tag_id);
+
+CREATE TABLE source_tag (
+    sid INTEGER NOT NULL,
+    tag_id INTEGER NOT NULL,
+    PRIMARY KEY (sid, tag_id),
+    FOREIGN KEY (tag_id) REFERENCES tag(tag_id)
+);
+CREATE INDEX source_tag_tag_id ON source_tag(tag_id);
+
+-- Labels of an entity, including the ones inherited from the entity that
+-- contains it (archive > sound bank > hierarchy object > source). `kind` is
+-- `tag` or `category`. E.g., sources of weapons:
+--   SELECT DISTINCT sid FROM source_label
+--   WHERE kind = 'category' AND name = 'weapons';
+CREATE VIEW archive_label AS
+SELECT archive_tag.aid, 'tag' AS kind, tag.name FROM archive_tag
+JOIN tag ON tag.tag_id = archive_tag.tag_id
+UNION
+SELECT archive_category.aid, 'category' AS kind, category.name FROM archive_category
+JOIN category ON category.category_id = archive_category.category_id;
+
+CREATE VIEW soundbank_label AS
+SELECT soundbank_tag.aid, soundbank_tag.fid, 'tag' AS kind, tag.name FROM soundbank_tag
+JOIN tag ON tag.tag_id = soundbank_tag.tag_id
+UNION
+SELECT soundbank_category.aid, soundbank_category.fid, 'category' AS kind, category.name
+FROM soundbank_category
+JOIN category ON category.category_id = soundbank_category.category_id
+UNION
+SELECT soundbank.aid, soundbank.fid, archive_label.kind, archive_label.name FROM soundbank
+JOIN archive_label ON archive_label.aid = soundbank.aid;
+
+CREATE VIEW hierarchy_label AS
+SELECT hierarchy_tag.aid, hierarchy_tag.fid, hierarchy_tag.hid, 'tag' AS kind, tag.name
+FROM hierarchy_tag
+JOIN tag ON tag.tag_id = hierarchy_tag.tag_id
+UNION
+SELECT hierarchy.aid, hierarchy.fid, hierarchy.hid, soundbank_label.kind, soundbank_label.name
+FROM hierarchy
+JOIN soundbank_label ON soundbank_label.aid = hierarchy.aid AND soundbank_label.fid = hierarchy.fid;
+
+CREATE VIEW source_label AS
+SELECT source_tag.sid, 'tag' AS kind, tag.name FROM source_tag
+JOIN tag ON tag.tag_id = source_tag.tag_id
+UNION
+SELECT sound.sid, hierarchy_label.kind, hierarchy_label.name FROM sound
+JOIN hierarchy_label ON hierarchy_label.aid = sound.aid AND
+                        hierarchy_label.fid = sound.fid AND
+                        hierarchy_label.hid = sound.hid;
+
+-- Split the existing TEXT columns
+CREATE TEMP TABLE label_split (
+    target TEXT NOT NULL,
+    aid TEXT NOT NULL,
+    fid INTEGER NOT NULL,
+    hid INTEGER NOT NULL,
+    name TEXT NOT NULL
+);
+
+-- +goose StatementBegin
+INSERT INTO label_split
+WITH RECURSIVE split (target, aid, fid, hid, name, rest) AS (
+    SELECT 'archive_tag', aid, 0, 0, '', tags || ';' FROM archive
+    UNION ALL
+    SELECT 'archive_category', aid, 0, 0, '', categories || ';' FROM archive
+    UNION ALL
+    SELECT 'soundbank_category', aid, fid, 0, '', categories || ';' FROM soundbank
+    UNION ALL
+    SELECT 'hierarchy_tag', aid, fid, hid, '', tags || ';' FROM hierarchy
+    UNION ALL
+    SELECT target, aid, fid, hid,
+           TRIM(SUBSTR(rest, 1, INSTR(rest, ';') - 1)),
+           SUBSTR(rest, INSTR(rest, ';') + 1)
+    FROM split WHERE rest <> ''
+)
+SELECT target, aid, fid, hid, name FROM split WHERE name <> '';
+-- +goose StatementEnd
+
+INSERT OR IGNORE INTO tag (name)
+SELECT DISTINCT name FROM label_split WHERE target IN ('archive_tag', 'hierarchy_tag');
+
+INSERT OR IGNORE INTO category (name)
+SELECT DISTINCT name FROM label_split WHERE target IN ('archive_category', 'soundbank_category');
+
+INSERT OR IGNORE INTO archive_tag (aid, tag_id)
+SELECT label_split.aid, tag.tag_id FROM label_split
+JOIN tag ON tag.name = label_split.name WHERE label_split.target = 'archive_tag';
+
+INSERT OR IGNORE INTO archive_category (aid, category_id)
+SELECT label_split.aid, category.category_id FROM label_split
+JOIN category ON category.name = label_split.name WHERE label_split.target = 'archive_category';
+
+INSERT OR IGNORE INTO soundbank_category (aid, fid, category_id)
+SELECT label_split.aid, label_split.fid, category.category_id FROM label_split
+JOIN category ON category.name = label_split.name WHERE label_split.target = 'soundbank_category';
+
+INSERT OR IGNORE INTO hierarchy_tag (aid, fid, hid, tag_id)
+SELECT label_split.aid, label_split.fid, label_split.hid, tag.tag_id FROM label_split
+JOIN tag ON tag.name = label_split.name WHERE label_split.target = 'hierarchy_tag';
+
+DROP TABLE label_split;
+
+-- +goose Down
+DROP VIEW source_label;
+DROP VIEW hierarchy_label;
+DROP VIEW soundbank_label;
+DROP VIEW archive_label;
+DROP INDEX source_tag_tag_id;
+DROP TABLE source_tag;
+DROP INDEX hierarchy_tag_tag_id;
+DROP TABLE hierarchy_tag;
+DROP INDEX soundbank_category_category_id;
+DROP TABLE soundbank_category;
+DROP INDEX soundbank_tag_tag_id;
+DROP TABLE soundbank_tag;
+DROP INDEX archive_category_category_id;
+DROP TABLE archive_category;
+DROP INDEX archive_tag_tag_id;
+DROP TABLE archive_tag;
+DROP TABLE category;
+DROP TABLE tag;
@@ -0,0 +1,21 @@
+-- +goose Up
+-- Human labels imported from the JSON files of `label` folder. `file` is the
+-- path of the file that contributed the label relative to that folder.
+-- `target` is `source` if `id` is a source ID, or `hierarchy` if `id` is a
+-- hierarchy object ID. `tags` are joined with `;`. E.g., labels of a source:
+--   SELECT name, description, tags, file FROM label_entry
+--   WHERE target = 'source' AND id = 291193203;
+CREATE TABLE label_entry (
+    file TEXT NOT NULL,
+    target TEXT NOT NULL,
+    id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL,
+    tags TEXT NOT NULL,
+    PRIMARY KEY (file, target, id, name)
+);
+CREATE INDEX label_entry_id ON label_entry(target, id);
+
+-- +goose Down
+DROP INDEX label_entry_id;
+DROP TABLE label_entry;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- 1 if a part of an asset that is not empty is missing or cannot be read. Its 
+-- hash is left as 0 so it must not be compared with the previous build.
+ALTER TABLE asset ADD COLUMN hash_failed INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE asset DROP COLUMN hash_failed;
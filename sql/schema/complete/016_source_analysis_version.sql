@@ -0,0 +1,12 @@
+-- +goose Up
+-- Version of the decoder and the analysis that computed a row. Rows of the 
+-- previous build are only reused for an unchanged asset if their version 
+-- matches the current one. Rows from before this column are never reused.
+ALTER TABLE source_fingerprint ADD COLUMN version INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE source_loudness ADD COLUMN version INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE source_waveform ADD COLUMN version INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE source_waveform DROP COLUMN version;
+ALTER TABLE source_loudness DROP COLUMN version;
+ALTER TABLE source_fingerprint DROP COLUMN version;
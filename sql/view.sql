@@ -11,3 +11,16 @@ SELECT
 FROM hierarchy
 INNER JOIN soundbank
 ON hierarchy.aid = soundbank.aid AND hierarchy.fid = soundbank.fid;
+
+CREATE VIEW IF NOT EXISTS localized_sound_view AS
+SELECT
+    soundbank.group_path,
+    soundbank.language,
+    sound.aid,
+    sound.fid,
+    sound.hid,
+    sound.sid
+FROM sound
+INNER JOIN soundbank
+ON sound.aid = soundbank.aid AND sound.fid = soundbank.fid
+WHERE soundbank.language != 'SFX';
@@ -0,0 +1,101 @@
+package waveform
+
+import (
+	"fmt"
+)
+
+// Envelope is the minimum and maximum of every bucket of consecutive samples
+// of every channel, quantized to 8 bits. It is enough for drawing a waveform
+// at any width up to the number of buckets.
+type Envelope struct {
+	Channels         int
+	SamplesPerBucket int
+	// Per channel
+	Min              [][]int8
+	Max              [][]int8
+}
+
+// Buckets returns the number of buckets per channel.
+func (e *Envelope) Buckets() int {
+	if len(e.Min) == 0 {
+		return 0
+	}
+	return len(e.Min[0])
+}
+
+func quantize(s int16) int8 {
+	return int8(s >> 8)
+}
+
+// FromPCM computes the envelope of interleaved `samples` with at most
+// `buckets` buckets per channel.
+func FromPCM(samples []int16, channels int, buckets int) *Envelope {
+	e := &Envelope{Channels: max(channels, 0), SamplesPerBucket: 1}
+	e.Min = make([][]int8, e.Channels)
+	e.Max = make([][]int8, e.Channels)
+	if channels <= 0 || buckets <= 0 {
+		return e
+	}
+	frames := len(samples) / channels
+	e.SamplesPerBucket = max((frames + buckets - 1) / buckets, 1)
+	n := (frames + e.SamplesPerBucket - 1) / e.SamplesPerBucket
+	for c := range channels {
+		e.Min[c] = make([]int8, n)
+		e.Max[c] = make([]int8, n)
+		for b := range n {
+			lo, hi := int16(32767), int16(-32768)
+			for i := b * e.SamplesPerBucket; i < min((b + 1) * e.SamplesPerBucket, frames); i++ {
+				s := samples[i * channels + c]
+				lo, hi = min(lo, s), max(hi, s)
+			}
+			e.Min[c][b], e.Max[c][b] = quantize(lo), quantize(hi)
+		}
+	}
+	return e
+}
+
+// Bytes serializes `e` as a pair of (min, max) per channel per bucket, bucket
+// major.
+func (e *Envelope) Bytes() []byte {
+	n := e.Buckets()
+	b := make([]byte, 0, n * e.Channels * 2)
+	for i := range n {
+		for c := range e.Channels {
+			b = append(b, byte(e.Min[c][i]), byte(e.Max[c][i]))
+		}
+	}
+	return b
+}
+
+// Parse is the inverse of Bytes.
+func Parse(b []byte, channels int, samplesPerBucket int) (*Envelope, error) {
+	if channels <= 0 {
+		if len(b) != 0 {
+			return nil, fmt.Errorf("Envelope of %d bytes has no channel", len(b))
+		}
+		return &Envelope{SamplesPerBucket: samplesPerBucket}, nil
+	}
+	if len(b) % (channels * 2) != 0 {
+		return nil, fmt.Errorf(
+			"Envelope size %d is not a multiple of %d channels", len(b), channels,
+		)
+	}
+	n := len(b) / (channels * 2)
+	e := &Envelope{
+		Channels: channels,
+		SamplesPerBucket: samplesPerBucket,
+		Min: make([][]int8, channels),
+		Max: make([][]int8, channels),
+	}
+	for c := range channels {
+		e.Min[c] = make([]int8, n)
+		e.Max[c] = make([]int8, n)
+	}
+	for i := range n {
+		for c := range channels {
+			at := (i * channels + c) * 2
+			e.Min[c][i], e.Max[c][i] = int8(b[at]), int8(b[at + 1])
+		}
+	}
+	return e, nil
+}
@@ -0,0 +1,50 @@
+package waveform
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFromPCM(t *testing.T) {
+	// Stereo, 10 frames. Left ramps up, right is the inverse of left.
+	samples := []int16{}
+	for i := range 10 {
+		v := int16(i * 3000)
+		samples = append(samples, v, -v)
+	}
+	e := FromPCM(samples, 2, 4)
+	if e.SamplesPerBucket != 3 || e.Buckets() != 4 {
+		t.Fatalf("expect 4 buckets of 3 samples, got %d of %d", e.Buckets(), e.SamplesPerBucket)
+	}
+	expectMin := []int8{0, 35, 70, 105}
+	expectMax := []int8{23, 58, 93, 105}
+	if !slices.Equal(e.Min[0], expectMin) || !slices.Equal(e.Max[0], expectMax) {
+		t.Fatalf("unexpected left channel %v %v", e.Min[0], e.Max[0])
+	}
+	if e.Min[1][3] != -106 || e.Max[1][0] != 0 {
+		t.Fatalf("unexpected right channel %v %v", e.Min[1], e.Max[1])
+	}
+
+	p, err := Parse(e.Bytes(), 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for c := range 2 {
+		if !slices.Equal(p.Min[c], e.Min[c]) || !slices.Equal(p.Max[c], e.Max[c]) {
+			t.Fatalf("channel %d does not round trip", c)
+		}
+	}
+	if _, err := Parse([]byte{1, 2, 3}, 2, 3); err == nil {
+		t.Fatal("expect error on partial bucket")
+	}
+}
+
+func TestFromPCMShort(t *testing.T) {
+	e := FromPCM([]int16{100, -100}, 1, 1024)
+	if e.SamplesPerBucket != 1 || e.Buckets() != 2 {
+		t.Fatalf("expect a bucket per sample, got %d of %d", e.Buckets(), e.SamplesPerBucket)
+	}
+	if e := FromPCM(nil, 1, 1024); e.Buckets() != 0 || len(e.Bytes()) != 0 {
+		t.Fatal("expect empty envelope")
+	}
+}
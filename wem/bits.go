@@ -0,0 +1,79 @@
+package wem
+
+import (
+	"errors"
+)
+
+var ErrBitsExhausted error = errors.New("Read past the end of bit stream")
+
+// bitReader reads bits LSB first as Vorbis packs them.
+type bitReader struct {
+	b   []byte
+	bit uint
+}
+
+func newBitReader(b []byte) *bitReader {
+	return &bitReader{b: b}
+}
+
+// read reads `n` (at most 32) bits.
+func (r *bitReader) read(n uint) (uint32, error) {
+	if r.bit + n > uint(len(r.b)) * 8 {
+		return 0, ErrBitsExhausted
+	}
+	var v uint32 = 0
+	for i := uint(0); i < n; i++ {
+		if r.b[r.bit / 8] & (1 << (r.bit % 8)) != 0 {
+			v |= 1 << i
+		}
+		r.bit += 1
+	}
+	return v, nil
+}
+
+func (r *bitReader) tell() uint {
+	return r.bit
+}
+
+// bitWriter writes bits LSB first as Vorbis packs them.
+type bitWriter struct {
+	b   []byte
+	bit uint
+}
+
+// write writes the lowest `n` (at most 32) bits of `v`.
+func (w *bitWriter) write(v uint32, n uint) {
+	for i := uint(0); i < n; i++ {
+		if w.bit % 8 == 0 {
+			w.b = append(w.b, 0)
+		}
+		if v & (1 << i) != 0 {
+			w.b[w.bit / 8] |= 1 << (w.bit % 8)
+		}
+		w.bit += 1
+	}
+}
+
+// copy reads `n` bits from `r` and writes them as is.
+func (w *bitWriter) copy(r *bitReader, n uint) (uint32, error) {
+	v, err := r.read(n)
+	if err != nil {
+		return 0, err
+	}
+	w.write(v, n)
+	return v, nil
+}
+
+func (w *bitWriter) bytes() []byte {
+	return w.b
+}
+
+// ilog returns the number of bits needed to represent `v`.
+func ilog(v uint32) uint {
+	n := uint(0)
+	for v != 0 {
+		n += 1
+		v >>= 1
+	}
+	return n
+}
@@ -0,0 +1,222 @@
+package wem
+
+import (
+	"embed"
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+// File name of the packed codebook library embedded as the default library. 
+// It must be copied from ww2ogg into `codebooks` before building.
+const DefaultCodebookFile = "packed_codebooks_aoTuV_603.bin"
+
+//go:embed codebooks
+var codebooks embed.FS
+
+// CodebookLibrary is a packed codebook library such as
+// `packed_codebooks_aoTuV_603.bin` shipped with ww2ogg. Wwise Vorbis files
+// with external codebooks only store the index of each codebook in the
+// library.
+//
+// Layout: codebooks in the stripped format of Wwise back to back, followed by
+// a u32 offset of every codebook, followed by a u32 offset of the offset table
+// which is also where the last codebook ends.
+type CodebookLibrary struct {
+	data    []byte
+	offsets []uint32
+}
+
+func LoadCodebookLibrary(p string) (*CodebookLibrary, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCodebookLibrary(b)
+}
+
+// DefaultCodebookLibrary returns the embedded aoTuV 6.03 codebook library, 
+// which Wwise Vorbis sources of the game use.
+func DefaultCodebookLibrary() (*CodebookLibrary, error) {
+	b, err := codebooks.ReadFile("codebooks/" + DefaultCodebookFile)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"%s is not embedded. Copy it from ww2ogg into wem/codebooks and " +
+			"rebuild, or provide a codebook library: %w",
+			DefaultCodebookFile, err,
+		)
+	}
+	return ParseCodebookLibrary(b)
+}
+
+func ParseCodebookLibrary(b []byte) (*CodebookLibrary, error) {
+	if len(b) < 4 {
+		return nil, fmt.Errorf("Codebook library is too small (%d bytes)", len(b))
+	}
+	tableOffset := binary.LittleEndian.Uint32(b[len(b) - 4:])
+	if uint64(tableOffset) > uint64(len(b) - 4) {
+		return nil, fmt.Errorf("Offset table at %d exceeds library size %d", tableOffset, len(b))
+	}
+	n := (len(b) - int(tableOffset)) / 4
+	offsets := make([]uint32, n)
+	for i := range offsets {
+		offsets[i] = binary.LittleEndian.Uint32(b[int(tableOffset) + i * 4:])
+	}
+	for i := 0; i + 1 < n; i++ {
+		if offsets[i] > offsets[i + 1] || offsets[i + 1] > tableOffset {
+			return nil, fmt.Errorf("Codebook %d has invalid range [%d, %d)", i, offsets[i], offsets[i + 1])
+		}
+	}
+	return &CodebookLibrary{data: b[:tableOffset], offsets: offsets}, nil
+}
+
+// Len returns the number of codebooks.
+func (l *CodebookLibrary) Len() int {
+	return max(len(l.offsets) - 1, 0)
+}
+
+func (l *CodebookLibrary) codebook(i uint32) ([]byte, error) {
+	if int(i) >= l.Len() {
+		return nil, fmt.Errorf("Codebook %d is not in the library (%d codebooks)", i, l.Len())
+	}
+	return l.data[l.offsets[i]:l.offsets[i + 1]], nil
+}
+
+// rebuildExternal rebuilds codebook `i` of the library.
+func (l *CodebookLibrary) rebuildExternal(i uint32, w *bitWriter) error {
+	cb, err := l.codebook(i)
+	if err != nil {
+		return err
+	}
+	r := newBitReader(cb)
+	if err := rebuildCodebook(r, w); err != nil {
+		return fmt.Errorf("Codebook %d: %w", i, err)
+	}
+	// Codebooks are padded to a whole number of bytes. ww2ogg packs a byte 
+	// aligned codebook with one extra byte.
+	if (r.tell() + 7) / 8 != uint(len(cb)) && r.tell() / 8 + 1 != uint(len(cb)) {
+		return fmt.Errorf(
+			"Codebook %d: expect %d bytes, consumed %d bits", i, len(cb), r.tell(),
+		)
+	}
+	return nil
+}
+
+const codebookSync = 0x564342
+
+// rebuildCodebook reads a codebook in the stripped format of Wwise and writes
+// it in the standard Vorbis format. The stripped format drops the sync
+// pattern, narrows dimensions and entries, and packs codeword lengths with
+// fewer bits.
+func rebuildCodebook(r *bitReader, w *bitWriter) error {
+	dimensions, err := r.read(4)
+	if err != nil {
+		return err
+	}
+	entries, err := r.read(14)
+	if err != nil {
+		return err
+	}
+	w.write(codebookSync, 24)
+	w.write(dimensions, 16)
+	w.write(entries, 24)
+
+	ordered, err := w.copy(r, 1)
+	if err != nil {
+		return err
+	}
+	if ordered == 1 {
+		if _, err := w.copy(r, 5); err != nil { // initial length
+			return err
+		}
+		var current uint32 = 0
+		for current < entries {
+			n, err := w.copy(r, ilog(entries - current))
+			if err != nil {
+				return err
+			}
+			current += n
+		}
+		if current > entries {
+			return fmt.Errorf("Ordered codebook has %d entries, expect %d", current, entries)
+		}
+	} else {
+		lengthBits, err := r.read(3)
+		if err != nil {
+			return err
+		}
+		if lengthBits == 0 || lengthBits > 5 {
+			return fmt.Errorf("Invalid codeword length bits %d", lengthBits)
+		}
+		sparse, err := w.copy(r, 1)
+		if err != nil {
+			return err
+		}
+		for range entries {
+			present := uint32(1)
+			if sparse == 1 {
+				if present, err = w.copy(r, 1); err != nil {
+					return err
+				}
+			}
+			if present == 1 {
+				length, err := r.read(uint(lengthBits))
+				if err != nil {
+					return err
+				}
+				w.write(length, 5)
+			}
+		}
+	}
+
+	lookup, err := r.read(1)
+	if err != nil {
+		return err
+	}
+	w.write(lookup, 4)
+	if lookup == 1 {
+		return copyLookup(r, w, entries, dimensions)
+	}
+	return nil
+}
+
+func copyLookup(r *bitReader, w *bitWriter, entries uint32, dimensions uint32) error {
+	if _, err := w.copy(r, 32); err != nil { // minimum value
+		return err
+	}
+	if _, err := w.copy(r, 32); err != nil { // delta value
+		return err
+	}
+	valueBits, err := w.copy(r, 4)
+	if err != nil {
+		return err
+	}
+	if _, err := w.copy(r, 1); err != nil { // sequence flag
+		return err
+	}
+	for range quantvals(entries, dimensions) {
+		if _, err := w.copy(r, uint(valueBits) + 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// quantvals returns the largest integer whose `dimensions`-th power does not
+// exceed `entries` (lookup1_values in the Vorbis specification).
+func quantvals(entries uint32, dimensions uint32) uint32 {
+	if dimensions == 0 {
+		return 0
+	}
+	var v uint32 = 1
+	for {
+		var acc uint64 = 1
+		for range dimensions {
+			acc *= uint64(v + 1)
+			if acc > uint64(entries) {
+				return v
+			}
+		}
+		v += 1
+	}
+}
@@ -0,0 +1,107 @@
+package wem
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+const (
+	oggContinued = 0x01
+	oggBOS       = 0x02
+	oggEOS       = 0x04
+)
+
+var oggCRCTable [256]uint32
+
+func init() {
+	for i := range oggCRCTable {
+		r := uint32(i) << 24
+		for range 8 {
+			if r & 0x80000000 != 0 {
+				r = r << 1 ^ 0x04c11db7
+			} else {
+				r <<= 1
+			}
+		}
+		oggCRCTable[i] = r
+	}
+}
+
+func oggCRC(b []byte) uint32 {
+	var crc uint32 = 0
+	for _, c := range b {
+		crc = crc << 8 ^ oggCRCTable[byte(crc >> 24) ^ c]
+	}
+	return crc
+}
+
+// oggWriter writes a single logical Ogg stream. Every packet starts on a new
+// page. A packet that does not fit in one page continues on the next pages.
+type oggWriter struct {
+	w      io.Writer
+	serial uint32
+	seq    uint32
+	first  bool
+}
+
+func newOggWriter(w io.Writer, serial uint32) *oggWriter {
+	return &oggWriter{w: w, serial: serial, first: true}
+}
+
+const maxOggSegments = 255
+
+// packet writes `p` ending at `granule`. `last` marks the end of stream.
+func (o *oggWriter) packet(p []byte, granule int64, last bool) error {
+	continued := false
+	for {
+		segments := len(p) / 255 + 1
+		complete := segments <= maxOggSegments
+		if !complete {
+			segments = maxOggSegments
+		}
+		size := min(len(p), segments * 255)
+
+		var flags byte = 0
+		if continued {
+			flags |= oggContinued
+		}
+		if o.first {
+			flags |= oggBOS
+			o.first = false
+		}
+		g := int64(-1)
+		if complete {
+			g = granule
+			if last {
+				flags |= oggEOS
+			}
+		}
+
+		page := make([]byte, 27, 27 + segments + size)
+		copy(page, "OggS")
+		page[5] = flags
+		binary.LittleEndian.PutUint64(page[6:], uint64(g))
+		binary.LittleEndian.PutUint32(page[14:], o.serial)
+		binary.LittleEndian.PutUint32(page[18:], o.seq)
+		page[26] = byte(segments)
+		for i := range segments {
+			if complete && i == segments - 1 {
+				page = append(page, byte(size - i * 255))
+			} else {
+				page = append(page, 255)
+			}
+		}
+		page = append(page, p[:size]...)
+		binary.LittleEndian.PutUint32(page[22:], oggCRC(page))
+		if _, err := o.w.Write(page); err != nil {
+			return err
+		}
+		o.seq += 1
+
+		p = p[size:]
+		if complete {
+			return nil
+		}
+		continued = true
+	}
+}
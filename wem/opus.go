@@ -0,0 +1,263 @@
+package wem
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Opus always decodes at 48 kHz. Granule positions are in 48 kHz samples.
+const opusRate = 48000
+
+// opusHeader is the Wwise specific setup of an Opus file.
+type opusHeader struct {
+	sampleCount uint32
+	// Number of packets. It is also the number of entries in the size table.
+	packetCount uint32
+	preSkip     uint16
+	family      uint8
+	// Size of every packet for Wwise Opus. Nil for Opus NX where every packet
+	// has its own header.
+	sizes       []uint16
+	// Offset of the first packet relative to the start of `data`.
+	audioOffset uint32
+}
+
+// Layout of the codec setup after the channel mask in `fmt ` (Wwise Opus).
+const (
+	opusPacketCountOffset = 0x0A
+	opusPreSkipOffset     = 0x0E
+	opusFamilyOffset      = 0x11
+	sizeOfOpusExtra       = 0x12
+)
+
+// Size of the header of an Opus NX packet: u32 BE size, u32 final range.
+const sizeOfOpusNXHeader = 8
+
+var seekTag []byte = []byte{'s', 'e', 'e', 'k'}
+
+// opusHeader reads the setup of an Opus file. `data` is the whole WEM file
+// and `payload` is the content of `data` chunk.
+//
+// Wwise Opus (0x3040, 0x3041) strips Opus packets of any framing. The size of
+// every packet is stored as u16 in `seek` chunk, or at the start of `data` if
+// there is no `seek` chunk. Opus NX (0x3039) prefixes every packet with a
+// header like Nintendo Switch Opus.
+func (w *Wem) opusHeader(data []byte, payload []byte) (*opusHeader, error) {
+	h := opusHeader{sampleCount: w.SampleCount}
+	if w.FormatTag == FormatOpusNX {
+		return &h, nil
+	}
+
+	if len(w.FmtExtra) < sizeOfOpusExtra {
+		return nil, fmt.Errorf("fmt chunk is too small for Wwise Opus")
+	}
+	o := w.ByteOrder()
+	h.packetCount = o.Uint32(w.FmtExtra[opusPacketCountOffset:])
+	h.preSkip = o.Uint16(w.FmtExtra[opusPreSkipOffset:])
+	h.family = w.FmtExtra[opusFamilyOffset]
+
+	table := []byte(nil)
+	for _, c := range w.Chunks {
+		if c.Tag == string(seekTag) {
+			if uint64(c.Offset) + uint64(c.Size) > uint64(len(data)) {
+				return nil, fmt.Errorf("seek chunk exceeds file size %d", len(data))
+			}
+			table = data[c.Offset:c.Offset + c.Size]
+			break
+		}
+	}
+	if table == nil {
+		size := uint64(h.packetCount) * 2
+		if size > uint64(len(payload)) {
+			return nil, fmt.Errorf(
+				"Packet size table of %d packets exceeds data size %d",
+				h.packetCount, len(payload),
+			)
+		}
+		table = payload[:size]
+		h.audioOffset = uint32(size)
+	}
+	if uint64(len(table)) < uint64(h.packetCount) * 2 {
+		return nil, fmt.Errorf(
+			"Packet size table has %d bytes, expect %d packets", len(table), h.packetCount,
+		)
+	}
+	h.sizes = make([]uint16, h.packetCount)
+	for i := range h.sizes {
+		h.sizes[i] = o.Uint16(table[i * 2:])
+	}
+	return &h, nil
+}
+
+// opusPacketSamples returns the number of 48 kHz samples in Opus packet `p`
+// according to its TOC byte (RFC 6716, section 3.1).
+func opusPacketSamples(p []byte) (int64, error) {
+	if len(p) == 0 {
+		return 0, fmt.Errorf("Empty Opus packet")
+	}
+	config := p[0] >> 3
+	var frame int64
+	switch {
+	case config < 12: // SILK: 10, 20, 40, 60 ms
+		frame = []int64{480, 960, 1920, 2880}[config & 3]
+	case config < 16: // Hybrid: 10, 20 ms
+		frame = []int64{480, 960}[config & 1]
+	default: // CELT: 2.5, 5, 10, 20 ms
+		frame = []int64{120, 240, 480, 960}[config & 3]
+	}
+	switch p[0] & 3 {
+	case 0:
+		return frame, nil
+	case 1, 2:
+		return frame * 2, nil
+	}
+	if len(p) < 2 {
+		return 0, fmt.Errorf("Opus packet with arbitrary frames is truncated")
+	}
+	return frame * int64(p[1] & 0x3F), nil
+}
+
+// opusMapping is the channel mapping of Vorbis channel order (mapping family
+// 1) as used by libopus.
+type opusMapping struct {
+	streams uint8
+	coupled uint8
+	mapping []uint8
+}
+
+var opusVorbisMappings = [8]opusMapping{
+	{1, 0, []uint8{0}},
+	{1, 1, []uint8{0, 1}},
+	{2, 1, []uint8{0, 2, 1}},
+	{2, 2, []uint8{0, 1, 2, 3}},
+	{3, 2, []uint8{0, 4, 1, 2, 3}},
+	{4, 2, []uint8{0, 4, 1, 2, 3, 5}},
+	{4, 3, []uint8{0, 4, 1, 2, 3, 5, 6}},
+	{5, 3, []uint8{0, 6, 1, 2, 3, 4, 5, 7}},
+}
+
+func opusHead(w *Wem, h *opusHeader) ([]byte, error) {
+	b := []byte("OpusHead")
+	b = append(b, 1) // version
+	b = append(b, byte(w.Channels))
+	b = binary.LittleEndian.AppendUint16(b, h.preSkip)
+	b = binary.LittleEndian.AppendUint32(b, w.SampleRate)
+	b = binary.LittleEndian.AppendUint16(b, 0) // output gain
+	if w.Channels <= 2 && h.family == 0 {
+		return append(b, 0), nil
+	}
+	if w.Channels > 8 {
+		return nil, fmt.Errorf("Unsupported Opus channel count %d", w.Channels)
+	}
+	m := opusVorbisMappings[w.Channels - 1]
+	b = append(b, 1, m.streams, m.coupled)
+	return append(b, m.mapping...), nil
+}
+
+func opusTags() []byte {
+	b := []byte("OpusTags")
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(oggVendor)))
+	b = append(b, oggVendor...)
+	return binary.LittleEndian.AppendUint32(b, 0) // user comment list length
+}
+
+// ToOggOpus remuxes a Wwise Opus file into a standard Ogg Opus file without
+// re-encoding. `data` is the whole WEM file and `w` is its header. Granule
+// positions start at 0, include the pre-skip, and are computed from the TOC
+// byte of every packet. The last one is trimmed to the pre-skip plus the
+// sample count of the file.
+func ToOggOpus(w *Wem, data []byte, out io.Writer) error {
+	if w.Codec != CodecOpus {
+		return fmt.Errorf("Expect Opus, found %s", w.Codec)
+	}
+	if w.Channels == 0 {
+		return fmt.Errorf("WEM has no channel")
+	}
+	if uint64(w.DataOffset) + uint64(w.DataSize) > uint64(len(data)) {
+		return fmt.Errorf(
+			"Data [%d, +%d) exceeds file size %d", w.DataOffset, w.DataSize, len(data),
+		)
+	}
+	payload := data[w.DataOffset:w.DataOffset + w.DataSize]
+
+	h, err := w.opusHeader(data, payload)
+	if err != nil {
+		return err
+	}
+	packets, err := h.packets(payload)
+	if err != nil {
+		return err
+	}
+	if len(packets) == 0 {
+		return fmt.Errorf("Missing audio packets")
+	}
+
+	head, err := opusHead(w, h)
+	if err != nil {
+		return err
+	}
+	ogg := newOggWriter(out, 1)
+	if err := ogg.packet(head, 0, false); err != nil {
+		return err
+	}
+	if err := ogg.packet(opusTags(), 0, false); err != nil {
+		return err
+	}
+
+	end := int64(-1)
+	if h.sampleCount > 0 && w.SampleRate > 0 {
+		end = int64(h.preSkip) + int64(h.sampleCount) * opusRate / int64(w.SampleRate)
+	}
+	granule := int64(0)
+	for i, p := range packets {
+		n, err := opusPacketSamples(p)
+		if err != nil {
+			return fmt.Errorf("Packet %d: %w", i, err)
+		}
+		granule += n
+		last := i == len(packets) - 1
+		g := granule
+		if last && end >= 0 && g > end {
+			g = end
+		}
+		if err := ogg.packet(p, g, last); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// packets splits `payload` into Opus packets.
+func (h *opusHeader) packets(payload []byte) ([][]byte, error) {
+	packets := [][]byte{}
+	offset := uint64(h.audioOffset)
+	if h.sizes != nil {
+		for i, size := range h.sizes {
+			if offset + uint64(size) > uint64(len(payload)) {
+				return nil, fmt.Errorf(
+					"Packet %d [%d, +%d) exceeds data size %d", i, offset, size, len(payload),
+				)
+			}
+			packets = append(packets, payload[offset:offset + uint64(size)])
+			offset += uint64(size)
+		}
+		return packets, nil
+	}
+
+	for offset < uint64(len(payload)) {
+		if offset + sizeOfOpusNXHeader > uint64(len(payload)) {
+			return nil, fmt.Errorf("Packet header at %d is truncated", offset)
+		}
+		size := uint64(binary.BigEndian.Uint32(payload[offset:]))
+		offset += sizeOfOpusNXHeader
+		if offset + size > uint64(len(payload)) {
+			return nil, fmt.Errorf(
+				"Packet [%d, +%d) exceeds data size %d", offset, size, len(payload),
+			)
+		}
+		packets = append(packets, payload[offset:offset + size])
+		offset += size
+	}
+	return packets, nil
+}
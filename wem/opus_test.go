@@ -0,0 +1,151 @@
+package wem
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// CELT 20 ms packets with code 0 (1 frame), code 1 (2 frames) and code 3 (3
+// frames).
+var testOpusPackets = [][]byte{
+	{0xF8, 0x01, 0x02},
+	{0xF9, 0x03},
+	{0xFB, 0x03, 0x04, 0x05, 0x06},
+}
+
+func testOpusExtra(o testOrder, channels uint16, sampleCount uint32, family uint8) []byte {
+	extra := o.AppendUint16(nil, 960) // samples per frame
+	extra = o.AppendUint32(extra, uint32(1 << channels - 1))
+	extra = o.AppendUint32(extra, sampleCount)
+	extra = o.AppendUint32(extra, uint32(len(testOpusPackets)))
+	extra = o.AppendUint16(extra, 312) // pre-skip
+	return append(extra, 1, family)
+}
+
+func testOpus(t *testing.T, data []byte) ([]oggPacket, []byte) {
+	w, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := ToOggOpus(w, data, &out); err != nil {
+		t.Fatal(err)
+	}
+	packets := readOgg(t, out.Bytes())
+	if len(packets) != 2 + len(testOpusPackets) {
+		t.Fatalf("expect %d packets, got %d", 2 + len(testOpusPackets), len(packets))
+	}
+	head := packets[0].data
+	if !bytes.Equal(head[:8], []byte("OpusHead")) || packets[0].flags != oggBOS {
+		t.Fatalf("unexpected OpusHead %x", head)
+	}
+	if !bytes.Equal(packets[1].data[:8], []byte("OpusTags")) {
+		t.Fatalf("unexpected OpusTags %x", packets[1].data)
+	}
+	return packets[2:], head
+}
+
+func TestToOggOpusSeek(t *testing.T) {
+	le := binary.LittleEndian
+	seek := []byte{}
+	audio := []byte{}
+	for _, p := range testOpusPackets {
+		seek = le.AppendUint16(seek, uint16(len(p)))
+		audio = append(audio, p...)
+	}
+	data := testWem(le, []testChunk{
+		testFmt(le, FormatOpusWW, 2, 48000, 0, 0, testOpusExtra(le, 2, 4880, 0)),
+		{"seek", seek},
+	}, uint32(len(audio)), audio)
+
+	packets, head := testOpus(t, data)
+	if head[9] != 2 || le.Uint16(head[10:]) != 312 || le.Uint32(head[12:]) != 48000 {
+		t.Fatalf("unexpected OpusHead %x", head)
+	}
+	if len(head) != 19 || head[18] != 0 {
+		t.Fatalf("expect mapping family 0, got %x", head)
+	}
+	// Granules count the pre-skip from 0. The last packet ends at 5760 but the
+	// file only has 312 + 4880 samples.
+	granules := []int64{960, 2880, 312 + 4880}
+	for i, p := range packets {
+		if !bytes.Equal(p.data, testOpusPackets[i]) || p.granule != granules[i] {
+			t.Fatalf("packet %d: unexpected %x at %d", i, p.data, p.granule)
+		}
+	}
+	if packets[len(packets) - 1].flags != oggEOS {
+		t.Fatal("expect end of stream on the last page")
+	}
+}
+
+func TestToOggOpusInlineTable(t *testing.T) {
+	be := binary.BigEndian
+	audio := []byte{}
+	for _, p := range testOpusPackets {
+		audio = be.AppendUint16(audio, uint16(len(p)))
+	}
+	for _, p := range testOpusPackets {
+		audio = append(audio, p...)
+	}
+	data := testWem(be, []testChunk{
+		testFmt(be, FormatOpus, 6, 48000, 0, 0, testOpusExtra(be, 6, 100000, 1)),
+	}, uint32(len(audio)), audio)
+
+	packets, head := testOpus(t, data)
+	expect := []byte{1, 4, 2, 0, 4, 1, 2, 3, 5}
+	if !bytes.Equal(head[18:], expect) {
+		t.Fatalf("expect channel mapping %x, got %x", expect, head[18:])
+	}
+	if packets[2].granule != 5760 {
+		t.Fatalf("unexpected last granule %d", packets[2].granule)
+	}
+}
+
+func TestToOggOpusNX(t *testing.T) {
+	le := binary.LittleEndian
+	audio := []byte{}
+	for _, p := range testOpusPackets {
+		audio = binary.BigEndian.AppendUint32(audio, uint32(len(p)))
+		audio = append(audio, 0, 0, 0, 0) // final range
+		audio = append(audio, p...)
+	}
+	data := testWem(le, []testChunk{
+		testFmt(le, FormatOpusNX, 1, 48000, 0, 0, testOpusExtra(le, 1, 5760, 0)),
+	}, uint32(len(audio)), audio)
+
+	packets, _ := testOpus(t, data)
+	for i, p := range packets {
+		if !bytes.Equal(p.data, testOpusPackets[i]) {
+			t.Fatalf("packet %d: unexpected %x", i, p.data)
+		}
+	}
+	if packets[2].granule != 5760 {
+		t.Fatalf("unexpected last granule %d", packets[2].granule)
+	}
+
+	data = data[:len(data) - 2]
+	w, _ := Parse(data)
+	if err := ToOggOpus(w, data, &bytes.Buffer{}); err == nil {
+		t.Fatal("expect error on truncated data")
+	}
+}
+
+func TestOpusPacketSamples(t *testing.T) {
+	cases := []struct {
+		p []byte
+		n int64
+	}{
+		{[]byte{0x00}, 480}, // SILK 10 ms
+		{[]byte{0x18}, 2880}, // SILK 60 ms
+		{[]byte{0x60}, 480}, // Hybrid 10 ms
+		{[]byte{0x80}, 120}, // CELT 2.5 ms
+		{[]byte{0x83, 0x05}, 600},
+	}
+	for _, c := range cases {
+		n, err := opusPacketSamples(c.p)
+		if err != nil || n != c.n {
+			t.Fatalf("%x: expect %d samples, got %d (%v)", c.p, c.n, n, err)
+		}
+	}
+}
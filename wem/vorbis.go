@@ -0,0 +1,574 @@
+package wem
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// OggOptions controls how the setup packet of a Wwise Vorbis file is rebuilt.
+type OggOptions struct {
+	// Library of external codebooks. Required unless InlineCodebooks is set.
+	Codebooks       *CodebookLibrary
+	// Codebooks are stored in the setup packet (in the stripped format of
+	// Wwise) instead of being referenced by their index in a library.
+	InlineCodebooks bool
+}
+
+const oggVendor = "converted from Audiokinetic Wwise by hd2_audio_db"
+
+// vorbisHeader is the Wwise specific setup of a Vorbis file. Offsets are
+// relative to the start of `data`.
+type vorbisHeader struct {
+	sampleCount uint32
+	setupOffset uint32
+	audioOffset uint32
+	blocksize0  uint8
+	blocksize1  uint8
+	// Packets only have a 2 bytes size instead of a 2 bytes size and a 4 bytes
+	// granule.
+	noGranule   bool
+	// Packets drop the packet type bit and the window flags of long windows.
+	modPackets  bool
+}
+
+// Size of `vorb` when it is embedded in `fmt `.
+const vorbInFmt = -1
+
+func (w *Wem) vorbisHeader() (*vorbisHeader, error) {
+	vorb := w.Vorb
+	size := len(vorb)
+	if vorb == nil {
+		if len(w.FmtExtra) < sampleCountOffset + 0x2A {
+			return nil, fmt.Errorf("Missing vorb chunk and fmt chunk is too small for Vorbis")
+		}
+		vorb = w.FmtExtra[sampleCountOffset:]
+		size = vorbInFmt
+	}
+	o := w.ByteOrder()
+
+	h := vorbisHeader{sampleCount: o.Uint32(vorb)}
+	offset := 0
+	switch size {
+	case vorbInFmt, 0x2A:
+		h.noGranule = true
+		switch o.Uint32(vorb[0x04:]) {
+		case 0x4A, 0x4B, 0x69, 0x70:
+		default:
+			h.modPackets = true
+		}
+		offset = 0x10
+	case 0x32, 0x34:
+		offset = 0x18
+	case 0x28, 0x2C:
+		return nil, fmt.Errorf("Vorbis with header triad (vorb size %#x) is not supported", size)
+	default:
+		return nil, fmt.Errorf("Unknown vorb size %#x", size)
+	}
+	h.setupOffset = o.Uint32(vorb[offset:])
+	h.audioOffset = o.Uint32(vorb[offset + 4:])
+
+	switch size {
+	case vorbInFmt, 0x2A:
+		offset = 0x24
+	default:
+		offset = 0x2C
+	}
+	// u32 uid at offset
+	h.blocksize0 = vorb[offset + 4]
+	h.blocksize1 = vorb[offset + 5]
+	if h.blocksize0 < 6 || h.blocksize0 > 13 ||
+	   h.blocksize1 < 6 || h.blocksize1 > 13 ||
+	   h.blocksize0 > h.blocksize1 {
+		return nil, fmt.Errorf("Invalid blocksizes 2^%d, 2^%d", h.blocksize0, h.blocksize1)
+	}
+	return &h, nil
+}
+
+// packet returns the payload of the Wwise packet at `offset` of `data` and
+// the offset of the next packet.
+func (h *vorbisHeader) packet(data []byte, offset uint32, o binary.ByteOrder) (
+	[]byte, uint32, error,
+) {
+	header := uint32(6)
+	if h.noGranule {
+		header = 2
+	}
+	if uint64(offset) + uint64(header) > uint64(len(data)) {
+		return nil, 0, fmt.Errorf("Packet header at %d exceeds data size %d", offset, len(data))
+	}
+	size := uint32(o.Uint16(data[offset:]))
+	start := offset + header
+	if uint64(start) + uint64(size) > uint64(len(data)) {
+		return nil, 0, fmt.Errorf(
+			"Packet at %d: size %d exceeds data size %d", offset, size, len(data),
+		)
+	}
+	return data[start:start + size], start + size, nil
+}
+
+// ToOgg converts a Wwise Vorbis file into a standard Ogg Vorbis file. `data`
+// is the whole WEM file and `w` is its header. Granule positions are computed
+// from the blocksizes of the packets, so the output does not need to be fixed
+// by revorb.
+func ToOgg(w *Wem, data []byte, out io.Writer, opt OggOptions) error {
+	if w.Codec != CodecVorbis {
+		return fmt.Errorf("Expect Vorbis, found %s", w.Codec)
+	}
+	if !opt.InlineCodebooks && opt.Codebooks == nil {
+		return fmt.Errorf("Codebook library is required for external codebooks")
+	}
+	if uint64(w.DataOffset) + uint64(w.DataSize) > uint64(len(data)) {
+		return fmt.Errorf(
+			"Data [%d, +%d) exceeds file size %d", w.DataOffset, w.DataSize, len(data),
+		)
+	}
+	data = data[w.DataOffset:w.DataOffset + w.DataSize]
+
+	h, err := w.vorbisHeader()
+	if err != nil {
+		return err
+	}
+	o := w.ByteOrder()
+	ogg := newOggWriter(out, 1)
+
+	if err := ogg.packet(identification(w, h), 0, false); err != nil {
+		return err
+	}
+	if err := ogg.packet(comment(), 0, false); err != nil {
+		return err
+	}
+	setup, _, err := h.packet(data, h.setupOffset, o)
+	if err != nil {
+		return fmt.Errorf("Setup packet: %w", err)
+	}
+	setup, blockflags, err := rebuildSetup(setup, w.Channels, opt)
+	if err != nil {
+		return fmt.Errorf("Setup packet: %w", err)
+	}
+	if err := ogg.packet(setup, 0, false); err != nil {
+		return err
+	}
+
+	modeBits := ilog(uint32(len(blockflags) - 1))
+	blocksize := func(mode uint32) int64 {
+		if blockflags[mode] {
+			return 1 << h.blocksize1
+		}
+		return 1 << h.blocksize0
+	}
+	prevBlockflag := false
+	var prevBlocksize int64 = 0
+	var granule int64 = 0
+	offset := h.audioOffset
+	if offset >= uint32(len(data)) {
+		return fmt.Errorf("Missing audio packets")
+	}
+	for offset < uint32(len(data)) {
+		p, next, err := h.packet(data, offset, o)
+		if err != nil {
+			return err
+		}
+		last := next >= uint32(len(data))
+
+		if len(p) == 0 {
+			if err := ogg.packet(p, granule, last); err != nil {
+				return err
+			}
+			offset = next
+			continue
+		}
+
+		var mode uint32
+		if h.modPackets {
+			r := newBitReader(p)
+			bw := bitWriter{b: make([]byte, 0, len(p) + 1)}
+			bw.write(0, 1) // packet type
+			if mode, err = bw.copy(r, modeBits); err != nil {
+				return err
+			}
+			if int(mode) >= len(blockflags) {
+				return fmt.Errorf("Packet at %d: invalid mode %d", offset, mode)
+			}
+			remainder, err := r.read(8 - modeBits)
+			if err != nil {
+				return err
+			}
+			if blockflags[mode] {
+				nextBlockflag := false
+				if !last {
+					np, _, err := h.packet(data, next, o)
+					if err == nil && len(np) > 0 {
+						nm, _ := newBitReader(np).read(modeBits)
+						nextBlockflag = int(nm) < len(blockflags) && blockflags[nm]
+					}
+				}
+				bw.write(boolBit(prevBlockflag), 1)
+				bw.write(boolBit(nextBlockflag), 1)
+			}
+			bw.write(remainder, 8 - modeBits)
+			for _, b := range p[1:] {
+				bw.write(uint32(b), 8)
+			}
+			p = bw.bytes()
+		} else {
+			r := newBitReader(p)
+			if t, _ := r.read(1); t != 0 {
+				return fmt.Errorf("Packet at %d is not an audio packet", offset)
+			}
+			if mode, err = r.read(modeBits); err != nil {
+				return err
+			}
+			if int(mode) >= len(blockflags) {
+				return fmt.Errorf("Packet at %d: invalid mode %d", offset, mode)
+			}
+		}
+		prevBlockflag = blockflags[mode]
+
+		// The first packet only primes the decoder. Every other packet
+		// yields a quarter of both overlapping blocks.
+		bs := blocksize(mode)
+		if prevBlocksize != 0 {
+			granule += (prevBlocksize + bs) / 4
+		}
+		prevBlocksize = bs
+		g := granule
+		if last && w.SampleCount > 0 && g > int64(w.SampleCount) {
+			g = int64(w.SampleCount)
+		}
+		if err := ogg.packet(p, g, last); err != nil {
+			return err
+		}
+		offset = next
+	}
+	return nil
+}
+
+func boolBit(b bool) uint32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func writeVorbisPacketHeader(w *bitWriter, t uint32) {
+	w.write(t, 8)
+	for _, c := range []byte("vorbis") {
+		w.write(uint32(c), 8)
+	}
+}
+
+func identification(w *Wem, h *vorbisHeader) []byte {
+	bw := bitWriter{}
+	writeVorbisPacketHeader(&bw, 1)
+	bw.write(0, 32) // version
+	bw.write(uint32(w.Channels), 8)
+	bw.write(w.SampleRate, 32)
+	bw.write(0, 32) // maximum bitrate
+	bw.write(w.AvgBytesPerSec * 8, 32) // nominal bitrate
+	bw.write(0, 32) // minimum bitrate
+	bw.write(uint32(h.blocksize0), 4)
+	bw.write(uint32(h.blocksize1), 4)
+	bw.write(1, 1) // framing
+	return bw.bytes()
+}
+
+func comment() []byte {
+	bw := bitWriter{}
+	writeVorbisPacketHeader(&bw, 3)
+	bw.write(uint32(len(oggVendor)), 32)
+	for _, c := range []byte(oggVendor) {
+		bw.write(uint32(c), 8)
+	}
+	bw.write(0, 32) // user comment list length
+	bw.write(1, 1) // framing
+	return bw.bytes()
+}
+
+// rebuildSetup turns the setup packet of Wwise into a standard Vorbis setup
+// header. Wwise strips fields that are constant (time domain, floor type,
+// mapping type, window and transform type) and narrows the residue type. It
+// returns the block flag of every mode, which is needed to rebuild audio
+// packets and compute granule positions.
+func rebuildSetup(p []byte, channels uint16, opt OggOptions) ([]byte, []bool, error) {
+	r := newBitReader(p)
+	w := bitWriter{b: make([]byte, 0, len(p) * 2)}
+	writeVorbisPacketHeader(&w, 5)
+
+	codebooks, err := w.copy(r, 8)
+	if err != nil {
+		return nil, nil, err
+	}
+	codebooks += 1
+	for i := range codebooks {
+		if opt.InlineCodebooks {
+			if err := rebuildCodebook(r, &w); err != nil {
+				return nil, nil, fmt.Errorf("Codebook %d: %w", i, err)
+			}
+			continue
+		}
+		id, err := r.read(10)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := opt.Codebooks.rebuildExternal(id, &w); err != nil {
+			return nil, nil, err
+		}
+	}
+	checkBook := func(book uint32) error {
+		if book >= codebooks {
+			return fmt.Errorf("Invalid codebook %d (%d codebooks)", book, codebooks)
+		}
+		return nil
+	}
+
+	// Time domain transforms
+	w.write(0, 6)
+	w.write(0, 16)
+
+	floors, err := w.copy(r, 6)
+	if err != nil {
+		return nil, nil, err
+	}
+	floors += 1
+	for range floors {
+		w.write(1, 16) // floor type 1
+		partitions, err := w.copy(r, 5)
+		if err != nil {
+			return nil, nil, err
+		}
+		classList := make([]uint32, partitions)
+		maxClass := -1
+		for i := range classList {
+			if classList[i], err = w.copy(r, 4); err != nil {
+				return nil, nil, err
+			}
+			maxClass = max(maxClass, int(classList[i]))
+		}
+		dimensions := make([]uint32, maxClass + 1)
+		for c := range dimensions {
+			d, err := w.copy(r, 3)
+			if err != nil {
+				return nil, nil, err
+			}
+			dimensions[c] = d + 1
+			subclasses, err := w.copy(r, 2)
+			if err != nil {
+				return nil, nil, err
+			}
+			if subclasses != 0 {
+				master, err := w.copy(r, 8)
+				if err != nil {
+					return nil, nil, err
+				}
+				if err := checkBook(master); err != nil {
+					return nil, nil, err
+				}
+			}
+			for range 1 << subclasses {
+				book, err := w.copy(r, 8)
+				if err != nil {
+					return nil, nil, err
+				}
+				if book > 0 {
+					if err := checkBook(book - 1); err != nil {
+						return nil, nil, err
+					}
+				}
+			}
+		}
+		if _, err := w.copy(r, 2); err != nil { // multiplier
+			return nil, nil, err
+		}
+		rangeBits, err := w.copy(r, 4)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, c := range classList {
+			for range dimensions[c] {
+				if _, err := w.copy(r, uint(rangeBits)); err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+	}
+
+	residues, err := w.copy(r, 6)
+	if err != nil {
+		return nil, nil, err
+	}
+	residues += 1
+	for range residues {
+		t, err := r.read(2)
+		if err != nil {
+			return nil, nil, err
+		}
+		if t > 2 {
+			return nil, nil, fmt.Errorf("Invalid residue type %d", t)
+		}
+		w.write(t, 16)
+		for range 3 { // begin, end, partition size
+			if _, err := w.copy(r, 24); err != nil {
+				return nil, nil, err
+			}
+		}
+		classifications, err := w.copy(r, 6)
+		if err != nil {
+			return nil, nil, err
+		}
+		classifications += 1
+		classbook, err := w.copy(r, 8)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := checkBook(classbook); err != nil {
+			return nil, nil, err
+		}
+		cascade := make([]uint32, classifications)
+		for i := range cascade {
+			low, err := w.copy(r, 3)
+			if err != nil {
+				return nil, nil, err
+			}
+			flag, err := w.copy(r, 1)
+			if err != nil {
+				return nil, nil, err
+			}
+			var high uint32 = 0
+			if flag == 1 {
+				if high, err = w.copy(r, 5); err != nil {
+					return nil, nil, err
+				}
+			}
+			cascade[i] = high << 3 | low
+		}
+		for _, c := range cascade {
+			for k := range 8 {
+				if c & (1 << k) == 0 {
+					continue
+				}
+				book, err := w.copy(r, 8)
+				if err != nil {
+					return nil, nil, err
+				}
+				if err := checkBook(book); err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+	}
+
+	mappings, err := w.copy(r, 6)
+	if err != nil {
+		return nil, nil, err
+	}
+	mappings += 1
+	for range mappings {
+		w.write(0, 16) // mapping type 0
+		submaps := uint32(1)
+		flag, err := w.copy(r, 1)
+		if err != nil {
+			return nil, nil, err
+		}
+		if flag == 1 {
+			if submaps, err = w.copy(r, 4); err != nil {
+				return nil, nil, err
+			}
+			submaps += 1
+		}
+		squarePolar, err := w.copy(r, 1)
+		if err != nil {
+			return nil, nil, err
+		}
+		if squarePolar == 1 {
+			steps, err := w.copy(r, 8)
+			if err != nil {
+				return nil, nil, err
+			}
+			bits := ilog(uint32(channels) - 1)
+			for range steps + 1 {
+				magnitude, err := w.copy(r, bits)
+				if err != nil {
+					return nil, nil, err
+				}
+				angle, err := w.copy(r, bits)
+				if err != nil {
+					return nil, nil, err
+				}
+				if magnitude == angle ||
+				   magnitude >= uint32(channels) ||
+				   angle >= uint32(channels) {
+					return nil, nil, fmt.Errorf("Invalid coupling %d, %d", magnitude, angle)
+				}
+			}
+		}
+		reserved, err := w.copy(r, 2)
+		if err != nil {
+			return nil, nil, err
+		}
+		if reserved != 0 {
+			return nil, nil, fmt.Errorf("Mapping reserved field is not zero")
+		}
+		if submaps > 1 {
+			for range channels {
+				mux, err := w.copy(r, 4)
+				if err != nil {
+					return nil, nil, err
+				}
+				if mux >= submaps {
+					return nil, nil, fmt.Errorf("Invalid mapping mux %d", mux)
+				}
+			}
+		}
+		for range submaps {
+			if _, err := w.copy(r, 8); err != nil { // time config
+				return nil, nil, err
+			}
+			floor, err := w.copy(r, 8)
+			if err != nil {
+				return nil, nil, err
+			}
+			if floor >= floors {
+				return nil, nil, fmt.Errorf("Invalid floor %d", floor)
+			}
+			residue, err := w.copy(r, 8)
+			if err != nil {
+				return nil, nil, err
+			}
+			if residue >= residues {
+				return nil, nil, fmt.Errorf("Invalid residue %d", residue)
+			}
+		}
+	}
+
+	modes, err := w.copy(r, 6)
+	if err != nil {
+		return nil, nil, err
+	}
+	modes += 1
+	blockflags := make([]bool, modes)
+	for i := range blockflags {
+		flag, err := w.copy(r, 1)
+		if err != nil {
+			return nil, nil, err
+		}
+		blockflags[i] = flag == 1
+		w.write(0, 16) // window type
+		w.write(0, 16) // transform type
+		mapping, err := w.copy(r, 8)
+		if err != nil {
+			return nil, nil, err
+		}
+		if mapping >= mappings {
+			return nil, nil, fmt.Errorf("Invalid mapping %d", mapping)
+		}
+	}
+	w.write(1, 1) // framing
+
+	if (r.tell() + 7) / 8 != uint(len(p)) {
+		return nil, nil, fmt.Errorf(
+			"Expect %d bytes, consumed %d bits", len(p), r.tell(),
+		)
+	}
+	return w.bytes(), blockflags, nil
+}
@@ -0,0 +1,244 @@
+package wem
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// bits packs `fields` of (value, width) LSB first.
+func bits(fields ...[2]uint32) []byte {
+	w := bitWriter{}
+	for _, f := range fields {
+		w.write(f[0], uint(f[1]))
+	}
+	return w.bytes()
+}
+
+func vorbisPacketHeader(t uint32) [][2]uint32 {
+	fields := [][2]uint32{{t, 8}}
+	for _, c := range []byte("vorbis") {
+		fields = append(fields, [2]uint32{uint32(c), 8})
+	}
+	return fields
+}
+
+// A setup with one codebook, floor, residue and mapping, and a short and a
+// long mode, in the stripped format of Wwise.
+var testStrippedSetup = bits(
+	[2]uint32{0, 8}, // codebooks - 1
+	[2]uint32{1, 4}, [2]uint32{2, 14}, [2]uint32{0, 1}, // dimensions, entries, ordered
+	[2]uint32{1, 3}, [2]uint32{0, 1}, [2]uint32{0, 1}, [2]uint32{0, 1}, // length bits, sparse, lengths
+	[2]uint32{0, 1}, // lookup
+	[2]uint32{0, 6}, // floors - 1
+	[2]uint32{1, 5}, [2]uint32{0, 4}, // partitions, class
+	[2]uint32{0, 3}, [2]uint32{0, 2}, [2]uint32{1, 8}, // dimensions - 1, subclasses, book + 1
+	[2]uint32{1, 2}, [2]uint32{4, 4}, [2]uint32{5, 4}, // multiplier, range bits, X
+	[2]uint32{0, 6}, // residues - 1
+	[2]uint32{2, 2}, [2]uint32{0, 24}, [2]uint32{64, 24}, [2]uint32{15, 24},
+	[2]uint32{0, 6}, [2]uint32{0, 8}, [2]uint32{1, 3}, [2]uint32{0, 1}, [2]uint32{0, 8},
+	[2]uint32{0, 6}, // mappings - 1
+	[2]uint32{0, 1}, [2]uint32{0, 1}, [2]uint32{0, 2}, // submaps, square polar, reserved
+	[2]uint32{0, 8}, [2]uint32{0, 8}, [2]uint32{0, 8}, // time, floor, residue
+	[2]uint32{1, 6}, // modes - 1
+	[2]uint32{0, 1}, [2]uint32{0, 8},
+	[2]uint32{1, 1}, [2]uint32{0, 8},
+)
+
+var testStandardSetup = bits(append(vorbisPacketHeader(5),
+	[2]uint32{0, 8},
+	[2]uint32{codebookSync, 24}, [2]uint32{1, 16}, [2]uint32{2, 24}, [2]uint32{0, 1},
+	[2]uint32{0, 1}, [2]uint32{0, 5}, [2]uint32{0, 5},
+	[2]uint32{0, 4},
+	[2]uint32{0, 6}, [2]uint32{0, 16}, // time domain
+	[2]uint32{0, 6},
+	[2]uint32{1, 16}, [2]uint32{1, 5}, [2]uint32{0, 4},
+	[2]uint32{0, 3}, [2]uint32{0, 2}, [2]uint32{1, 8},
+	[2]uint32{1, 2}, [2]uint32{4, 4}, [2]uint32{5, 4},
+	[2]uint32{0, 6},
+	[2]uint32{2, 16}, [2]uint32{0, 24}, [2]uint32{64, 24}, [2]uint32{15, 24},
+	[2]uint32{0, 6}, [2]uint32{0, 8}, [2]uint32{1, 3}, [2]uint32{0, 1}, [2]uint32{0, 8},
+	[2]uint32{0, 6},
+	[2]uint32{0, 16}, [2]uint32{0, 1}, [2]uint32{0, 1}, [2]uint32{0, 2},
+	[2]uint32{0, 8}, [2]uint32{0, 8}, [2]uint32{0, 8},
+	[2]uint32{1, 6},
+	[2]uint32{0, 1}, [2]uint32{0, 16}, [2]uint32{0, 16}, [2]uint32{0, 8},
+	[2]uint32{1, 1}, [2]uint32{0, 16}, [2]uint32{0, 16}, [2]uint32{0, 8},
+	[2]uint32{1, 1}, // framing
+)...)
+
+func testVorbisWem(setup []byte, packets [][]byte) []byte {
+	le := binary.LittleEndian
+	data := le.AppendUint16(nil, uint16(len(setup)))
+	data = append(data, setup...)
+	audioOffset := len(data)
+	for _, p := range packets {
+		data = le.AppendUint16(data, uint16(len(p)))
+		data = append(data, p...)
+	}
+
+	vorb := make([]byte, 0x2A)
+	le.PutUint32(vorb[0x00:], 1000) // sample count
+	le.PutUint32(vorb[0x04:], 0) // mod signal
+	le.PutUint32(vorb[0x10:], 0) // setup offset
+	le.PutUint32(vorb[0x14:], uint32(audioOffset))
+	vorb[0x28] = 8
+	vorb[0x29] = 11
+	extra := append(make([]byte, 6), vorb...)
+
+	return testWem(le, []testChunk{
+		testFmt(le, FormatVorbis, 1, 48000, 0, 0, extra),
+	}, uint32(len(data)), data)
+}
+
+type oggPacket struct {
+	data    []byte
+	granule int64
+	flags   byte
+}
+
+func readOgg(t *testing.T, b []byte) []oggPacket {
+	packets := []oggPacket{}
+	p := []byte{}
+	for len(b) > 0 {
+		if len(b) < 27 || !bytes.Equal(b[:4], []byte("OggS")) {
+			t.Fatalf("invalid page header")
+		}
+		segments := int(b[26])
+		size := 0
+		for _, l := range b[27:27 + segments] {
+			size += int(l)
+		}
+		page := bytes.Clone(b[:27 + segments + size])
+		crc := binary.LittleEndian.Uint32(page[22:])
+		binary.LittleEndian.PutUint32(page[22:], 0)
+		if oggCRC(page) != crc {
+			t.Fatalf("CRC mismatch on page %d", binary.LittleEndian.Uint32(page[18:]))
+		}
+		body := page[27 + segments:]
+		for _, l := range page[27:27 + segments] {
+			p = append(p, body[:l]...)
+			body = body[l:]
+			if l < 255 {
+				packets = append(packets, oggPacket{
+					p, int64(binary.LittleEndian.Uint64(page[6:])), page[5],
+				})
+				p = []byte{}
+			}
+		}
+		b = b[len(page):]
+	}
+	return packets
+}
+
+func TestOggCRC(t *testing.T) {
+	if crc := oggCRC([]byte("123456789")); crc != 0x89a1897f {
+		t.Fatalf("unexpected CRC %#x", crc)
+	}
+}
+
+func TestOggLongPacket(t *testing.T) {
+	b := bytes.Buffer{}
+	ogg := newOggWriter(&b, 1)
+	p := bytes.Repeat([]byte{0xAB}, 255 * 255 + 10)
+	if err := ogg.packet(p, 42, true); err != nil {
+		t.Fatal(err)
+	}
+	packets := readOgg(t, b.Bytes())
+	if len(packets) != 1 || !bytes.Equal(packets[0].data, p) {
+		t.Fatalf("expect a single packet of %d bytes", len(p))
+	}
+	if packets[0].granule != 42 || packets[0].flags != oggContinued | oggEOS {
+		t.Fatalf("unexpected last page %d %#x", packets[0].granule, packets[0].flags)
+	}
+}
+
+func TestToOgg(t *testing.T) {
+	data := testVorbisWem(testStrippedSetup, [][]byte{
+		{0xA0, 0x11}, // short, remainder 0x50
+		{0x03, 0x22}, // long, remainder 0x01
+		{0x04}, // short, remainder 0x02
+	})
+	w, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.SampleCount != 1000 {
+		t.Fatalf("expect 1000 samples, got %d", w.SampleCount)
+	}
+
+	b := bytes.Buffer{}
+	if err := ToOgg(w, data, &b, OggOptions{}); err == nil {
+		t.Fatal("expect error without codebook library")
+	}
+	if err := ToOgg(w, data, &b, OggOptions{InlineCodebooks: true}); err != nil {
+		t.Fatal(err)
+	}
+	packets := readOgg(t, b.Bytes())
+	if len(packets) != 6 {
+		t.Fatalf("expect 6 packets, got %d", len(packets))
+	}
+	if packets[0].flags != oggBOS || packets[0].data[0] != 1 {
+		t.Fatalf("unexpected identification header %v", packets[0])
+	}
+	if !bytes.Equal(packets[2].data, testStandardSetup) {
+		t.Fatalf("unexpected setup header\n%x\n%x", packets[2].data, testStandardSetup)
+	}
+
+	expects := []oggPacket{
+		{bits([2]uint32{0, 1}, [2]uint32{0, 1}, [2]uint32{0x50, 7}, [2]uint32{0x11, 8}), 0, 0},
+		{bits(
+			[2]uint32{0, 1}, [2]uint32{1, 1}, [2]uint32{0, 1}, [2]uint32{0, 1},
+			[2]uint32{0x01, 7}, [2]uint32{0x22, 8},
+		), 576, 0},
+		{bits([2]uint32{0, 1}, [2]uint32{0, 1}, [2]uint32{0x02, 7}), 1000, oggEOS},
+	}
+	for i, e := range expects {
+		p := packets[3 + i]
+		if !bytes.Equal(p.data, e.data) || p.granule != e.granule || p.flags != e.flags {
+			t.Fatalf("audio packet %d: expect %v, got %v", i, e, p)
+		}
+	}
+}
+
+func TestCodebookLibrary(t *testing.T) {
+	// Two stripped codebooks: the one of testStrippedSetup and an ordered one.
+	cb0 := bits(
+		[2]uint32{1, 4}, [2]uint32{2, 14}, [2]uint32{0, 1},
+		[2]uint32{1, 3}, [2]uint32{0, 1}, [2]uint32{0, 1}, [2]uint32{0, 1},
+		[2]uint32{0, 1},
+	)
+	cb1 := bits(
+		[2]uint32{2, 4}, [2]uint32{4, 14}, [2]uint32{1, 1},
+		[2]uint32{1, 5}, [2]uint32{4, 3}, // initial length, 4 entries of it
+		[2]uint32{0, 1},
+	)
+	lib := append(bytes.Clone(cb0), cb1...)
+	table := len(lib)
+	for _, o := range []int{0, len(cb0)} {
+		lib = binary.LittleEndian.AppendUint32(lib, uint32(o))
+	}
+	lib = binary.LittleEndian.AppendUint32(lib, uint32(table))
+
+	l, err := ParseCodebookLibrary(lib)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Len() != 2 {
+		t.Fatalf("expect 2 codebooks, got %d", l.Len())
+	}
+	w := bitWriter{}
+	if err := l.rebuildExternal(1, &w); err != nil {
+		t.Fatal(err)
+	}
+	expect := bits(
+		[2]uint32{codebookSync, 24}, [2]uint32{2, 16}, [2]uint32{4, 24}, [2]uint32{1, 1},
+		[2]uint32{1, 5}, [2]uint32{4, 3}, [2]uint32{0, 4},
+	)
+	if !bytes.Equal(w.bytes(), expect) {
+		t.Fatalf("expect %x, got %x", expect, w.bytes())
+	}
+	if err := l.rebuildExternal(2, &w); err == nil {
+		t.Fatal("expect error on codebook beyond library")
+	}
+}
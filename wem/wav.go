@@ -0,0 +1,266 @@
+package wem
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	wio "dekr0/hd2_audio_db/io"
+)
+
+var (
+	waveFmtExtensible []byte = []byte{
+		0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10, 0x00,
+		0x80, 0x00, 0x00, 0xAA, 0x00, 0x38, 0x9B, 0x71,
+	} // KSDATAFORMAT_SUBTYPE_PCM
+)
+
+// Header of a Wwise IMA ADPCM block of one channel: s16 predictor, u8 step
+// index, u8 reserved.
+const sizeOfImaHeader = 4
+
+var imaIndexTable = [16]int{
+	-1, -1, -1, -1, 2, 4, 6, 8,
+	-1, -1, -1, -1, 2, 4, 6, 8,
+}
+
+var imaStepTable = [89]int{
+	7, 8, 9, 10, 11, 12, 13, 14, 16, 17,
+	19, 21, 23, 25, 28, 31, 34, 37, 41, 45,
+	50, 55, 60, 66, 73, 80, 88, 97, 107, 118,
+	130, 143, 157, 173, 190, 209, 230, 253, 279, 307,
+	337, 371, 408, 449, 494, 544, 598, 658, 724, 796,
+	876, 963, 1060, 1166, 1282, 1411, 1552, 1707, 1878, 2066,
+	2272, 2499, 2749, 3024, 3327, 3660, 4026, 4428, 4871, 5358,
+	5894, 6484, 7132, 7845, 8630, 9493, 10442, 11487, 12635, 13899,
+	15289, 16818, 18500, 20350, 22385, 24623, 27086, 29794, 32767,
+}
+
+// ToWAV decodes PCM or Wwise IMA ADPCM WEM `w` (parsed from `data`) and writes
+// a standard little endian WAV into `out`. Samples are interleaved per frame.
+// WAVE_FORMAT_EXTENSIBLE is used when there are more than two channels so that
+// the channel mask is kept.
+func ToWAV(w *Wem, data []byte, out io.Writer) error {
+	payload, err := w.pcmPayload(data)
+	if err != nil {
+		return err
+	}
+
+	var samples []byte
+	var bits uint16
+	switch w.Codec {
+	case CodecPCM:
+		bits = w.BitsPerSample
+		samples, err = decodePCM(w, payload)
+	case CodecIMA:
+		bits = 16
+		samples, err = decodeIMA(w, payload)
+	default:
+		return fmt.Errorf("Cannot decode %s into wav", w.Codec)
+	}
+	if err != nil {
+		return err
+	}
+
+	extensible := w.Channels > 2
+	blockAlign := w.Channels * (bits / 8)
+	b := wio.NewBufferWriter(uint(len(samples)) + 80, binary.LittleEndian)
+	b.FourCCUnsafe(riffTag)
+	riffSize := b.ReserveU32Unsafe()
+	b.FourCCUnsafe(waveTag)
+
+	b.FourCCUnsafe(fmtTag)
+	fmtSize := b.ReserveU32Unsafe()
+	if extensible {
+		b.U16Unsafe(FormatExtensible)
+	} else {
+		b.U16Unsafe(FormatPCM)
+	}
+	b.U16Unsafe(w.Channels)
+	b.U32Unsafe(w.SampleRate)
+	b.U32Unsafe(w.SampleRate * uint32(blockAlign))
+	b.U16Unsafe(blockAlign)
+	b.U16Unsafe(bits)
+	if extensible {
+		b.U16Unsafe(22) // cbSize
+		b.U16Unsafe(bits) // wValidBitsPerSample
+		b.U32Unsafe(w.ChannelMask)
+		b.WriteUnsafe(waveFmtExtensible)
+	}
+	b.PatchSizeUnsafe(fmtSize)
+
+	b.FourCCUnsafe(dataTag)
+	b.U32Unsafe(uint32(len(samples)))
+	b.WriteUnsafe(samples)
+	if len(samples) % 2 == 1 {
+		b.U8Unsafe(0)
+	}
+	b.PatchSizeUnsafe(riffSize)
+
+	_, err = out.Write(b.Bytes())
+	return err
+}
+
+// Decodable reports whether Decode supports codec `c`. Vorbis and Opus are
+// remuxed into Ogg but not decoded.
+func (c Codec) Decodable() bool {
+	return c == CodecPCM || c == CodecIMA
+}
+
+// Decode decodes PCM or Wwise IMA ADPCM WEM `w` (parsed from `data`) into
+// interleaved 16 bits samples. PCM of other widths is narrowed to 16 bits.
+func Decode(w *Wem, data []byte) ([]int16, error) {
+	payload, err := w.pcmPayload(data)
+	if err != nil {
+		return nil, err
+	}
+
+	width := 2
+	var b []byte
+	switch w.Codec {
+	case CodecPCM:
+		width = int(w.BitsPerSample / 8)
+		b, err = decodePCM(w, payload)
+	case CodecIMA:
+		b, err = decodeIMA(w, payload)
+	default:
+		return nil, fmt.Errorf("Cannot decode %s", w.Codec)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	samples := make([]int16, len(b) / width)
+	for i := range samples {
+		s := b[i * width:(i + 1) * width]
+		if width == 1 {
+			samples[i] = (int16(s[0]) - 128) << 8 // 8 bits PCM is unsigned
+		} else {
+			samples[i] = int16(binary.LittleEndian.Uint16(s[width - 2:]))
+		}
+	}
+	return samples, nil
+}
+
+// pcmPayload returns the content of `data` chunk.
+func (w *Wem) pcmPayload(data []byte) ([]byte, error) {
+	if w.Channels == 0 {
+		return nil, fmt.Errorf("WEM has no channel")
+	}
+	end := uint64(w.DataOffset) + uint64(w.DataSize)
+	if end > uint64(len(data)) {
+		return nil, fmt.Errorf(
+			"data chunk ends at %d but WEM only has %d bytes", end, len(data),
+		)
+	}
+	return data[w.DataOffset:end], nil
+}
+
+// decodePCM trims `payload` to whole frames and converts it into little
+// endian. Wwise PCM is interleaved per sample as WAV is.
+func decodePCM(w *Wem, payload []byte) ([]byte, error) {
+	width := int(w.BitsPerSample / 8)
+	if w.BitsPerSample % 8 != 0 || width == 0 || width > 4 {
+		return nil, fmt.Errorf("Unsupported PCM bits per sample %d", w.BitsPerSample)
+	}
+	frame := width * int(w.Channels)
+	if int(w.BlockAlign) != frame {
+		return nil, fmt.Errorf(
+			"PCM block align %d does not match %d channels of %d bits",
+			w.BlockAlign, w.Channels, w.BitsPerSample,
+		)
+	}
+
+	samples := make([]byte, len(payload) - len(payload) % frame)
+	copy(samples, payload)
+	if w.BigEndian && width > 1 {
+		for i := 0; i < len(samples); i += width {
+			s := samples[i:i + width]
+			for j := 0; j < width / 2; j++ {
+				s[j], s[width - 1 - j] = s[width - 1 - j], s[j]
+			}
+		}
+	}
+	return samples, nil
+}
+
+// decodeIMA decodes Wwise IMA ADPCM into interleaved 16 bits PCM (vgmstream
+// decode_wwise_ima). A block starts with the 4 bytes header of every channel,
+// followed by the nibbles of each channel one after another instead of
+// interleaving every 4 bytes as Microsoft IMA ADPCM does. Nibbles are low
+// nibble first. The predictor in the header is the first sample of the block
+// and the last nibble is not decoded, so a block has 2 samples per byte of
+// each channel (64 for the usual 0x24 bytes per channel).
+func decodeIMA(w *Wem, payload []byte) ([]byte, error) {
+	ch := int(w.Channels)
+	if ch == 0 || w.BlockAlign == 0 || int(w.BlockAlign) % ch != 0 ||
+		int(w.BlockAlign) <= sizeOfImaHeader * ch {
+		return nil, fmt.Errorf(
+			"Invalid IMA ADPCM block align %d for %d channels", w.BlockAlign, ch,
+		)
+	}
+	channelSize := (int(w.BlockAlign) - sizeOfImaHeader * ch) / ch
+	perBlock := channelSize * 2
+	blocks := len(payload) / int(w.BlockAlign)
+	total := blocks * perBlock
+	if w.SampleCount != 0 && int(w.SampleCount) < total {
+		total = int(w.SampleCount)
+	}
+
+	o := w.ByteOrder()
+	samples := make([]byte, total * ch * 2)
+	put := func(s int, c int, predictor int) {
+		at := (s * ch + c) * 2
+		binary.LittleEndian.PutUint16(samples[at:], uint16(int16(predictor)))
+	}
+	for b := 0; b < blocks; b++ {
+		block := payload[b * int(w.BlockAlign):(b + 1) * int(w.BlockAlign)]
+		first := b * perBlock
+		if first >= total {
+			break
+		}
+		for c := 0; c < ch; c++ {
+			header := block[c * sizeOfImaHeader:]
+			predictor := int(int16(o.Uint16(header)))
+			index := min(max(int(int8(header[2])), 0), len(imaStepTable) - 1)
+			put(first, c, predictor)
+
+			at := sizeOfImaHeader * ch + channelSize * c
+			for i, nibbles := range block[at:at + channelSize] {
+				for k := 0; k < 2; k++ {
+					s := first + 1 + i * 2 + k
+					if s >= first + perBlock || s >= total {
+						break
+					}
+					nibble := nibbles >> (4 * k) & 0xF
+					predictor, index = imaExpand(nibble, predictor, index)
+					put(s, c, predictor)
+				}
+			}
+		}
+	}
+	return samples, nil
+}
+
+// imaExpand expands one nibble and returns the next predictor and step index.
+func imaExpand(nibble byte, predictor int, index int) (int, int) {
+	step := imaStepTable[index]
+	diff := step >> 3
+	if nibble & 1 != 0 {
+		diff += step >> 2
+	}
+	if nibble & 2 != 0 {
+		diff += step >> 1
+	}
+	if nibble & 4 != 0 {
+		diff += step
+	}
+	if nibble & 8 != 0 {
+		predictor -= diff
+	} else {
+		predictor += diff
+	}
+	predictor = min(max(predictor, -32768), 32767)
+	index = min(max(index + imaIndexTable[nibble], 0), len(imaStepTable) - 1)
+	return predictor, index
+}
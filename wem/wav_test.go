@@ -0,0 +1,180 @@
+package wem
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// testWav parses the WAV written by ToWAV and returns its fmt and data.
+func testWav(t *testing.T, b []byte) (*Wem, []byte) {
+	w, err := Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.BigEndian {
+		t.Fatal("expect little endian WAV")
+	}
+	if binary.LittleEndian.Uint32(b[4:]) != uint32(len(b) - 8) {
+		t.Fatalf("RIFF size %d does not match file size %d", binary.LittleEndian.Uint32(b[4:]), len(b))
+	}
+	return w, b[w.DataOffset:w.DataOffset + w.DataSize]
+}
+
+func TestToWAVPCM(t *testing.T) {
+	be := binary.BigEndian
+	samples := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0xFF}
+	data := testWem(be, []testChunk{
+		testFmt(be, FormatPCM, 2, 48000, 4, 16, nil),
+	}, uint32(len(samples)), samples)
+	w, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := ToWAV(w, data, &out); err != nil {
+		t.Fatal(err)
+	}
+	wav, pcm := testWav(t, out.Bytes())
+	if wav.FormatTag != FormatPCM || wav.Channels != 2 || wav.BlockAlign != 4 {
+		t.Fatalf("unexpected format %v", wav)
+	}
+	// Trailing partial frame is dropped and every sample is swapped.
+	expect := []byte{0x02, 0x01, 0x04, 0x03, 0x06, 0x05, 0x08, 0x07}
+	if !bytes.Equal(pcm, expect) {
+		t.Fatalf("expect %x, got %x", expect, pcm)
+	}
+}
+
+func TestToWAVIMA(t *testing.T) {
+	le := binary.LittleEndian
+	// Two channels. Left rises from 0 with index 0, right falls from 1000 with
+	// index 10. Both headers come first, then the nibbles of each channel.
+	block := []byte{0x00, 0x00, 0x00, 0x00, 0xE8, 0x03, 10, 0x00}
+	block = append(block, bytes.Repeat([]byte{0x77}, 32)...)
+	block = append(block, bytes.Repeat([]byte{0x88}, 32)...)
+	data := testWem(le, []testChunk{
+		testFmt(le, FormatIMA, 2, 24000, 0x48, 4, []byte{}),
+	}, uint32(len(block)), block)
+	w, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := ToWAV(w, data, &out); err != nil {
+		t.Fatal(err)
+	}
+	wav, pcm := testWav(t, out.Bytes())
+	if wav.Channels != 2 || wav.BitsPerSample != 16 || wav.BlockAlign != 4 {
+		t.Fatalf("unexpected format %v", wav)
+	}
+	if len(pcm) != 64 * 2 * 2 {
+		t.Fatalf("expect 64 frames, got %d bytes", len(pcm))
+	}
+
+	// The first sample is the predictor in the header and the last nibble is
+	// not decoded. Nibble 7 adds
+	// step * 15 / 8 and raises the index by 8. Nibble 8 subtracts step / 8
+	// and lowers the index by 1.
+	predictor := []int{0, 1000}
+	index := []int{0, 10}
+	for s := range 64 {
+		for c, nibble := range []int{7, 8} {
+			if s > 0 {
+				step := imaStepTable[index[c]]
+				if nibble == 7 {
+					predictor[c] += step >> 3 + step >> 2 + step >> 1 + step
+					index[c] = min(index[c] + 8, 88)
+				} else {
+					predictor[c] -= step >> 3
+					index[c] = max(index[c] - 1, 0)
+				}
+				predictor[c] = min(max(predictor[c], -32768), 32767)
+			}
+			got := int(int16(le.Uint16(pcm[(s * 2 + c) * 2:])))
+			if got != predictor[c] {
+				t.Fatalf("sample %d of channel %d: expect %d, got %d", s, c, predictor[c], got)
+			}
+		}
+	}
+	if int16(le.Uint16(pcm[len(pcm) - 4:])) != 32767 {
+		t.Fatal("expect left channel to saturate")
+	}
+}
+
+// Two stereo blocks of 0x10 bytes, 8 samples per channel each. Expected
+// samples are computed separately from the IMA step and index tables. They are
+// not vgmstream output. The last nibble of each channel (high nibble of
+// 0xC6, 0xE1, 0x80 and 0x5C) is not decoded. The second block saturates both
+// channels and has a negative step index in the right header.
+func TestToWAVIMAReference(t *testing.T) {
+	le := binary.LittleEndian
+	payload := []byte{
+		0xD4, 0xFE, 0x14, 0x00, 0xD2, 0x04, 0x28, 0x00,
+		0x1F, 0x93, 0x48, 0xC6, 0x2D, 0xB0, 0x65, 0xE1,
+		0x00, 0x7D, 0x58, 0x00, 0x00, 0x80, 0xFF, 0x00,
+		0x77, 0x77, 0x07, 0x80, 0x8F, 0xF8, 0x3A, 0x5C,
+	}
+	expect := [][]int16{
+		{
+			-300, -393, -354, -270, -303, -313, -231, -88,
+			32000, 32767, 32767, 32767, 32767, 32767, 32767, 32767,
+		},
+		{
+			1234, 771, 1079, 1135, 778, 1287, 2171, 2531,
+			-32768, -32768, -32768, -32768, -32768, -32768, -32747, -32768,
+		},
+	}
+	data := testWem(le, []testChunk{
+		testFmt(le, FormatIMA, 2, 24000, 0x10, 4, []byte{}),
+	}, uint32(len(payload)), payload)
+	w, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.SampleCount != 16 {
+		t.Fatalf("expect 16 samples, got %d", w.SampleCount)
+	}
+
+	var out bytes.Buffer
+	if err := ToWAV(w, data, &out); err != nil {
+		t.Fatal(err)
+	}
+	_, pcm := testWav(t, out.Bytes())
+	if len(pcm) != 16 * 2 * 2 {
+		t.Fatalf("expect 16 frames, got %d bytes", len(pcm))
+	}
+	for c := range expect {
+		for s, e := range expect[c] {
+			got := int16(le.Uint16(pcm[(s * 2 + c) * 2:]))
+			if got != e {
+				t.Fatalf("sample %d of channel %d: expect %d, got %d", s, c, e, got)
+			}
+		}
+	}
+}
+
+func TestToWAVExtensible(t *testing.T) {
+	le := binary.LittleEndian
+	extra := le.AppendUint16(nil, 0)
+	extra = le.AppendUint32(extra, 0x3F)
+	extra = append(extra, make([]byte, 16)...)
+	data := testWem(le, []testChunk{
+		testFmt(le, FormatPCM, 6, 48000, 12, 16, extra),
+	}, 24, make([]byte, 24))
+	w, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := ToWAV(w, data, &out); err != nil {
+		t.Fatal(err)
+	}
+	wav, pcm := testWav(t, out.Bytes())
+	if wav.FormatTag != FormatExtensible || wav.ChannelMask != 0x3F || len(pcm) != 24 {
+		t.Fatalf("unexpected format %v", wav)
+	}
+}
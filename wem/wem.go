@@ -0,0 +1,315 @@
+package wem
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	wio "dekr0/hd2_audio_db/io"
+)
+
+type Codec string
+
+const (
+	CodecPCM     Codec = "PCM"
+	CodecIMA     Codec = "Wwise IMA ADPCM"
+	CodecVorbis  Codec = "Vorbis"
+	CodecOpus    Codec = "Opus"
+	CodecUnknown Codec = "Unknown"
+)
+
+// wFormatTag used by Wwise.
+const (
+	FormatPCM        uint16 = 0x0001
+	FormatIMA        uint16 = 0x0002
+	FormatOpusNX     uint16 = 0x3039
+	FormatOpus       uint16 = 0x3040
+	FormatOpusWW     uint16 = 0x3041
+	FormatExtensible uint16 = 0xFFFE
+	FormatVorbis     uint16 = 0xFFFF
+)
+
+var NotRIFF error = errors.New("Not a RIFF / RIFX file")
+
+var (
+	riffTag []byte = []byte{'R', 'I', 'F', 'F'}
+	rifxTag []byte = []byte{'R', 'I', 'F', 'X'}
+	waveTag []byte = []byte{'W', 'A', 'V', 'E'}
+	fmtTag  []byte = []byte{'f', 'm', 't', ' '}
+	vorbTag []byte = []byte{'v', 'o', 'r', 'b'}
+	smplTag []byte = []byte{'s', 'm', 'p', 'l'}
+	cueTag  []byte = []byte{'c', 'u', 'e', ' '}
+	listTag []byte = []byte{'L', 'I', 'S', 'T'}
+	adtlTag []byte = []byte{'a', 'd', 't', 'l'}
+	lablTag []byte = []byte{'l', 'a', 'b', 'l'}
+	dataTag []byte = []byte{'d', 'a', 't', 'a'}
+)
+
+type Chunk struct {
+	Tag    string
+	Offset uint32 // Offset of the payload, i.e., right after the size
+	Size   uint32
+}
+
+type Cue struct {
+	ID       uint32
+	Position uint32
+	Label    string
+}
+
+// Wem is the header of a WEM file. Only chunks before `data` are parsed, so
+// a WEM truncated anywhere after the header of `data` (e.g., prefetched head
+// of a streamed source) still parses. Sizes derived from `data` use the size
+// declared in its header.
+type Wem struct {
+	BigEndian      bool
+	Codec          Codec
+	FormatTag      uint16
+	Channels       uint16
+	SampleRate     uint32
+	AvgBytesPerSec uint32
+	BlockAlign     uint16
+	BitsPerSample  uint16
+	ChannelMask    uint32
+	// Bytes of `fmt ` after cbSize. Codec specific setup lives here.
+	FmtExtra       []byte
+	// Payload of `vorb` if the file has a separate `vorb` chunk.
+	Vorb           []byte
+	SampleCount    uint32
+	LoopStart      uint32
+	LoopEnd        uint32
+	HasLoop        bool
+	Cues           []Cue
+	Chunks         []Chunk
+	DataOffset     uint32
+	DataSize       uint32
+}
+
+// Duration returns the duration in seconds.
+func (w *Wem) Duration() float64 {
+	if w.SampleRate == 0 {
+		return 0
+	}
+	return float64(w.SampleCount) / float64(w.SampleRate)
+}
+
+// ByteOrder returns the byte order of the file (little endian for RIFF and
+// big endian for RIFX).
+func (w *Wem) ByteOrder() binary.ByteOrder {
+	if w.BigEndian {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+// Parse parses the header of a WEM file.
+func Parse(data []byte) (*Wem, error) {
+	if len(data) < 12 {
+		return nil, NotRIFF
+	}
+	w := Wem{Cues: []Cue{}, Chunks: []Chunk{}}
+	switch {
+	case bytes.Equal(data[:4], riffTag):
+	case bytes.Equal(data[:4], rifxTag):
+		w.BigEndian = true
+	default:
+		return nil, NotRIFF
+	}
+	if !bytes.Equal(data[8:12], waveTag) {
+		return nil, NotRIFF
+	}
+
+	r := wio.NewInPlaceReader(data, w.ByteOrder())
+	r.AbsSeekUnsafe(12)
+	hasFmt := false
+	hasData := false
+	labels := map[uint32]string{}
+	for !hasData {
+		tag, err := r.FourCC()
+		if err != nil {
+			break
+		}
+		size, err := r.U32()
+		if err != nil {
+			break
+		}
+		offset := r.Tell()
+		w.Chunks = append(w.Chunks, Chunk{string(tag), uint32(offset), size})
+		if bytes.Equal(tag, dataTag) {
+			w.DataOffset = uint32(offset)
+			w.DataSize = size
+			hasData = true
+			break
+		}
+		payload, err := r.ReadNoCopy(uint(size))
+		if err != nil {
+			return nil, fmt.Errorf(
+				"Chunk %q at %d: size %d exceeds file size %d",
+				tag, offset, size, len(data),
+			)
+		}
+		cr := wio.NewInPlaceReader(payload, w.ByteOrder())
+		switch {
+		case bytes.Equal(tag, fmtTag):
+			if err := w.parseFmt(cr); err != nil {
+				return nil, err
+			}
+			hasFmt = true
+		case bytes.Equal(tag, vorbTag):
+			w.Vorb = bytes.Clone(payload)
+		case bytes.Equal(tag, smplTag):
+			w.parseSmpl(cr)
+		case bytes.Equal(tag, cueTag):
+			w.parseCue(cr)
+		case bytes.Equal(tag, listTag):
+			parseList(cr, w.ByteOrder(), labels)
+		}
+		// Chunks are word aligned
+		if size % 2 == 1 && r.Len() > 0 {
+			r.RelSeekUnsafe(1)
+		}
+	}
+	if !hasFmt {
+		return nil, fmt.Errorf("Missing fmt chunk")
+	}
+	if !hasData {
+		return nil, fmt.Errorf("Missing data chunk")
+	}
+	for i := range w.Cues {
+		w.Cues[i].Label = labels[w.Cues[i].ID]
+	}
+	w.SampleCount = w.sampleCount()
+	return &w, nil
+}
+
+const sizeOfFmt = 16
+
+func (w *Wem) parseFmt(r *wio.InPlaceReader) error {
+	if r.Len() < sizeOfFmt {
+		return fmt.Errorf("fmt chunk is too small (%d bytes)", r.Len())
+	}
+	w.FormatTag = r.U16Unsafe()
+	w.Channels = r.U16Unsafe()
+	w.SampleRate = r.U32Unsafe()
+	w.AvgBytesPerSec = r.U32Unsafe()
+	w.BlockAlign = r.U16Unsafe()
+	w.BitsPerSample = r.U16Unsafe()
+	if r.Len() >= 2 {
+		cbSize := r.U16Unsafe()
+		w.FmtExtra = bytes.Clone(r.ExhaustNoCopy())
+		if uint(cbSize) < uint(len(w.FmtExtra)) {
+			w.FmtExtra = w.FmtExtra[:cbSize]
+		}
+	}
+	if len(w.FmtExtra) >= 6 {
+		// wValidBitsPerSample, dwChannelMask. Wwise writes the channel mask
+		// for every codec, not only for WAVE_FORMAT_EXTENSIBLE.
+		w.ChannelMask = w.ByteOrder().Uint32(w.FmtExtra[2:6])
+	}
+
+	switch w.FormatTag {
+	case FormatPCM, FormatExtensible:
+		w.Codec = CodecPCM
+	case FormatIMA:
+		w.Codec = CodecIMA
+	case FormatVorbis:
+		w.Codec = CodecVorbis
+	case FormatOpusNX, FormatOpus, FormatOpusWW:
+		w.Codec = CodecOpus
+	default:
+		w.Codec = CodecUnknown
+	}
+	return nil
+}
+
+// Offset of the sample count in the codec setup that follows the channel
+// mask in `fmt ` (Vorbis and Opus), or at the start of `vorb`.
+const sampleCountOffset = 6
+
+func (w *Wem) sampleCount() uint32 {
+	o := w.ByteOrder()
+	switch w.Codec {
+	case CodecPCM:
+		if w.BlockAlign == 0 {
+			return 0
+		}
+		return w.DataSize / uint32(w.BlockAlign)
+	case CodecIMA:
+		if w.Channels == 0 || uint32(w.BlockAlign) <= sizeOfImaHeader * uint32(w.Channels) {
+			return 0
+		}
+		perBlock := (uint32(w.BlockAlign) / uint32(w.Channels) - sizeOfImaHeader) * 2
+		return w.DataSize / uint32(w.BlockAlign) * perBlock
+	case CodecVorbis:
+		if len(w.Vorb) >= 4 {
+			return o.Uint32(w.Vorb)
+		}
+		fallthrough
+	case CodecOpus:
+		if len(w.FmtExtra) >= sampleCountOffset + 4 {
+			return o.Uint32(w.FmtExtra[sampleCountOffset:])
+		}
+	}
+	return 0
+}
+
+const sizeOfSmpl = 36
+const sizeOfSmplLoop = 24
+
+func (w *Wem) parseSmpl(r *wio.InPlaceReader) {
+	if r.Len() < sizeOfSmpl + sizeOfSmplLoop {
+		return
+	}
+	r.AbsSeekUnsafe(28)
+	if r.U32Unsafe() == 0 {
+		return
+	}
+	r.AbsSeekUnsafe(sizeOfSmpl)
+	r.U32Unsafe() // dwIdentifier
+	r.U32Unsafe() // dwType
+	w.LoopStart = r.U32Unsafe()
+	w.LoopEnd = r.U32Unsafe()
+	w.HasLoop = true
+}
+
+const sizeOfCuePoint = 24
+
+func (w *Wem) parseCue(r *wio.InPlaceReader) {
+	n, err := r.U32()
+	if err != nil {
+		return
+	}
+	for range n {
+		if r.Len() < sizeOfCuePoint {
+			return
+		}
+		id := r.U32Unsafe()
+		position := r.U32Unsafe()
+		r.RelSeekUnsafe(sizeOfCuePoint - 8)
+		w.Cues = append(w.Cues, Cue{ID: id, Position: position})
+	}
+}
+
+// parseList collects labels of cue points from a `LIST` chunk of type `adtl`.
+func parseList(r *wio.InPlaceReader, o binary.ByteOrder, labels map[uint32]string) {
+	tag, err := r.FourCCNoCopy()
+	if err != nil || !bytes.Equal(tag, adtlTag) {
+		return
+	}
+	for r.Len() >= 8 {
+		tag := r.FourCCNoCopyUnsafe()
+		size := r.U32Unsafe()
+		payload, err := r.ReadNoCopy(uint(size))
+		if err != nil {
+			return
+		}
+		if bytes.Equal(tag, lablTag) && len(payload) >= 4 {
+			id := o.Uint32(payload)
+			labels[id] = string(bytes.TrimRight(payload[4:], "\x00"))
+		}
+		if size % 2 == 1 && r.Len() > 0 {
+			r.RelSeekUnsafe(1)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package wem
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+type testOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+type testChunk struct {
+	tag  string
+	data []byte
+}
+
+// testWem builds a WEM with `chunks` followed by a `data` chunk that declares
+// `dataSize` bytes but only holds `dataHead`.
+func testWem(o testOrder, chunks []testChunk, dataSize uint32, dataHead []byte) []byte {
+	body := []byte("WAVE")
+	for _, c := range chunks {
+		body = append(body, c.tag...)
+		body = o.AppendUint32(body, uint32(len(c.data)))
+		body = append(body, c.data...)
+		if len(c.data) % 2 == 1 {
+			body = append(body, 0)
+		}
+	}
+	body = append(body, "data"...)
+	body = o.AppendUint32(body, dataSize)
+	body = append(body, dataHead...)
+
+	tag := "RIFF"
+	if o == testOrder(binary.BigEndian) {
+		tag = "RIFX"
+	}
+	data := append([]byte(tag), o.AppendUint32(nil, uint32(len(body)))...)
+	return append(data, body...)
+}
+
+func testFmt(
+	o testOrder,
+	format uint16,
+	channels uint16,
+	rate uint32,
+	blockAlign uint16,
+	bits uint16,
+	extra []byte,
+) testChunk {
+	b := o.AppendUint16(nil, format)
+	b = o.AppendUint16(b, channels)
+	b = o.AppendUint32(b, rate)
+	b = o.AppendUint32(b, rate * uint32(blockAlign))
+	b = o.AppendUint16(b, blockAlign)
+	b = o.AppendUint16(b, bits)
+	if extra != nil {
+		b = o.AppendUint16(b, uint16(len(extra)))
+		b = append(b, extra...)
+	}
+	return testChunk{"fmt ", b}
+}
+
+func TestParsePCM(t *testing.T) {
+	le := binary.LittleEndian
+	smpl := make([]byte, 36)
+	le.PutUint32(smpl[28:], 1)
+	smpl = le.AppendUint32(smpl, 1) // dwIdentifier
+	smpl = le.AppendUint32(smpl, 0) // dwType
+	smpl = le.AppendUint32(smpl, 100)
+	smpl = le.AppendUint32(smpl, 4000)
+	smpl = append(smpl, make([]byte, 8)...)
+
+	cue := le.AppendUint32(nil, 1)
+	cue = le.AppendUint32(cue, 7) // ID
+	cue = le.AppendUint32(cue, 960) // position
+	cue = append(cue, make([]byte, 16)...)
+
+	list := []byte("adtllabl")
+	list = le.AppendUint32(list, 9)
+	list = le.AppendUint32(list, 7)
+	list = append(list, "Hit\x00\x00"...)
+	list = append(list, 0)
+
+	data := testWem(le, []testChunk{
+		testFmt(le, FormatPCM, 2, 48000, 4, 16, []byte{}),
+		{"smpl", smpl},
+		{"cue ", cue},
+		{"LIST", list},
+	}, 48000 * 4 / 10, make([]byte, 16))
+
+	w, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Codec != CodecPCM || w.Channels != 2 || w.SampleRate != 48000 {
+		t.Fatalf("unexpected format %v", w)
+	}
+	if w.SampleCount != 4800 || w.Duration() != 0.1 {
+		t.Fatalf("unexpected sample count %d", w.SampleCount)
+	}
+	if !w.HasLoop || w.LoopStart != 100 || w.LoopEnd != 4000 {
+		t.Fatalf("unexpected loop %v", w)
+	}
+	if len(w.Cues) != 1 || w.Cues[0] != (Cue{7, 960, "Hit"}) {
+		t.Fatalf("unexpected cues %v", w.Cues)
+	}
+	if len(w.Chunks) != 5 || w.Chunks[4].Tag != "data" {
+		t.Fatalf("unexpected chunks %v", w.Chunks)
+	}
+}
+
+func TestParseVorbisRIFX(t *testing.T) {
+	be := binary.BigEndian
+	extra := be.AppendUint16(nil, 0)
+	extra = be.AppendUint32(extra, 0x4) // channel mask
+	extra = be.AppendUint32(extra, 12345) // sample count
+	extra = append(extra, make([]byte, 0x30 - len(extra))...)
+
+	data := testWem(be, []testChunk{
+		testFmt(be, FormatVorbis, 1, 48000, 0, 0, extra),
+	}, 1 << 20, nil)
+	w, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !w.BigEndian || w.Codec != CodecVorbis || w.ChannelMask != 4 {
+		t.Fatalf("unexpected format %v", w)
+	}
+	if w.SampleCount != 12345 {
+		t.Fatalf("expect 12345 samples, got %d", w.SampleCount)
+	}
+}
+
+func TestParseIMA(t *testing.T) {
+	le := binary.LittleEndian
+	data := testWem(le, []testChunk{
+		testFmt(le, FormatIMA, 2, 24000, 0x48, 4, []byte{}),
+	}, 0x48 * 10, nil)
+	w, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Codec != CodecIMA || w.SampleCount != 64 * 10 {
+		t.Fatalf("unexpected IMA header %v", w)
+	}
+
+	// Block align too small for the headers of both channels
+	data = testWem(le, []testChunk{
+		testFmt(le, FormatIMA, 2, 24000, 6, 4, []byte{}),
+	}, 6 * 10, nil)
+	if w, err = Parse(data); err != nil {
+		t.Fatal(err)
+	}
+	if w.SampleCount != 0 || w.Duration() != 0 {
+		t.Fatalf("expect no sample, got %d", w.SampleCount)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	if _, err := Parse([]byte("RIFF\x00\x00\x00\x00WAVX")); err != NotRIFF {
+		t.Fatalf("expect NotRIFF, got %v", err)
+	}
+	le := binary.LittleEndian
+	if _, err := Parse(testWem(le, nil, 0, nil)); err == nil {
+		t.Fatal("expect error on missing fmt chunk")
+	}
+	data := testWem(le, []testChunk{testFmt(le, FormatPCM, 1, 48000, 2, 16, nil)}, 0, nil)
+	le.PutUint32(data[16:], 1 << 20) // size of fmt
+	if _, err := Parse(data); err == nil {
+		t.Fatal("expect error on chunk beyond file size")
+	}
+}